@@ -4,17 +4,23 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
 	"playfast/internal/api"
 	"playfast/internal/core"
+	"playfast/internal/dhcp"
 	"playfast/internal/dialog"
 	"playfast/internal/http-client"
+	"playfast/internal/lan"
+	"playfast/internal/nat"
 	"playfast/internal/node"
+	"playfast/internal/probe"
 	"playfast/internal/systray"
 	"playfast/utils"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -27,24 +33,56 @@ import (
 )
 
 type App struct {
-	ctx  context.Context
-	box  *core.Box
-	init atomic.Bool
+	ctx    context.Context
+	box    *core.Box
+	dhcp   *dhcp.Server
+	dhcpMu sync.Mutex
+	ranker *node.Ranker
+	init   atomic.Bool
 }
 
+// rankInterval 节点质量的后台刷新间隔
+const rankInterval = 5 * time.Minute
+
+// updateClient 下载安装包使用的客户端，安装包较大，放宽超时和大小限制
+var updateClient = http_client.New(http_client.WithTimeout(10*time.Minute), http_client.WithMaxSize(512<<20))
+
 func NewApp() *App {
 	return &App{}
 }
 func (a *App) startup(ctx context.Context) {
 	defer a.init.Store(true)
 	a.ctx = ctx
+	http_client.SetVersion(Version)
+	if upstream := node.LoadUpstream(); upstream.ControlPlane {
+		http_client.SetProxy(upstream.URL())
+	}
+	a.box = core.New(a.ctx)
+	a.box.SetRouteProgress(func(done, total int) {
+		runtime.EventsEmit(a.ctx, "route-progress", done, total)
+	})
+	// 加速中的后台探测经过加速器的 NetworkManager，绕过 tun 直接走物理网卡
+	a.ranker = node.NewRanker(node.WithNetwork(a.box.NetworkContext), node.WithUpdate(func(ranking node.Ranking) {
+		runtime.EventsEmit(a.ctx, "rank-update", ranking)
+	}))
+	a.box.SetRanker(a.ranker, func(choice node.Quality) {
+		runtime.EventsEmit(a.ctx, "auto-choice", choice)
+	}, func(err error, stopped bool) {
+		// 与手动加速失败一样提示，加速已停止时通知前端恢复状态
+		if stopped {
+			a.stopDHCP()
+			runtime.EventsEmit(a.ctx, "auto-stopped", node.NewErrorInfo(err))
+		}
+		dialog.Error(a.ctx, "加速失败", err.Error())
+	})
+	go a.ranker.Run(a.ctx, rankInterval)
+	go node.RunSubscriptions(a.ctx)
 	go systray.Run(a.systemTray, func() {})
 	a.checkUpdate(false)
-	a.box = core.New(a.ctx)
 }
 func (a *App) checkUpdate(tip bool) {
 	data := make(map[string]string)
-	all, err := http_client.GET(fmt.Sprintf("%s/version.json", api.GetApiDomain()))
+	all, err := http_client.Get(a.ctx, fmt.Sprintf("%s/version.json", api.GetApiDomain()))
 	if err != nil {
 		dialog.Error(a.ctx, "获取最新版本失败", fmt.Sprintln("Error:", err))
 		return
@@ -81,7 +119,7 @@ func (a *App) checkUpdate(tip bool) {
 	if res == "No" {
 		return
 	}
-	all, err = http_client.GET(data[fmt.Sprintf("url_%s", goRuntime.GOOS)] + "?version=" + data["version"])
+	all, err = updateClient.Get(a.ctx, data[fmt.Sprintf("url_%s", goRuntime.GOOS)]+"?version="+data["version"])
 	if err != nil {
 		dialog.Error(a.ctx, "下载新版本失败", fmt.Sprintln("Error:", err))
 		return
@@ -153,7 +191,9 @@ func (a *App) systemTray() {
 		}
 	}()
 }
-func (a *App) Switch(status bool, proxy string, route bool) string {
+
+// Switch 开始或停止加速，成功时返回 nil，失败时返回带有错误代码的 ErrorInfo
+func (a *App) Switch(status bool, proxy string, route bool) *node.ErrorInfo {
 	for i := 0; i < 300; i++ {
 		if a.init.Load() == false {
 			time.Sleep(time.Millisecond * 100)
@@ -163,7 +203,11 @@ func (a *App) Switch(status bool, proxy string, route bool) string {
 	}
 	var err error
 	if status {
-		if route {
+		dhcpConfig := dhcp.LoadConfig()
+		a.box.SetLanDNS(route && dhcpConfig.Enabled)
+		a.box.SetAllowedSources(lan.AllowedIPs())
+		a.box.SetDeviceNodes(lan.AssignedNodes())
+		if route && !dhcpConfig.Enabled {
 			ip, gateway, mask, err2 := utils.RandIP()
 			if err2 != nil {
 				dialog.Error(a.ctx, "获取本地IP失败", err2.Error())
@@ -176,19 +220,23 @@ func (a *App) Switch(status bool, proxy string, route bool) string {
 		}
 		if err = a.box.Start(proxy, route); err != nil {
 			dialog.Error(a.ctx, "加速失败", err.Error())
-			return err.Error()
+			return node.NewErrorInfo(err)
+		}
+		_ = node.AddRecent(proxy)
+		if route && dhcpConfig.Enabled {
+			a.startDHCP(dhcpConfig)
 		}
-
 	} else {
+		a.stopDHCP()
 		if err = a.box.Stop(); err != nil {
 			dialog.Error(a.ctx, "停止失败", err.Error())
-			return err.Error()
+			return node.NewErrorInfo(err)
 		}
 	}
-	return ""
+	return nil
 }
 func (a *App) GetAnnouncement() string {
-	all, err := http_client.GET(fmt.Sprintf("%s/announcement", api.GetApiDomain()))
+	all, err := http_client.Get(a.ctx, fmt.Sprintf("%s/announcement", api.GetApiDomain()))
 	if err != nil {
 		return ""
 	}
@@ -200,11 +248,295 @@ func (a *App) Version() string {
 func (a *App) Open(path string) {
 	_ = exec.Command(path).Start()
 }
-func (a *App) ProxyList() []string {
-	get := node.Get()
-	strings := make([]string, 0)
-	for _, proxy := range get {
-		strings = append(strings, proxy.Name)
+
+// ProxyList 返回智能选择和所有节点及其收藏和最近使用信息，界面按 ID 选择节点
+func (a *App) ProxyList() []node.Item {
+	get := node.Get(a.ctx)
+	proxies := make([]node.Proxy, 0, len(get))
+	for _, name := range node.AutoNames(get) {
+		proxies = append(proxies, node.Proxy{ID: name, Name: name})
+	}
+	return node.LoadPreferences().Items(append(proxies, get...))
+}
+
+// SetFavorite 收藏或取消收藏节点
+func (a *App) SetFavorite(id string, favorite bool) string {
+	if err := node.SetFavorite(id, favorite); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// NodeCache 返回服务端节点列表的缓存时间和状态
+func (a *App) NodeCache() node.CacheInfo {
+	return node.RemoteCache()
+}
+
+// RefreshNodes 立即从服务端刷新节点列表
+func (a *App) RefreshNodes() node.CacheInfo {
+	return node.RefreshRemote(a.ctx)
+}
+
+// GetTuning 返回节点的本地调优覆盖，没有覆盖时返回 nil
+func (a *App) GetTuning(id string) *node.Tuning {
+	if tuning, ok := node.LoadTuning()[id]; ok {
+		return &tuning
+	}
+	return nil
+}
+
+// SetTuning 保存节点的本地调优覆盖，tuning 为 nil 时恢复服务端的设置
+func (a *App) SetTuning(id string, tuning *node.Tuning) string {
+	if err := node.SetTuning(a.ctx, id, tuning); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// GetUpstream 返回本地配置的上游代理
+func (a *App) GetUpstream() node.Upstream {
+	return node.LoadUpstream()
+}
+
+// SetUpstream 保存上游代理，节点在下次开始加速时生效，加速器自身的请求立即生效
+func (a *App) SetUpstream(upstream node.Upstream) string {
+	if err := node.SaveUpstream(upstream); err != nil {
+		return err.Error()
 	}
-	return strings
+	if upstream.ControlPlane {
+		http_client.SetProxy(upstream.URL())
+	} else {
+		http_client.SetProxy(nil)
+	}
+	return ""
+}
+
+// GetDownloadRoutes 返回控制面下载的出口策略和镜像
+func (a *App) GetDownloadRoutes() []http_client.Route {
+	return http_client.LoadRoutes()
+}
+
+// SetDownloadRoutes 保存控制面下载的出口策略和镜像，nil 表示恢复默认
+func (a *App) SetDownloadRoutes(routes []http_client.Route) string {
+	if err := http_client.SaveRoutes(routes); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// LocalNodes 返回本地添加的节点
+func (a *App) LocalNodes() []node.Proxy {
+	return node.LoadLocal()
+}
+
+// AddNode 添加本地节点，ID 与已有节点相同时覆盖该节点
+func (a *App) AddNode(proxy node.Proxy) string {
+	if _, err := node.AddNode(proxy); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// EditNode 按 ID 修改本地节点
+func (a *App) EditNode(proxy node.Proxy) string {
+	if err := node.UpdateNode(proxy); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// DeleteNode 按 ID 删除本地节点
+func (a *App) DeleteNode(id string) string {
+	if err := node.DeleteNode(id); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// ImportLinks 导入分享链接或订阅内容，一行一个
+func (a *App) ImportLinks(text string) string {
+	if _, err := node.ImportLinks(text); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// ImportedLinks 返回通过分享链接导入的节点
+func (a *App) ImportedLinks() []node.Proxy {
+	return node.ImportedLinks()
+}
+
+// RemoveLink 删除通过分享链接导入的节点
+func (a *App) RemoveLink(id string) string {
+	if err := node.RemoveLink(id); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Subscriptions 返回所有订阅及最近一次刷新的结果
+func (a *App) Subscriptions() []node.Subscription {
+	return node.LoadImported().Subscriptions
+}
+
+// AddSubscription 添加订阅并立即拉取，interval 为刷新间隔分钟数，0 表示默认
+func (a *App) AddSubscription(url, name string, interval int) string {
+	if err := node.AddSubscription(a.ctx, url, name, interval); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// RemoveSubscription 删除订阅及其节点
+func (a *App) RemoveSubscription(url string) string {
+	if err := node.RemoveSubscription(url); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// RefreshSubscriptions 立即刷新所有订阅
+func (a *App) RefreshSubscriptions() string {
+	var errs []error
+	for _, s := range node.LoadImported().Subscriptions {
+		errs = append(errs, node.RefreshSubscription(a.ctx, s.URL))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// AutoChoice 返回智能选择当前使用的节点，未使用智能选择时返回 nil
+func (a *App) AutoChoice() *node.Quality {
+	return a.box.Choice()
+}
+
+// RankNodes 立即返回按延迟、抖动和丢包排序的节点，结果过期时在后台刷新并通过 rank-update 事件推送
+func (a *App) RankNodes() node.Ranking {
+	return a.ranker.Latest(a.ctx, rankInterval)
+}
+
+// GetProbeTargets 返回按游戏保存的延迟探测目标
+func (a *App) GetProbeTargets() probe.Targets {
+	return probe.LoadTargets()
+}
+
+// SetProbeTargets 保存延迟探测目标，下次选择节点时生效
+func (a *App) SetProbeTargets(targets probe.Targets) string {
+	if config, ok := targets.Games[targets.Game]; ok {
+		if _, err := config.New(); err != nil {
+			return err.Error()
+		}
+	}
+	if err := probe.SaveTargets(targets); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// NATType 检测经过指定节点后的 NAT 类型
+func (a *App) NATType(proxy string) nat.Result {
+	ctx, cancel := context.WithTimeout(a.ctx, 15*time.Second)
+	defer cancel()
+	if region, ok := node.ParseAuto(proxy); ok {
+		best, err := node.Best(a.ranker.Ready(a.ctx, rankInterval), region)
+		if err != nil {
+			return nat.Result{Type: nat.TypeUnknown, Mapping: nat.Unknown, Filtering: nat.Unknown, Error: err.Error()}
+		}
+		proxy = best.ID
+	}
+	result, err := node.DetectNAT(ctx, proxy)
+	if err != nil {
+		return nat.Result{Type: nat.TypeUnknown, Mapping: nat.Unknown, Filtering: nat.Unknown, Error: err.Error()}
+	}
+	return *result
+}
+
+// startDHCP 在本机局域网地址上启动 DHCP 服务，将本机下发为网关和 DNS
+func (a *App) startDHCP(config dhcp.Config) {
+	info, err := utils.GetDefaultNetworkInfo()
+	if err != nil {
+		dialog.Error(a.ctx, "DHCP启动失败", err.Error())
+		return
+	}
+	ip, ipNet, err := net.ParseCIDR(info.Subnet)
+	if err != nil {
+		dialog.Error(a.ctx, "DHCP启动失败", err.Error())
+		return
+	}
+	options := append(config.Options(), dhcp.WithInterface(info.InterfaceName),
+		dhcp.WithConflictCheck(utils.IsReachable), dhcp.WithOfferCheck(time.Second))
+	server := dhcp.NewServer(ip, ipNet.Mask, options...)
+	a.dhcpMu.Lock()
+	if a.dhcp != nil {
+		_ = a.dhcp.Close()
+	}
+	a.dhcp = server
+	a.dhcpMu.Unlock()
+	go func() {
+		if err := server.ListenAndServe(); err != nil {
+			dialog.Error(a.ctx, "DHCP启动失败", err.Error())
+		}
+	}()
+	go dialog.Info(a.ctx, "网关配置", "已开启DHCP，请将主机PS/XBOX的网络设置为自动获取，重新连接网络即可")
+}
+func (a *App) stopDHCP() {
+	a.dhcpMu.Lock()
+	defer a.dhcpMu.Unlock()
+	if a.dhcp != nil {
+		_ = a.dhcp.Close()
+		a.dhcp = nil
+	}
+}
+func (a *App) GetDHCPConfig() dhcp.Config {
+	return dhcp.LoadConfig()
+}
+func (a *App) SetDHCPConfig(config dhcp.Config) string {
+	if err := dhcp.SaveConfig(config); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+func (a *App) DHCPLeases() []dhcp.Lease {
+	a.dhcpMu.Lock()
+	defer a.dhcpMu.Unlock()
+	if a.dhcp == nil {
+		return []dhcp.Lease{}
+	}
+	return a.dhcp.Leases()
+}
+
+// Devices 探测局域网内的设备
+func (a *App) Devices() []lan.Device {
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+	return lan.Discover(ctx)
+}
+
+// SetAllowedDevices 保存允许使用网关的设备，主机模式运行中立即生效
+func (a *App) SetAllowedDevices(devices []lan.Device) string {
+	if err := lan.SaveAllowed(devices); err != nil {
+		return err.Error()
+	}
+	a.box.SetAllowedSources(lan.AllowedIPs())
+	return ""
+}
+
+// GetDeviceNodes 返回各设备指定的节点
+func (a *App) GetDeviceNodes() []lan.Assignment {
+	return lan.LoadAssignments()
+}
+
+// SetDeviceNodes 保存各设备指定的节点，下次开启主机模式时生效
+func (a *App) SetDeviceNodes(assignments []lan.Assignment) string {
+	if err := lan.SaveAssignments(assignments); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// DeviceTraffic 返回本次加速各设备的流量
+func (a *App) DeviceTraffic() []core.DeviceTraffic {
+	return a.box.Traffic()
 }
@@ -22,8 +22,8 @@ if defined latest_tag (
 )
 
 REM 构建项目
-wails build -clean -ldflags "-s -w -X \"main.Version=%latest_tag%\"" -platform windows/amd64 -tags "with_gvisor,with_clash_api" -trimpath -webview2 embed
-wails build -nsis -ldflags "-s -w -X \"main.Version=%latest_tag%\"" -platform windows/amd64 -tags "with_gvisor,with_clash_api" -trimpath -webview2 embed
+wails build -clean -ldflags "-s -w -X \"main.Version=%latest_tag%\"" -platform windows/amd64 -tags "with_gvisor,with_quic,with_wireguard,with_utls,with_clash_api" -trimpath -webview2 embed
+wails build -nsis -ldflags "-s -w -X \"main.Version=%latest_tag%\"" -platform windows/amd64 -tags "with_gvisor,with_quic,with_wireguard,with_utls,with_clash_api" -trimpath -webview2 embed
 
 REM 计算 SHA-256
 set "file=build\bin\PlayFast.exe"
@@ -0,0 +1,49 @@
+// echo-server 独立运行的 TCP/UDP Echo 服务，部署在节点旁供客户端测速
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"playfast/internal/echo"
+	"syscall"
+)
+
+func main() {
+	tcp := flag.String("tcp", ":7", "TCP 监听地址，为空时不监听")
+	udp := flag.String("udp", ":7", "UDP 监听地址，为空时不监听")
+	limit := flag.Int("rate", echo.DefaultRate, "每个客户端每秒回显的字节数，0 为不限速，公网上不限速的 UDP 回显可被用作反射放大")
+	burst := flag.Int("burst", 0, "每个客户端允许的突发字节数，最小 1500，即一个 MTU 大小的 UDP 包，更大的 UDP 包总是被丢弃")
+	timestamps := flag.Bool("timestamps", true, "UDP 回显时写入服务端接收时间")
+	flag.Parse()
+
+	var options []echo.ServerOption
+	if *limit > 0 {
+		options = append(options, echo.WithRateLimit(*limit, *burst))
+	} else {
+		log.Println("未限速，请只在受信任的网络中使用")
+	}
+	if *timestamps {
+		options = append(options, echo.WithTimestamps())
+	}
+	server := echo.NewServer(options...)
+	if *tcp != "" {
+		addr, err := server.ListenTCP(*tcp)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("TCP 监听", addr)
+	}
+	if *udp != "" {
+		addr, err := server.ListenUDP(*udp)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		log.Println("UDP 监听", addr)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	_ = server.Close()
+}
@@ -1,7 +1,11 @@
 import './App.css'
-import {Switch, Version, ProxyList} from "../wailsjs/go/main/App";
+import {Switch, Version, ProxyList, GetDHCPConfig, SetDHCPConfig, DHCPLeases, DeviceTraffic, NATType, RankNodes, AutoChoice, NodeCache, RefreshNodes, SetFavorite} from "../wailsjs/go/main/App";
+import {core, dhcp, nat, node} from "../wailsjs/go/models";
+import {EventsOn} from "../wailsjs/runtime/runtime";
 import {h} from 'preact';
 import {Announcement} from "./component/Announcement";
+import {Devices} from "./component/Devices";
+import {Nodes} from "./component/Nodes";
 import {useLayoutEffect, useState, useEffect, useRef} from "preact/compat";
 // 为particlesJS添加类型声明
 declare global {
@@ -52,14 +56,33 @@ interface TrafficData {
     down: number;
 }
 
+// 节点列表的缓存时间，刷新失败时提示正在使用离线缓存
+function cacheAge(cache: node.CacheInfo) {
+    if (!cache.updatedAt || new Date(cache.updatedAt).getFullYear() < 2000) {
+        return cache.stale ? '无法获取节点列表' : '';
+    }
+    const minutes = Math.floor((Date.now() - new Date(cache.updatedAt).getTime()) / 60000);
+    const age = minutes < 1 ? '刚刚' : minutes < 60 ? `${minutes}分钟前` : `${Math.floor(minutes / 60)}小时前`;
+    return cache.stale ? `离线缓存，更新于${age}` : `节点列表更新于${age}`;
+}
+
 export function App(props: any) {
     const [status, setStatus] = useState('开始加速');
     const [isLoading, setIsLoading] = useState(false);
     const [getVersion, setVersion] = useState("v1.0.0");
-    const [getRegion, setRegion] = useState(''); // 初始为空，等待服务器列表加载后设置
-    const [proxyList, setProxyList] = useState<string[]>([]); // 新增：服务器列表状态
+    const [getRegion, setRegion] = useState(''); // 选中节点的 ID，初始为空，等待服务器列表加载后设置
+    const [proxyList, setProxyList] = useState<node.Item[]>([]); // 新增：服务器列表状态
+    const [tag, setTag] = useState(''); // 按标签筛选节点，空表示全部
     const [isAccelerated, setIsAccelerated] = useState(false);
     const [isHostMode, setIsHostMode] = useState(false); // 新增主机模式状态
+    const [dhcpConfig, setDhcpConfig] = useState<dhcp.Config>(new dhcp.Config({enabled: false}));
+    const [leases, setLeases] = useState<dhcp.Lease[]>([]); // DHCP 已分配的设备
+    const [traffic, setTraffic] = useState<core.DeviceTraffic[]>([]); // 各设备流量
+    const [quality, setQuality] = useState<Record<string, node.Quality>>({}); // 各节点的探测结果
+    const [autoChoice, setAutoChoice] = useState<node.Quality | null>(null); // 智能选择选中的节点
+    const [natResult, setNatResult] = useState<nat.Result | null>(null); // 当前节点的 NAT 类型
+    const [natLoading, setNatLoading] = useState(false);
+    const [cache, setCache] = useState<node.CacheInfo | null>(null); // 服务端节点列表的缓存状态
     const [stats, setStats] = useState({download: 0, upload: 0, totalTraffic:0, uptime: 0});
     const timerRef = useRef<number | null>(null);
     // WebSocket连接引用
@@ -153,6 +176,20 @@ export function App(props: any) {
             wsRef.current = null;
         }
     };
+    // 智能选择在网络变化后切换节点失败且无法恢复时，后端已停止加速
+    useEffect(() => {
+        return EventsOn("auto-stopped", () => {
+            setStatus("开始加速");
+            setIsAccelerated(false);
+            setAutoChoice(null);
+            disconnectWebSocket();
+            if (timerRef.current !== null) {
+                clearInterval(timerRef.current);
+                timerRef.current = null;
+            }
+            setStats({download: 0, upload: 0, totalTraffic: 0, uptime: 0});
+        });
+    }, []);
     // 组件卸载时断开WebSocket连接
     useEffect(() => {
         return () => {
@@ -169,12 +206,28 @@ export function App(props: any) {
         Switch(status === "开始加速", getRegion, isHostMode).then(
             function (res) {
                 setIsLoading(false);
-                if (res==""){
+                if (!res){
                     updateStatus();
+                } else if (res.code === "unknown_node" || res.code === "maintenance") {
+                    // 节点已下线或维护中，刷新节点列表
+                    loadProxyList();
                 }
+                AutoChoice().then(setAutoChoice);
             }
         );
     }
+    // 获取服务器列表，当前选择的节点不存在时选中第一个
+    async function loadProxyList() {
+        const servers = await ProxyList();
+        setProxyList(servers);
+        setRegion(region => servers.some(s => s.id === region) ? region : (servers[0]?.id ?? ''));
+        setCache(await NodeCache());
+    }
+    // 忽略缓存立即刷新服务端节点列表
+    async function refreshNodes() {
+        await RefreshNodes();
+        await loadProxyList();
+    }
     // 初始化获取版本信息和服务器列表
     useLayoutEffect(() => {
         async function fetchInitialData() {
@@ -183,20 +236,88 @@ export function App(props: any) {
                 const version = await Version();
                 setVersion(version);
 
-                // 获取服务器列表
-                const servers = await ProxyList();
-                setProxyList(servers);
+                // 获取DHCP配置
+                setDhcpConfig(await GetDHCPConfig());
 
-                // 设置默认选中第一个服务器
-                if (servers.length > 0) {
-                    setRegion(servers[0]);
-                }
+                // 获取服务器列表
+                await loadProxyList();
             } catch (error) {
                 console.error("获取初始数据失败:", error);
             }
         }
         fetchInitialData().then(_=> {});
     }, []);
+    // 节点质量在后台定时刷新，收到更新后替换显示
+    useEffect(() => {
+        const apply = (ranking: node.Ranking) => {
+            const result: Record<string, node.Quality> = {};
+            ranking.nodes?.forEach(q => result[q.id] = q);
+            setQuality(result);
+        };
+        RankNodes().then(apply);
+        const offChoice = EventsOn("auto-choice", setAutoChoice);
+        const offRank = EventsOn("rank-update", apply);
+        return () => {
+            offChoice();
+            offRank();
+        };
+    }, []);
+    // 节点选项后显示延迟和丢包
+    const qualityLabel = (id: string) => {
+        const q = quality[id];
+        if (!q) return '';
+        if (q.error) return ' 不可用';
+        return q.loss > 0 ? ` ${q.latency}ms 丢包${q.loss.toFixed(0)}%` : ` ${q.latency}ms`;
+    };
+    // 节点选项后显示倍率、负载和维护状态
+    const nodeLabel = (item: node.Item) => {
+        let label = item.name;
+        if (item.multiplier && item.multiplier != 1) label += ` x${item.multiplier}`;
+        if (item.load) label += ` 负载${item.load}%`;
+        if (item.maintenance) return label + ' 维护中';
+        return label + qualityLabel(item.id);
+    };
+    // 节点按智能选择、收藏、最近使用和地区分组，同一节点可以出现在多个分组中
+    const nodeGroups = () => {
+        const auto = proxyList.filter(p => !p.source);
+        const nodes = proxyList.filter(p => p.source && (tag == '' || p.tags?.includes(tag)));
+        const groups: [string, node.Item[]][] = [['智能选择', auto]];
+        groups.push(['收藏', nodes.filter(p => p.favorite)]);
+        groups.push(['最近使用', proxyList.filter(p => p.recent > 0).sort((a, b) => a.recent - b.recent)]);
+        const regions = new Map<string, node.Item[]>();
+        nodes.forEach(p => {
+            const region = p.region || '其他';
+            regions.set(region, [...(regions.get(region) ?? []), p]);
+        });
+        regions.forEach((items, region) => groups.push([region, items]));
+        return groups.filter(([, items]) => items.length > 0);
+    };
+    const tags = Array.from(new Set(proxyList.flatMap(p => p.tags ?? []))).sort();
+    const selected = proxyList.find(p => p.id === getRegion);
+    // 收藏或取消收藏当前选择的节点
+    function toggleFavorite() {
+        if (!selected) return;
+        SetFavorite(selected.id, !selected.favorite).then(res => {
+            if (res == "") loadProxyList();
+        });
+    }
+    // 主机模式加速时定时刷新 DHCP 租约和各设备流量
+    useEffect(() => {
+        if (!isAccelerated || !isHostMode) {
+            setLeases([]);
+            setTraffic([]);
+            return;
+        }
+        const refresh = () => {
+            DeviceTraffic().then(setTraffic);
+            if (dhcpConfig.enabled) {
+                DHCPLeases().then(setLeases);
+            }
+        };
+        refresh();
+        const timer = window.setInterval(refresh, 5000);
+        return () => clearInterval(timer);
+    }, [isAccelerated, isHostMode, dhcpConfig.enabled]);
     // 初始化粒子背景
     useEffect(() => {
         // 检查window和particlesJS是否可用
@@ -215,11 +336,29 @@ export function App(props: any) {
     }, []);
     function onChange(e: any) {
         setRegion(e.target.value);
+        setNatResult(null);
+    }
+    // 检测当前节点的 NAT 类型
+    function detectNAT() {
+        setNatLoading(true);
+        NATType(getRegion).then(res => {
+            setNatResult(res);
+            setNatLoading(false);
+        });
     }
     // 主机模式开关处理函数
     function handleHostModeChange(e: any) {
         setIsHostMode(e.target.checked);
     }
+    // DHCP开关处理函数
+    function handleDHCPChange(e: any) {
+        const config = new dhcp.Config({...dhcpConfig, enabled: e.target.checked});
+        SetDHCPConfig(config).then(res => {
+            if (res == "") {
+                setDhcpConfig(config);
+            }
+        });
+    }
     return (
         <div>
             <div id="App">
@@ -244,6 +383,11 @@ export function App(props: any) {
                         <div className="indicator-text">
                             {isAccelerated ? '加速中' : '未加速'}
                         </div>
+                        {isAccelerated && autoChoice && (
+                            <div className="indicator-text" title={`得分 ${autoChoice.score.toFixed(1)}`}>
+                                已选择 {autoChoice.name} {autoChoice.latency}ms
+                            </div>
+                        )}
                     </div>
                     
                     {/* 网络状态显示 */}
@@ -277,10 +421,40 @@ export function App(props: any) {
                         <div>
                             <label htmlFor="region-select">加速节点：</label>
                             <select id="region-select" value={getRegion} onChange={onChange} disabled={isAccelerated}>
-                                {proxyList.map((server, index) => (
-                                    <option key={index} value={server}>{server}</option>
+                                {nodeGroups().map(([group, items]) => (
+                                    <optgroup key={group} label={group}>
+                                        {items.map(server => (
+                                            <option key={server.id} value={server.id} disabled={server.maintenance}>{nodeLabel(server)}</option>
+                                        ))}
+                                    </optgroup>
                                 ))}
                             </select>
+                            {selected?.source && (
+                                <a className="favorite" title={selected.favorite ? '取消收藏' : '收藏'} onClick={toggleFavorite}>
+                                    {selected.favorite ? '★' : '☆'}
+                                </a>
+                            )}
+                            {tags.length > 0 && (
+                                <select className="tag-select" value={tag} onChange={(e: any) => setTag(e.target.value)} disabled={isAccelerated}>
+                                    <option value="">全部标签</option>
+                                    {tags.map(t => <option key={t} value={t}>{t}</option>)}
+                                </select>
+                            )}
+                            {!isAccelerated && <Nodes onChange={loadProxyList} selected={selected}/>}
+                        </div>
+                        {cache && (
+                            <div className={`node-cache ${cache.stale ? 'stale' : ''}`} title={cache.error}>
+                                {cacheAge(cache)}
+                                {!isAccelerated && <a onClick={refreshNodes}>刷新</a>}
+                            </div>
+                        )}
+                        <div className="nat-type">
+                            <a onClick={natLoading ? undefined : detectNAT}>{natLoading ? '检测中...' : '检测NAT类型'}</a>
+                            {natResult && (
+                                <span title={natResult.error || `映射: ${natResult.mapping} 过滤: ${natResult.filtering}`}>
+                                    NAT: {natResult.type}{natResult.public && ` (${natResult.public})`}
+                                </span>
+                            )}
                         </div>
 
                         {!isAccelerated && (
@@ -294,6 +468,33 @@ export function App(props: any) {
                                     />
                                     主机模式
                                 </label>
+                                {isHostMode && (
+                                    <label htmlFor="dhcp-mode">
+                                        <input
+                                            type="checkbox"
+                                            id="dhcp-mode"
+                                            checked={dhcpConfig.enabled}
+                                            onChange={handleDHCPChange}
+                                        />
+                                        自动分配IP
+                                    </label>
+                                )}
+                                {isHostMode && <Devices/>}
+                            </div>
+                        )}
+
+                        {(leases.length > 0 || traffic.length > 0) && (
+                            <div className="lease-list">
+                                {leases.map(lease => (
+                                    <div key={lease.mac} className="lease-item">
+                                        {lease.hostname || lease.mac} {lease.ip}
+                                    </div>
+                                ))}
+                                {traffic.map(device => (
+                                    <div key={device.ip} className="lease-item">
+                                        {device.ip} {proxyList.find(p => p.id === device.node)?.name ?? device.node} ↑{formatBytes(device.upload)} ↓{formatBytes(device.download)}
+                                    </div>
+                                ))}
                             </div>
                         )}
 
@@ -0,0 +1,94 @@
+import { Component, h } from "preact";
+import './Devices.css';
+import { Devices as ListDevices, SetAllowedDevices, GetDeviceNodes, SetDeviceNodes, ProxyList } from "../../wailsjs/go/main/App";
+import { lan, node } from "../../wailsjs/go/models";
+
+// 定义状态的类型
+interface DevicesState {
+    open: boolean;
+    loading: boolean;
+    devices: lan.Device[];
+    assignments: lan.Assignment[];
+    proxies: node.Item[];
+}
+
+// 局域网设备列表，勾选的设备才允许使用网关，全部不勾选表示不限制
+export class Devices extends Component<{}, DevicesState> {
+    constructor() {
+        super();
+        this.state = { open: false, loading: false, devices: [], assignments: [], proxies: [] };
+    }
+
+    async refresh() {
+        this.setState({ loading: true });
+        try {
+            const [devices, assignments, proxies] = await Promise.all([ListDevices(), GetDeviceNodes(), ProxyList()]);
+            this.setState({ devices, assignments, proxies });
+        } catch (error) {
+            console.error("获取设备失败:", error);
+        }
+        this.setState({ loading: false });
+    }
+
+    toggle(index: number) {
+        const devices = this.state.devices.map((device, i) =>
+            i === index ? new lan.Device({ ...device, allowed: !device.allowed }) : device
+        );
+        this.setState({ devices });
+        SetAllowedDevices(devices).then(res => {
+            if (res != "") {
+                console.error("保存设备失败:", res);
+            }
+        });
+    }
+
+    // 设备使用的节点 ID，空表示使用当前选择的节点
+    nodeOf(device: lan.Device): string {
+        const assignment = this.state.assignments.find(a => a.mac === device.mac);
+        return assignment ? assignment.node : "";
+    }
+
+    assign(device: lan.Device, node: string) {
+        const assignments = this.state.assignments.filter(a => a.mac !== device.mac);
+        if (node != "") {
+            assignments.push(new lan.Assignment({ mac: device.mac, ip: device.ip, node }));
+        }
+        this.setState({ assignments });
+        SetDeviceNodes(assignments).then(res => {
+            if (res != "") {
+                console.error("保存设备节点失败:", res);
+            }
+        });
+    }
+
+    render() {
+        if (!this.state.open) {
+            return (
+                <button className="devices-btn" onClick={() => { this.setState({ open: true }); this.refresh(); }}>
+                    设备管理
+                </button>
+            );
+        }
+        return (
+            <div className="devices-panel">
+                <div className="devices-header">
+                    <span>{this.state.loading ? "正在搜索设备..." : "允许使用网关的设备"}</span>
+                    <span className="devices-close" onClick={() => this.setState({ open: false })}>×</span>
+                </div>
+                {this.state.devices.map((device, index) => (
+                    <label key={device.mac} className="devices-item">
+                        <input type="checkbox" checked={device.allowed} onChange={() => this.toggle(index)} />
+                        {device.vendor || device.name || device.mac} {device.ip}
+                        <select value={this.nodeOf(device)} onClick={e => e.preventDefault()}
+                                onChange={(e: any) => this.assign(device, e.target.value)}>
+                            <option value="">默认节点</option>
+                            {this.state.proxies.map(proxy => (
+                                <option key={proxy.id} value={proxy.id}>{proxy.name}</option>
+                            ))}
+                        </select>
+                    </label>
+                ))}
+            </div>
+        );
+    }
+}
@@ -0,0 +1,183 @@
+import { Component, h } from "preact";
+import './Nodes.css';
+import { ImportLinks, ImportedLinks, RemoveLink, Subscriptions, AddSubscription, RemoveSubscription, RefreshSubscriptions, LocalNodes, AddNode, EditNode, DeleteNode, GetTuning, SetTuning, GetUpstream, SetUpstream, GetDownloadRoutes, SetDownloadRoutes } from "../../wailsjs/go/main/App";
+import { node, http_client } from "../../wailsjs/go/models";
+
+interface NodesProps {
+    // 节点有变化时通知外部刷新节点列表
+    onChange: () => void;
+    // 当前选择的节点，用于编辑调优
+    selected?: node.Item;
+}
+
+interface NodesState {
+    open: boolean;
+    loading: boolean;
+    links: string;
+    url: string;
+    message: string;
+    subscriptions: node.Subscription[];
+    local: node.Proxy[];
+    // 通过分享链接导入的节点
+    imported: node.Proxy[];
+    // 正在编辑的本地节点或调优 JSON，空表示未在编辑
+    editing: string;
+    // 正在编辑调优的节点 ID，空表示编辑的是本地节点
+    tuning: string;
+    // 正在编辑上游代理
+    upstream: boolean;
+    // 正在编辑下载策略
+    routes: boolean;
+}
+
+// 新建本地节点时的模板
+const template = { name: "", protocol: "shadowsocks", host: "", port: 0, method: "aes-128-gcm", password: "" };
+
+// 导入分享链接和管理订阅
+export class Nodes extends Component<NodesProps, NodesState> {
+    constructor() {
+        super();
+        this.state = { open: false, loading: false, links: "", url: "", message: "", subscriptions: [], local: [], imported: [], editing: "", tuning: "", upstream: false, routes: false };
+    }
+
+    async refresh() {
+        const [subscriptions, local, imported] = await Promise.all([Subscriptions(), LocalNodes(), ImportedLinks()]);
+        this.setState({ subscriptions, local, imported });
+    }
+
+    // 执行操作后刷新订阅列表并显示错误
+    async run(action: () => Promise<string>, clear: Partial<NodesState> = {}) {
+        this.setState({ loading: true, message: "" });
+        const res = await action();
+        this.setState({ loading: false, message: res, ...(res == "" ? clear : {}) });
+        await this.refresh();
+        this.props.onChange();
+    }
+
+    // 编辑节点的本地调优，没有覆盖时显示服务端的设置
+    async editTuning(item: node.Item) {
+        const tuning = await GetTuning(item.id) ?? item.tuning ?? {};
+        this.setState({ editing: JSON.stringify(tuning, null, 2), tuning: item.id });
+    }
+
+    // 编辑本地上游代理，type 为空表示不使用
+    async editUpstream() {
+        const upstream = await GetUpstream();
+        this.setState({ editing: JSON.stringify(upstream, null, 2), upstream: true });
+    }
+
+    // 编辑规则文件等下载的出口策略和镜像
+    async editRoutes() {
+        const routes = await GetDownloadRoutes();
+        this.setState({ editing: JSON.stringify(routes, null, 2), routes: true });
+    }
+
+    // 保存编辑中的节点，有 ID 且已存在时修改，否则添加
+    save() {
+        if (this.state.routes) {
+            let routes: http_client.Route[] | null;
+            try {
+                // 清空内容表示恢复默认策略
+                routes = this.state.editing.trim() == "" ? null : (JSON.parse(this.state.editing) as any[]).map(r => http_client.Route.createFrom(r));
+            } catch (error) {
+                this.setState({ message: `JSON 格式错误: ${error}` });
+                return;
+            }
+            this.run(() => SetDownloadRoutes(routes as http_client.Route[]), { editing: "", routes: false });
+            return;
+        }
+        if (this.state.upstream) {
+            let upstream: node.Upstream;
+            try {
+                upstream = node.Upstream.createFrom(JSON.parse(this.state.editing));
+            } catch (error) {
+                this.setState({ message: `JSON 格式错误: ${error}` });
+                return;
+            }
+            this.run(() => SetUpstream(upstream), { editing: "", upstream: false });
+            return;
+        }
+        if (this.state.tuning) {
+            let tuning: node.Tuning | null;
+            try {
+                // 清空内容表示恢复服务端的设置
+                tuning = this.state.editing.trim() == "" ? null : node.Tuning.createFrom(JSON.parse(this.state.editing));
+            } catch (error) {
+                this.setState({ message: `JSON 格式错误: ${error}` });
+                return;
+            }
+            this.run(() => SetTuning(this.state.tuning, tuning as node.Tuning), { editing: "", tuning: "" });
+            return;
+        }
+        let proxy: node.Proxy;
+        try {
+            proxy = node.Proxy.createFrom(JSON.parse(this.state.editing));
+        } catch (error) {
+            this.setState({ message: `JSON 格式错误: ${error}` });
+            return;
+        }
+        const exists = this.state.local.some(p => p.id === proxy.id);
+        this.run(() => exists ? EditNode(proxy) : AddNode(proxy), { editing: "" });
+    }
+
+    render() {
+        if (!this.state.open) {
+            return (
+                <button className="nodes-btn" onClick={() => { this.setState({ open: true }); this.refresh(); }}>
+                    导入节点
+                </button>
+            );
+        }
+        const { loading, links, url, message, subscriptions, local, imported, editing, tuning, upstream, routes } = this.state;
+        if (editing || tuning || upstream || routes) {
+            return (
+                <div className="nodes-panel">
+                    <div className="nodes-header">
+                        <span>{tuning ? "节点调优，清空后保存恢复默认" : upstream ? "上游代理，type 为 socks 或 http，留空不使用" : routes ? "下载策略：direct、proxy 或 direct-then-proxy，清空后保存恢复默认" : "本地节点"}</span>
+                        <span className="nodes-close" onClick={() => this.setState({ editing: "", tuning: "", upstream: false, routes: false, message: "" })}>×</span>
+                    </div>
+                    <textarea rows={10} value={editing} onInput={(e: any) => this.setState({ editing: e.target.value })} />
+                    <a onClick={() => this.save()}>保存</a>
+                    {message && <div className="nodes-error">{message}</div>}
+                </div>
+            );
+        }
+        return (
+            <div className="nodes-panel">
+                <div className="nodes-header">
+                    <span>{loading ? "正在导入..." : "分享链接，一行一个"}</span>
+                    <span className="nodes-close" onClick={() => this.setState({ open: false })}>×</span>
+                </div>
+                <textarea rows={3} value={links} onInput={(e: any) => this.setState({ links: e.target.value })} />
+                <a onClick={() => this.run(() => ImportLinks(links), { links: "" })}>导入</a>
+                <input placeholder="订阅地址" value={url} onInput={(e: any) => this.setState({ url: e.target.value })} />
+                <a onClick={() => this.run(() => AddSubscription(url, "", 0), { url: "" })}>添加订阅</a>
+                <a onClick={() => this.run(() => RefreshSubscriptions())}>全部刷新</a>
+                {message && <div className="nodes-error">{message}</div>}
+                <a onClick={() => this.setState({ editing: JSON.stringify(template, null, 2) })}>添加本地节点</a>
+                {this.props.selected?.source && <a onClick={() => this.editTuning(this.props.selected!)}>调优当前节点</a>}
+                <a onClick={() => this.editUpstream()}>上游代理</a>
+                <a onClick={() => this.editRoutes()}>下载策略</a>
+                {local.map(p => (
+                    <div key={p.id} className="nodes-item">
+                        <span>{p.name} {p.host}:{p.port}</span>
+                        <a onClick={() => this.setState({ editing: JSON.stringify(p, null, 2) })}>编辑</a>
+                        <a onClick={() => this.run(() => DeleteNode(p.id))}>删除</a>
+                    </div>
+                ))}
+                {imported.map(p => (
+                    <div key={p.id} className="nodes-item">
+                        <span>{p.name} {p.host}:{p.port}</span>
+                        <a onClick={() => this.run(() => RemoveLink(p.id))}>删除</a>
+                    </div>
+                ))}
+                {subscriptions.map(s => (
+                    <div key={s.url} className="nodes-item" title={s.error || `更新于 ${new Date(s.updatedAt).toLocaleString()}`}>
+                        <span className={s.error ? "nodes-error" : ""}>{s.name || s.url} ({s.nodes?.length ?? 0})</span>
+                        <a onClick={() => this.run(() => RemoveSubscription(s.url))}>删除</a>
+                    </div>
+                ))}
+            </div>
+        );
+    }
+}
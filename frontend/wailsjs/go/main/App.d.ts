@@ -1,12 +1,85 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {node} from '../models';
+import {dhcp} from '../models';
+import {core} from '../models';
+import {lan} from '../models';
+import {http_client} from '../models';
+import {probe} from '../models';
+import {nat} from '../models';
+
+export function AddNode(arg1:node.Proxy):Promise<string>;
+
+export function AddSubscription(arg1:string,arg2:string,arg3:number):Promise<string>;
+
+export function AutoChoice():Promise<node.Quality>;
+
+export function DHCPLeases():Promise<Array<dhcp.Lease>>;
+
+export function DeleteNode(arg1:string):Promise<string>;
+
+export function DeviceTraffic():Promise<Array<core.DeviceTraffic>>;
+
+export function Devices():Promise<Array<lan.Device>>;
+
+export function EditNode(arg1:node.Proxy):Promise<string>;
 
 export function GetAnnouncement():Promise<string>;
 
+export function GetDHCPConfig():Promise<dhcp.Config>;
+
+export function GetDeviceNodes():Promise<Array<lan.Assignment>>;
+
+export function GetDownloadRoutes():Promise<Array<http_client.Route>>;
+
+export function GetProbeTargets():Promise<probe.Targets>;
+
+export function GetTuning(arg1:string):Promise<node.Tuning>;
+
+export function GetUpstream():Promise<node.Upstream>;
+
+export function ImportLinks(arg1:string):Promise<string>;
+
+export function ImportedLinks():Promise<Array<node.Proxy>>;
+
+export function LocalNodes():Promise<Array<node.Proxy>>;
+
+export function NATType(arg1:string):Promise<nat.Result>;
+
+export function NodeCache():Promise<node.CacheInfo>;
+
 export function Open(arg1:string):Promise<void>;
 
-export function ProxyList():Promise<Array<string>>;
+export function ProxyList():Promise<Array<node.Item>>;
+
+export function RankNodes():Promise<node.Ranking>;
+
+export function RefreshNodes():Promise<node.CacheInfo>;
+
+export function RefreshSubscriptions():Promise<string>;
+
+export function RemoveLink(arg1:string):Promise<string>;
+
+export function RemoveSubscription(arg1:string):Promise<string>;
+
+export function SetAllowedDevices(arg1:Array<lan.Device>):Promise<string>;
+
+export function SetDHCPConfig(arg1:dhcp.Config):Promise<string>;
+
+export function SetDeviceNodes(arg1:Array<lan.Assignment>):Promise<string>;
+
+export function SetDownloadRoutes(arg1:Array<http_client.Route>):Promise<string>;
+
+export function SetFavorite(arg1:string,arg2:boolean):Promise<string>;
+
+export function SetProbeTargets(arg1:probe.Targets):Promise<string>;
+
+export function SetTuning(arg1:string,arg2:node.Tuning):Promise<string>;
+
+export function SetUpstream(arg1:node.Upstream):Promise<string>;
+
+export function Subscriptions():Promise<Array<node.Subscription>>;
 
-export function Switch(arg1:boolean,arg2:string,arg3:boolean):Promise<string>;
+export function Switch(arg1:boolean,arg2:string,arg3:boolean):Promise<node.ErrorInfo>;
 
 export function Version():Promise<string>;
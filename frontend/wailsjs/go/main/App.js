@@ -2,10 +2,86 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddNode(arg1) {
+  return window['go']['main']['App']['AddNode'](arg1);
+}
+
+export function AddSubscription(arg1, arg2, arg3) {
+  return window['go']['main']['App']['AddSubscription'](arg1, arg2, arg3);
+}
+
+export function AutoChoice() {
+  return window['go']['main']['App']['AutoChoice']();
+}
+
+export function DHCPLeases() {
+  return window['go']['main']['App']['DHCPLeases']();
+}
+
+export function DeleteNode(arg1) {
+  return window['go']['main']['App']['DeleteNode'](arg1);
+}
+
+export function DeviceTraffic() {
+  return window['go']['main']['App']['DeviceTraffic']();
+}
+
+export function Devices() {
+  return window['go']['main']['App']['Devices']();
+}
+
+export function EditNode(arg1) {
+  return window['go']['main']['App']['EditNode'](arg1);
+}
+
 export function GetAnnouncement() {
   return window['go']['main']['App']['GetAnnouncement']();
 }
 
+export function GetDHCPConfig() {
+  return window['go']['main']['App']['GetDHCPConfig']();
+}
+
+export function GetDeviceNodes() {
+  return window['go']['main']['App']['GetDeviceNodes']();
+}
+
+export function GetDownloadRoutes() {
+  return window['go']['main']['App']['GetDownloadRoutes']();
+}
+
+export function GetProbeTargets() {
+  return window['go']['main']['App']['GetProbeTargets']();
+}
+
+export function GetTuning(arg1) {
+  return window['go']['main']['App']['GetTuning'](arg1);
+}
+
+export function GetUpstream() {
+  return window['go']['main']['App']['GetUpstream']();
+}
+
+export function ImportLinks(arg1) {
+  return window['go']['main']['App']['ImportLinks'](arg1);
+}
+
+export function ImportedLinks() {
+  return window['go']['main']['App']['ImportedLinks']();
+}
+
+export function LocalNodes() {
+  return window['go']['main']['App']['LocalNodes']();
+}
+
+export function NATType(arg1) {
+  return window['go']['main']['App']['NATType'](arg1);
+}
+
+export function NodeCache() {
+  return window['go']['main']['App']['NodeCache']();
+}
+
 export function Open(arg1) {
   return window['go']['main']['App']['Open'](arg1);
 }
@@ -14,6 +90,62 @@ export function ProxyList() {
   return window['go']['main']['App']['ProxyList']();
 }
 
+export function RankNodes() {
+  return window['go']['main']['App']['RankNodes']();
+}
+
+export function RefreshNodes() {
+  return window['go']['main']['App']['RefreshNodes']();
+}
+
+export function RefreshSubscriptions() {
+  return window['go']['main']['App']['RefreshSubscriptions']();
+}
+
+export function RemoveLink(arg1) {
+  return window['go']['main']['App']['RemoveLink'](arg1);
+}
+
+export function RemoveSubscription(arg1) {
+  return window['go']['main']['App']['RemoveSubscription'](arg1);
+}
+
+export function SetAllowedDevices(arg1) {
+  return window['go']['main']['App']['SetAllowedDevices'](arg1);
+}
+
+export function SetDHCPConfig(arg1) {
+  return window['go']['main']['App']['SetDHCPConfig'](arg1);
+}
+
+export function SetDeviceNodes(arg1) {
+  return window['go']['main']['App']['SetDeviceNodes'](arg1);
+}
+
+export function SetDownloadRoutes(arg1) {
+  return window['go']['main']['App']['SetDownloadRoutes'](arg1);
+}
+
+export function SetFavorite(arg1, arg2) {
+  return window['go']['main']['App']['SetFavorite'](arg1, arg2);
+}
+
+export function SetProbeTargets(arg1) {
+  return window['go']['main']['App']['SetProbeTargets'](arg1);
+}
+
+export function SetTuning(arg1, arg2) {
+  return window['go']['main']['App']['SetTuning'](arg1, arg2);
+}
+
+export function SetUpstream(arg1) {
+  return window['go']['main']['App']['SetUpstream'](arg1);
+}
+
+export function Subscriptions() {
+  return window['go']['main']['App']['Subscriptions']();
+}
+
 export function Switch(arg1, arg2, arg3) {
   return window['go']['main']['App']['Switch'](arg1, arg2, arg3);
 }
@@ -0,0 +1,782 @@
+export namespace core {
+	
+	export class DeviceTraffic {
+	    ip: string;
+	    node: string;
+	    upload: number;
+	    download: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeviceTraffic(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ip = source["ip"];
+	        this.node = source["node"];
+	        this.upload = source["upload"];
+	        this.download = source["download"];
+	    }
+	}
+
+}
+
+export namespace dhcp {
+	
+	export class Config {
+	    enabled: boolean;
+	    macs: string[];
+	    pool_start: string;
+	    pool_size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Config(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.macs = source["macs"];
+	        this.pool_start = source["pool_start"];
+	        this.pool_size = source["pool_size"];
+	    }
+	}
+	export class Lease {
+	    mac: string;
+	    ip: string;
+	    hostname: string;
+	    // Go type: time
+	    expire: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Lease(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mac = source["mac"];
+	        this.ip = source["ip"];
+	        this.hostname = source["hostname"];
+	        this.expire = this.convertValues(source["expire"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace http_client {
+	
+	export class Route {
+	    prefix: string;
+	    policy: string;
+	    mirrors?: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Route(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.prefix = source["prefix"];
+	        this.policy = source["policy"];
+	        this.mirrors = source["mirrors"];
+	    }
+	}
+
+}
+
+export namespace lan {
+	
+	export class Assignment {
+	    mac: string;
+	    ip: string;
+	    node: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Assignment(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mac = source["mac"];
+	        this.ip = source["ip"];
+	        this.node = source["node"];
+	    }
+	}
+	export class Device {
+	    ip: string;
+	    mac: string;
+	    vendor: string;
+	    name: string;
+	    allowed: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Device(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ip = source["ip"];
+	        this.mac = source["mac"];
+	        this.vendor = source["vendor"];
+	        this.name = source["name"];
+	        this.allowed = source["allowed"];
+	    }
+	}
+
+}
+
+export namespace nat {
+	
+	export class Result {
+	    type: string;
+	    mapping: string;
+	    filtering: string;
+	    public: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Result(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.mapping = source["mapping"];
+	        this.filtering = source["filtering"];
+	        this.public = source["public"];
+	        this.error = source["error"];
+	    }
+	}
+
+}
+
+export namespace node {
+	
+	export class Brutal {
+	    up_mbps: number;
+	    down_mbps: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Brutal(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.up_mbps = source["up_mbps"];
+	        this.down_mbps = source["down_mbps"];
+	    }
+	}
+	export class CacheInfo {
+	    // Go type: time
+	    updatedAt: any;
+	    stale: boolean;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CacheInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.stale = source["stale"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ErrorInfo {
+	    code: string;
+	    node: string;
+	    message: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ErrorInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.code = source["code"];
+	        this.node = source["node"];
+	        this.message = source["message"];
+	    }
+	}
+	export class Multiplex {
+	    enabled: boolean;
+	    protocol?: string;
+	    max_connections?: number;
+	    min_streams?: number;
+	    max_streams?: number;
+	    padding?: boolean;
+	    brutal?: Brutal;
+	
+	    static createFrom(source: any = {}) {
+	        return new Multiplex(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.protocol = source["protocol"];
+	        this.max_connections = source["max_connections"];
+	        this.min_streams = source["min_streams"];
+	        this.max_streams = source["max_streams"];
+	        this.padding = source["padding"];
+	        this.brutal = this.convertValues(source["brutal"], Brutal);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Tuning {
+	    multiplex?: Multiplex;
+	    tfo?: boolean;
+	    mptcp?: boolean;
+	    uot?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Tuning(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.multiplex = this.convertValues(source["multiplex"], Multiplex);
+	        this.tfo = source["tfo"];
+	        this.mptcp = source["mptcp"];
+	        this.uot = source["uot"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Transport {
+	    type: string;
+	    path?: string;
+	    host?: string;
+	    headers?: Record<string, string>;
+	    service_name?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Transport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.path = source["path"];
+	        this.host = source["host"];
+	        this.headers = source["headers"];
+	        this.service_name = source["service_name"];
+	    }
+	}
+	export class Reality {
+	    public_key: string;
+	    short_id?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Reality(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.public_key = source["public_key"];
+	        this.short_id = source["short_id"];
+	    }
+	}
+	export class TLS {
+	    server_name?: string;
+	    insecure?: boolean;
+	    alpn?: string[];
+	    fingerprint?: string;
+	    reality?: Reality;
+	
+	    static createFrom(source: any = {}) {
+	        return new TLS(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.server_name = source["server_name"];
+	        this.insecure = source["insecure"];
+	        this.alpn = source["alpn"];
+	        this.fingerprint = source["fingerprint"];
+	        this.reality = this.convertValues(source["reality"], Reality);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Item {
+	    id?: string;
+	    name: string;
+	    method: string;
+	    password: string;
+	    host: string;
+	    port: number;
+	    protocol: string;
+	    region?: string;
+	    source?: string;
+	    probe?: probe.Config;
+	    tags?: string[];
+	    load?: number;
+	    multiplier?: number;
+	    maintenance?: boolean;
+	    uuid?: string;
+	    security?: string;
+	    alter_id?: number;
+	    up_mbps?: number;
+	    down_mbps?: number;
+	    obfs?: string;
+	    congestion_control?: string;
+	    tls?: TLS;
+	    flow?: string;
+	    transport?: Transport;
+	    tuning?: Tuning;
+	    plugin?: string;
+	    plugin_opts?: string;
+	    detour?: string;
+	    username?: string;
+	    private_key?: string;
+	    public_key?: string;
+	    pre_shared_key?: string;
+	    address?: string[];
+	    allowed_ips?: string[];
+	    reserved?: number[];
+	    mtu?: number;
+	    favorite: boolean;
+	    recent: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Item(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.method = source["method"];
+	        this.password = source["password"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.protocol = source["protocol"];
+	        this.region = source["region"];
+	        this.source = source["source"];
+	        this.probe = this.convertValues(source["probe"], probe.Config);
+	        this.tags = source["tags"];
+	        this.load = source["load"];
+	        this.multiplier = source["multiplier"];
+	        this.maintenance = source["maintenance"];
+	        this.uuid = source["uuid"];
+	        this.security = source["security"];
+	        this.alter_id = source["alter_id"];
+	        this.up_mbps = source["up_mbps"];
+	        this.down_mbps = source["down_mbps"];
+	        this.obfs = source["obfs"];
+	        this.congestion_control = source["congestion_control"];
+	        this.tls = this.convertValues(source["tls"], TLS);
+	        this.flow = source["flow"];
+	        this.transport = this.convertValues(source["transport"], Transport);
+	        this.tuning = this.convertValues(source["tuning"], Tuning);
+	        this.plugin = source["plugin"];
+	        this.plugin_opts = source["plugin_opts"];
+	        this.detour = source["detour"];
+	        this.username = source["username"];
+	        this.private_key = source["private_key"];
+	        this.public_key = source["public_key"];
+	        this.pre_shared_key = source["pre_shared_key"];
+	        this.address = source["address"];
+	        this.allowed_ips = source["allowed_ips"];
+	        this.reserved = source["reserved"];
+	        this.mtu = source["mtu"];
+	        this.favorite = source["favorite"];
+	        this.recent = source["recent"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Proxy {
+	    id?: string;
+	    name: string;
+	    method: string;
+	    password: string;
+	    host: string;
+	    port: number;
+	    protocol: string;
+	    region?: string;
+	    source?: string;
+	    probe?: probe.Config;
+	    tags?: string[];
+	    load?: number;
+	    multiplier?: number;
+	    maintenance?: boolean;
+	    uuid?: string;
+	    security?: string;
+	    alter_id?: number;
+	    up_mbps?: number;
+	    down_mbps?: number;
+	    obfs?: string;
+	    congestion_control?: string;
+	    tls?: TLS;
+	    flow?: string;
+	    transport?: Transport;
+	    tuning?: Tuning;
+	    plugin?: string;
+	    plugin_opts?: string;
+	    detour?: string;
+	    username?: string;
+	    private_key?: string;
+	    public_key?: string;
+	    pre_shared_key?: string;
+	    address?: string[];
+	    allowed_ips?: string[];
+	    reserved?: number[];
+	    mtu?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Proxy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.method = source["method"];
+	        this.password = source["password"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.protocol = source["protocol"];
+	        this.region = source["region"];
+	        this.source = source["source"];
+	        this.probe = this.convertValues(source["probe"], probe.Config);
+	        this.tags = source["tags"];
+	        this.load = source["load"];
+	        this.multiplier = source["multiplier"];
+	        this.maintenance = source["maintenance"];
+	        this.uuid = source["uuid"];
+	        this.security = source["security"];
+	        this.alter_id = source["alter_id"];
+	        this.up_mbps = source["up_mbps"];
+	        this.down_mbps = source["down_mbps"];
+	        this.obfs = source["obfs"];
+	        this.congestion_control = source["congestion_control"];
+	        this.tls = this.convertValues(source["tls"], TLS);
+	        this.flow = source["flow"];
+	        this.transport = this.convertValues(source["transport"], Transport);
+	        this.tuning = this.convertValues(source["tuning"], Tuning);
+	        this.plugin = source["plugin"];
+	        this.plugin_opts = source["plugin_opts"];
+	        this.detour = source["detour"];
+	        this.username = source["username"];
+	        this.private_key = source["private_key"];
+	        this.public_key = source["public_key"];
+	        this.pre_shared_key = source["pre_shared_key"];
+	        this.address = source["address"];
+	        this.allowed_ips = source["allowed_ips"];
+	        this.reserved = source["reserved"];
+	        this.mtu = source["mtu"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Quality {
+	    id: string;
+	    name: string;
+	    region: string;
+	    latency: number;
+	    jitter: number;
+	    loss: number;
+	    score: number;
+	    error: string;
+	    errorKind: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Quality(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.region = source["region"];
+	        this.latency = source["latency"];
+	        this.jitter = source["jitter"];
+	        this.loss = source["loss"];
+	        this.score = source["score"];
+	        this.error = source["error"];
+	        this.errorKind = source["errorKind"];
+	    }
+	}
+	export class Ranking {
+	    nodes: Quality[];
+	    // Go type: time
+	    updatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Ranking(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.nodes = this.convertValues(source["nodes"], Quality);
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class Subscription {
+	    url: string;
+	    name: string;
+	    interval: number;
+	    // Go type: time
+	    updatedAt: any;
+	    error: string;
+	    nodes: Proxy[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Subscription(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.name = source["name"];
+	        this.interval = source["interval"];
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	        this.error = source["error"];
+	        this.nodes = this.convertValues(source["nodes"], Proxy);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	
+	
+	export class Upstream {
+	    type: string;
+	    host: string;
+	    port: number;
+	    username: string;
+	    password: string;
+	    allNodes: boolean;
+	    controlPlane: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Upstream(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.allNodes = source["allNodes"];
+	        this.controlPlane = source["controlPlane"];
+	    }
+	}
+
+}
+
+export namespace probe {
+	
+	export class Config {
+	    type: string;
+	    target: string;
+	    server_name?: string;
+	    insecure?: boolean;
+	    method?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Config(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.target = source["target"];
+	        this.server_name = source["server_name"];
+	        this.insecure = source["insecure"];
+	        this.method = source["method"];
+	    }
+	}
+	export class Targets {
+	    game: string;
+	    games: Record<string, Config>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Targets(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.game = source["game"];
+	        this.games = this.convertValues(source["games"], Config, true);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
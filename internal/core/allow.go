@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+// allowedTag 允许使用网关的来源地址规则集
+const allowedTag = "allowed-sources"
+
+func allowedFile() string {
+	return filepath.Join(path.Path(), "allowed-sources.json")
+}
+
+// allowedSources 允许使用网关的来源网段，为空表示不限制
+// 本机流量来自 tun 地址和回环地址，始终放行
+func allowedSources(ips []string) []string {
+	if len(ips) == 0 {
+		return []string{"0.0.0.0/0", "::/0"}
+	}
+	sources := []string{"172.25.0.0/30", "127.0.0.0/8"}
+	for _, ip := range ips {
+		sources = append(sources, ip+"/32")
+	}
+	return sources
+}
+
+// writeAllowed 以规则集的形式写入允许的来源地址，运行中 sing-box 检测到文件变化后重新加载，无需重启
+func writeAllowed(ips []string) error {
+	data, err := json.MarshalIndent(map[string]any{
+		"version": constant.RuleSetVersion3,
+		"rules": []map[string]any{
+			{"source_ip_cidr": allowedSources(ips)},
+		},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(allowedFile(), data, 0644)
+}
+
+// withAllowed 网关模式下拒绝不在规则集中的来源，仅允许指定设备使用网关
+func withAllowed(options *option.RouteOptions) {
+	options.RuleSet = append(options.RuleSet, option.RuleSet{
+		Type:         constant.RuleSetTypeLocal,
+		Tag:          allowedTag,
+		Format:       constant.RuleSetFormatSource,
+		LocalOptions: option.LocalRuleSet{Path: allowedFile()},
+	})
+	options.Rules = append(options.Rules, option.Rule{
+		Type: constant.RuleTypeDefault,
+		DefaultOptions: option.DefaultRule{
+			RawDefaultRule: option.RawDefaultRule{
+				RuleSet: []string{allowedTag},
+				Invert:  true,
+			},
+			RuleAction: option.RuleAction{
+				Action: constant.RuleActionTypeReject,
+				RejectOptions: option.RejectActionOptions{
+					Method: constant.RuleActionRejectMethodDefault,
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+)
+
+func TestSetAllowedSources(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	b := &Box{}
+	read := func() []string {
+		data, err := os.ReadFile(allowedFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ruleSet, err := json.UnmarshalExtended[option.PlainRuleSetCompat](data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules := ruleSet.Options.Rules
+		if len(rules) != 1 {
+			t.Fatalf("unexpected rules %+v", rules)
+		}
+		return rules[0].DefaultOptions.SourceIPCIDR
+	}
+	// 运行中修改允许的设备时直接改写规则集文件
+	b.SetAllowedSources([]string{"192.168.1.20"})
+	if got, want := read(), []string{"172.25.0.0/30", "127.0.0.0/8", "192.168.1.20/32"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sources %v, want %v", got, want)
+	}
+	b.SetAllowedSources(nil)
+	if got, want := read(), []string{"0.0.0.0/0", "::/0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sources %v, want %v", got, want)
+	}
+
+	options := option.RouteOptions{}
+	withAllowed(&options)
+	if len(options.RuleSet) != 1 || options.RuleSet[0].LocalOptions.Path != allowedFile() {
+		t.Errorf("unexpected rule sets %+v", options.RuleSet)
+	}
+	if rule := options.Rules[0].DefaultOptions; !rule.Invert || !reflect.DeepEqual([]string(rule.RuleSet), []string{allowedTag}) {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"log"
+	"playfast/internal/node"
+	"time"
+
+	box "github.com/sagernet/sing-box"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/control"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	// autoMaxAge 启动时可直接使用的节点排序结果的最长时间
+	autoMaxAge = time.Minute
+	// autoDebounce 网络变化后等待网络稳定再重新评估
+	autoDebounce = 5 * time.Second
+	// autoSwitchRatio 新节点得分低于当前节点的该比例时才切换，避免频繁重连
+	autoSwitchRatio = 0.8
+)
+
+// SetRanker 设置智能选择使用的节点排序器，onChoice 在每次选定节点后调用
+// onFailure 在网络变化后切换节点失败时调用，stopped 表示恢复原节点也失败，加速已停止
+func (b *Box) SetRanker(ranker *node.Ranker, onChoice func(node.Quality), onFailure func(err error, stopped bool)) {
+	b.Lock()
+	defer b.Unlock()
+	b.ranker = ranker
+	b.onChoice = onChoice
+	b.onFailure = onFailure
+}
+
+// Choice 返回智能选择当前选中的节点，未使用智能选择时返回 nil
+func (b *Box) Choice() *node.Quality {
+	b.Lock()
+	defer b.Unlock()
+	if b.auto == "" {
+		return nil
+	}
+	choice := b.choice
+	return &choice
+}
+
+// NetworkContext 加速中为 ctx 带上加速器的 NetworkManager，出站会绑定物理网卡，不经过 tun
+func (b *Box) NetworkContext(ctx context.Context) context.Context {
+	if running := b.running.Load(); running != nil {
+		return service.ContextWith[adapter.NetworkManager](ctx, running.Network())
+	}
+	return ctx
+}
+
+// prepare 使用智能选择时等待节点排序结果就绪，从未探测过时需要探测所有节点，调用时不能持有锁
+func (b *Box) prepare(region string) {
+	b.Lock()
+	if b.ranker == nil {
+		b.ranker = node.NewRanker()
+	}
+	ranker := b.ranker
+	names := []string{region}
+	for _, name := range b.nodes {
+		names = append(names, name)
+	}
+	b.Unlock()
+	for _, name := range names {
+		if _, ok := node.ParseAuto(name); ok {
+			// 有缓存时直接使用并在后台刷新，只有从未探测过时才等待
+			ranker.Ready(b.ctx, autoMaxAge)
+			return
+		}
+	}
+}
+
+// resolve 将智能选择解析为得分最优的节点，普通节点原样返回且 auto 为 false
+// 只使用 prepare 准备好的缓存，不会探测节点，调用时需持有锁
+func (b *Box) resolve(name string) (q node.Quality, auto bool, err error) {
+	region, ok := node.ParseAuto(name)
+	if !ok {
+		return node.Quality{ID: name, Name: name}, false, nil
+	}
+	if b.ranker == nil {
+		b.ranker = node.NewRanker()
+	}
+	q, err = node.Best(b.ranker.Cached(), region)
+	return q, true, err
+}
+
+func (b *Box) choose(q node.Quality) {
+	b.choice = q
+	log.Printf("智能选择: 节点 %s 延迟=%dms 抖动=%dms 丢包=%.0f%% 得分=%.1f\n", q.Name, q.Latency, q.Jitter, q.Loss, q.Score)
+	if b.onChoice != nil {
+		go b.onChoice(q)
+	}
+}
+
+// watch 在网络变化时重新评估智能选择，调用时需持有锁
+func (b *Box) watch() {
+	monitor := b.box.Network().InterfaceMonitor()
+	if monitor == nil {
+		return
+	}
+	current := b.box
+	b.autoMu.Lock()
+	b.watched = current
+	b.autoMu.Unlock()
+	monitor.RegisterCallback(func(*control.Interface, int) {
+		// 回调不能等待 b 的锁，关闭实例时会在持有锁的情况下注销回调
+		b.autoMu.Lock()
+		defer b.autoMu.Unlock()
+		if b.watched != current {
+			return
+		}
+		if b.autoTimer != nil {
+			b.autoTimer.Stop()
+		}
+		b.autoTimer = time.AfterFunc(autoDebounce, func() {
+			b.reevaluate(current)
+		})
+	})
+}
+
+// unwatch 停止重新评估，调用时需持有锁
+func (b *Box) unwatch() {
+	b.autoMu.Lock()
+	defer b.autoMu.Unlock()
+	b.watched = nil
+	if b.autoTimer != nil {
+		b.autoTimer.Stop()
+		b.autoTimer = nil
+	}
+}
+
+// reevaluate 重新探测所有节点，明显更优时切换到新节点
+func (b *Box) reevaluate(current *box.Box) {
+	b.Lock()
+	if b.box == nil || b.box != current || b.auto == "" {
+		b.Unlock()
+		return
+	}
+	auto, router, previous := b.auto, b.router, b.choice
+	// 带上加速器的 NetworkManager，使探测绕过 tun 直接走物理网卡
+	ctx := service.ContextWith[adapter.NetworkManager](b.ctx, b.box.Network())
+	b.Unlock()
+
+	region, _ := node.ParseAuto(auto)
+	ranking := b.ranker.Refresh(ctx, node.Get(ctx))
+	best, err := node.Best(ranking, region)
+	if err != nil || best.ID == previous.ID {
+		return
+	}
+	for _, q := range ranking.Nodes {
+		if q.ID == previous.ID && q.Error == "" && best.Score >= q.Score*autoSwitchRatio {
+			return
+		}
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	// 探测期间用户可能已经停止或切换了节点
+	if b.box != current {
+		return
+	}
+	log.Printf("智能选择: 网络变化后切换节点 %s -> %s\n", previous.Name, best.Name)
+	if err = b.stop(); err != nil {
+		log.Println("智能选择: 停止失败:", err)
+	}
+	if err = b.start(auto, best, router); err == nil {
+		return
+	}
+	log.Println("智能选择: 切换失败:", err)
+	// 切换失败时恢复原来的节点，恢复也失败时加速已停止
+	_ = b.stop()
+	restoreErr := b.start(auto, previous, router)
+	if restoreErr != nil {
+		log.Println("智能选择: 恢复节点失败:", restoreErr)
+	}
+	if b.onFailure != nil {
+		go b.onFailure(err, restoreErr != nil)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"log"
 	"net"
 	"net/netip"
 	"os"
@@ -13,7 +14,9 @@ import (
 	"playfast/internal/node"
 	"playfast/internal/path"
 	"playfast/utils"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	box "github.com/sagernet/sing-box"
@@ -23,6 +26,7 @@ import (
 	slog "github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
 	dns "github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/json/badoption"
 	"github.com/sagernet/sing/service"
 )
@@ -45,18 +49,77 @@ type Box struct {
 	router           bool
 	appends          []string
 	defaultInterface int
+	progress         RouteProgress
+	lanDNS           bool
+	allowed          []string
+	nodes            map[string]string
+	region           string
+	traffic          *trafficTracker
+	ranker           *node.Ranker
+	onChoice         func(node.Quality)
+	onFailure        func(err error, stopped bool)
+	autoMu           sync.Mutex              // 保护 watched 和 autoTimer，网络变化回调中不能使用 b 的锁
+	watched          *box.Box                // 正在监听网络变化的实例
+	autoTimer        *time.Timer             // 网络变化后延迟重新评估的定时器
+	auto             string                  // 使用智能选择时为选择的虚拟节点名称
+	choice           node.Quality            // 智能选择选中的节点
+	running          atomic.Pointer[box.Box] // 已启动的实例，控制面请求不持有锁读取
 	sync.Mutex
 }
 
+// SetAllowedSources 设置网关模式下允许使用网关的局域网设备 IP，为空表示不限制
+// 运行中修改时立即生效
+func (b *Box) SetAllowedSources(ips []string) {
+	b.Lock()
+	defer b.Unlock()
+	b.allowed = ips
+	if err := writeAllowed(ips); err != nil {
+		log.Println("写入允许的设备失败:", err)
+	}
+}
+
+// SetLanDNS 设置是否在本机 53 端口为局域网设备提供 DNS，供 DHCP 下发本机为 DNS 时使用
+func (b *Box) SetLanDNS(enable bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.lanDNS = enable
+}
+
+// SetRouteProgress 设置绕行路由写入进度回调
+func (b *Box) SetRouteProgress(progress RouteProgress) {
+	b.Lock()
+	defer b.Unlock()
+	b.progress = progress
+}
+
 func (b *Box) Start(region string, router bool) error {
+	// 探测节点耗时较长，在加锁前完成
+	b.prepare(region)
 	b.Lock()
 	defer b.Unlock()
+	var q node.Quality
+	if b.box == nil {
+		var err error
+		if q, _, err = b.resolve(region); err != nil {
+			return err
+		}
+	}
+	return b.start(region, q, router)
+}
+
+// start 使用解析后的节点 q 启动加速，region 为智能选择时记录 q 为选中的节点，调用时需持有锁
+func (b *Box) start(region string, q node.Quality, router bool) error {
 	b.router = router
 	if b.box == nil {
-		err := b.newBox(region)
+		b.auto = ""
+		err := b.newBox(q.ID)
 		if err != nil {
 			return err
 		}
+		if _, auto := node.ParseAuto(region); auto {
+			b.auto = region
+			b.choose(q)
+		}
 	}
 	err := b.box.Start()
 	if err != nil {
@@ -71,23 +134,44 @@ func (b *Box) Start(region string, router bool) error {
 		b.defaultInterface = defaultInterface.Index
 		err = utils.SetIPForwarding(b.defaultInterface, true)
 	}
-	return route(b.appends)
+	err = route(b.appends, b.progress)
+	if err != nil {
+		_ = b.box.Close()
+		b.box = nil
+		if router {
+			_ = utils.SetIPForwarding(b.defaultInterface, false)
+		}
+		return err
+	}
+	b.running.Store(b.box)
+	if b.auto != "" {
+		b.watch()
+	}
+	return nil
 }
 func (b *Box) Stop() error {
 	b.Lock()
 	defer b.Unlock()
+	return b.stop()
+}
+
+// stop 停止加速，调用时需持有锁
+func (b *Box) stop() error {
 	if b.box == nil {
 		return nil
 	}
+	b.unwatch()
+	b.running.Store(nil)
 	err := b.box.Close()
 	b.box = nil
+	b.auto = ""
 	if b.router {
 		err = utils.SetIPForwarding(b.defaultInterface, false)
 		if err != nil {
 			return err
 		}
 	}
-	deleteRoute(b.appends)
+	deleteRoute()
 	return err
 }
 func New(ctx context.Context) *Box {
@@ -101,52 +185,53 @@ func New(ctx context.Context) *Box {
 		ctx:     ctx,
 		appends: []string{},
 	}
+	httpclient.SetProxyDialer(b.dialProxy)
 	go b.update()
 	return &b
 }
 func (b *Box) update() {
-	var data []byte
-	data, err := httpclient.GET(fmt.Sprintf("%s/black-list.json", api.GetApiDomain()))
-	if err != nil {
-		data = black
-	}
-	b.Lock()
-	_ = os.WriteFile(filepath.Join(path.Path(), "black-list.json"), data, 0644)
-	b.Unlock()
-	data, err = httpclient.GET(fmt.Sprintf("%s/direct-list.json", api.GetApiDomain()))
-	if err != nil {
-		data = direct
-	}
-	b.Lock()
-	_ = os.WriteFile(filepath.Join(path.Path(), "direct-list.json"), data, 0644)
-	b.Unlock()
-	data, err = httpclient.GET("https://raw.githubusercontent.com/lyc8503/sing-box-rules/refs/heads/rule-set-geoip/geoip-cn.srs")
-	if err != nil {
-		data = geoip
-	}
-	b.Lock()
-	_ = os.WriteFile(filepath.Join(path.Path(), "geoip-cn.srs"), data, 0644)
-	b.Unlock()
-	data, err = httpclient.GET("https://raw.githubusercontent.com/lyc8503/sing-box-rules/refs/heads/rule-set-geosite/geosite-cn.srs")
-	if err != nil {
-		data = geosite
+	b.updateRule(fmt.Sprintf("%s/black-list.json", api.GetApiDomain()), "black-list.json", black)
+	b.updateRule(fmt.Sprintf("%s/direct-list.json", api.GetApiDomain()), "direct-list.json", direct)
+	b.updateRule("https://raw.githubusercontent.com/lyc8503/sing-box-rules/refs/heads/rule-set-geoip/geoip-cn.srs", "geoip-cn.srs", geoip)
+	b.updateRule("https://raw.githubusercontent.com/lyc8503/sing-box-rules/refs/heads/rule-set-geosite/geosite-cn.srs", "geosite-cn.srs", geosite)
+}
+
+// updateRule 下载规则文件，失败时保留磁盘上已有的文件，没有文件时写入内置规则
+func (b *Box) updateRule(target, name string, embedded []byte) {
+	file := filepath.Join(path.Path(), name)
+	data, err := httpclient.GetCached(b.ctx, target)
+	if data == nil {
+		if _, statErr := os.Stat(file); statErr == nil {
+			log.Println("下载规则失败，使用上次下载的规则:", err)
+			return
+		}
+		log.Println("下载规则失败，使用内置规则:", err)
+		data = embedded
 	}
 	b.Lock()
-	_ = os.WriteFile(filepath.Join(path.Path(), "geosite-cn.srs"), data, 0644)
+	_ = os.WriteFile(file, data, 0644)
 	b.Unlock()
 }
 
 func (b *Box) newBox(proxy string) error {
-	proxyOutbound, proxyOutboundHost, err := node.GetOutbound(proxy)
+	chain, err := node.GetOutbound(b.ctx, proxy, "proxy")
 	if err != nil {
 		return err
 	}
 	b.appends = make([]string, 0)
-	proxyOutboundIp, err := utils.GetIPsFromString(proxyOutboundHost)
+	// 节点使用前置节点或上游代理时，直连的是链路的第一跳
+	prefix, err := bypass(chain.Host)
+	if err != nil {
+		return err
+	}
+	if prefix != "" {
+		b.appends = append(b.appends, prefix)
+	}
+	hosts := []string{chain.Host}
+	devices, deviceRules, err := b.deviceRoutes(proxy, &hosts)
 	if err != nil {
 		return err
 	}
-	b.appends = append(b.appends, fmt.Sprintf("%s/32", proxyOutboundIp))
 	options := box.Options{
 		Options: option.Options{
 			Log: &option.LogOptions{
@@ -210,7 +295,7 @@ func (b *Box) newBox(proxy string) error {
 							Type: constant.RuleTypeDefault,
 							DefaultOptions: option.DefaultDNSRule{
 								RawDefaultDNSRule: option.RawDefaultDNSRule{
-									Domain: []string{proxyOutboundHost},
+									Domain: hosts,
 								},
 								DNSRuleAction: option.DNSRuleAction{
 									Action: constant.RuleActionTypeRoute,
@@ -238,9 +323,6 @@ func (b *Box) newBox(proxy string) error {
 						Address: badoption.Listable[netip.Prefix]{
 							netip.MustParsePrefix("172.25.0.0/30"),
 						},
-						//RouteAddress: in(),
-						//AutoRoute:    true,
-						//StrictRoute:  true,
 						UDPTimeout: option.UDPTimeoutCompat(time.Second * 300),
 						Stack:      "gvisor",
 					},
@@ -276,9 +358,8 @@ func (b *Box) newBox(proxy string) error {
 				AutoDetectInterface: true,
 				Rules:               []option.Rule{},
 			},
-			Outbounds: []option.Outbound{
-				*proxyOutbound, {Type: constant.TypeDirect, Tag: "direct"},
-			},
+			Outbounds: append(chain.Outbounds, option.Outbound{Type: constant.TypeDirect, Tag: "direct"}),
+			Endpoints: chain.Endpoints,
 			Experimental: &option.ExperimentalOptions{
 				ClashAPI: &option.ClashAPIOptions{
 					ExternalController: "127.0.0.1:54713",
@@ -287,6 +368,26 @@ func (b *Box) newBox(proxy string) error {
 		},
 		Context: b.ctx,
 	}
+	if b.lanDNS {
+		options.Options.Inbounds = append(options.Options.Inbounds, option.Inbound{
+			Type: constant.TypeDirect,
+			Tag:  "dns-in",
+			Options: &option.DirectInboundOptions{
+				ListenOptions: option.ListenOptions{
+					Listen:     common.Ptr(badoption.Addr(netip.IPv4Unspecified())),
+					ListenPort: 53,
+				},
+				Network: option.NetworkList("udp"),
+			},
+		})
+	}
+	if b.router {
+		if err = writeAllowed(b.allowed); err != nil {
+			return err
+		}
+		withAllowed(options.Options.Route)
+	}
+	tunRoute(options.Options.Inbounds[0].Options.(*option.TunInboundOptions), b.appends)
 	options.Options.Route.Rules = append(options.Options.Route.Rules, []option.Rule{
 		{
 			Type: constant.RuleTypeDefault,
@@ -395,6 +496,7 @@ func (b *Box) newBox(proxy string) error {
 			},
 		}, //最终代理
 	}...)
+	withDeviceRoutes(&options.Options, devices, deviceRules)
 	_ = os.Remove(path.Path() + "/run.log")
 	options.Log = &option.LogOptions{
 		Disabled:     false,
@@ -404,5 +506,104 @@ func (b *Box) newBox(proxy string) error {
 		DisableColor: true,
 	}
 	b.box, err = box.New(options)
-	return err
+	if err != nil {
+		return err
+	}
+	b.region = proxy
+	b.traffic = newTrafficTracker()
+	b.box.Router().AppendTracker(b.traffic)
+	return nil
+}
+
+// withDeviceRoutes 将按设备分流的规则插入最终代理之前，设备的出站追加在默认出站之后
+func withDeviceRoutes(options *option.Options, devices node.Chain, deviceRules []option.Rule) {
+	last := len(options.Route.Rules) - 1
+	options.Route.Rules = append(options.Route.Rules[:last], append(deviceRules, options.Route.Rules[last])...)
+	options.Outbounds = append(options.Outbounds, devices.Outbounds...)
+	options.Endpoints = append(options.Endpoints, devices.Endpoints...)
+}
+
+// getOutbound 探测并生成设备节点的出站，测试时替换
+var getOutbound = node.GetOutbound
+
+// deviceRoutes 为网关模式下指定了其他节点的设备生成出站和按来源 IP 分流的规则
+// 返回的 Chain 包含所有设备节点的出站和端点
+func (b *Box) deviceRoutes(proxy string, hosts *[]string) (node.Chain, []option.Rule, error) {
+	devices := node.Chain{}
+	rules := make([]option.Rule, 0)
+	if !b.router || len(b.nodes) == 0 {
+		return devices, rules, nil
+	}
+	ips := make([]string, 0, len(b.nodes))
+	for ip := range b.nodes {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	tags := make(map[string]string)
+	sources := make(map[string]badoption.Listable[string])
+	order := make([]string, 0)
+	for _, ip := range ips {
+		name := b.nodes[ip]
+		if name == proxy {
+			continue
+		}
+		if _, ok := tags[name]; !ok {
+			resolved, _, err := b.resolve(name)
+			if err != nil {
+				return node.Chain{}, nil, fmt.Errorf("设备 %s 的节点 %s 不可用: %w", ip, name, err)
+			}
+			tag := fmt.Sprintf("proxy-%d", len(tags)+1)
+			chain, err := getOutbound(b.ctx, resolved.ID, tag)
+			if err != nil {
+				return node.Chain{}, nil, fmt.Errorf("设备 %s 的节点 %s 不可用: %w", ip, name, err)
+			}
+			prefix, err := bypass(chain.Host)
+			if err != nil {
+				return node.Chain{}, nil, err
+			}
+			tags[name] = tag
+			order = append(order, tag)
+			devices.Outbounds = append(devices.Outbounds, chain.Outbounds...)
+			devices.Endpoints = append(devices.Endpoints, chain.Endpoints...)
+			if prefix != "" {
+				b.appends = append(b.appends, prefix)
+			}
+			*hosts = append(*hosts, chain.Host)
+		}
+		sources[tags[name]] = append(sources[tags[name]], ip+"/32")
+	}
+	for _, tag := range order {
+		rules = append(rules, option.Rule{
+			Type: constant.RuleTypeDefault,
+			DefaultOptions: option.DefaultRule{
+				RawDefaultRule: option.RawDefaultRule{
+					SourceIPCIDR: sources[tag],
+				},
+				RuleAction: option.RuleAction{
+					Action: constant.RuleActionTypeRoute,
+					RouteOptions: option.RouteActionOptions{
+						Outbound: tag,
+					},
+				},
+			},
+		})
+	}
+	return devices, rules, nil
+}
+
+// SetDeviceNodes 设置网关模式下各设备 IP 使用的节点名称，未设置的设备使用默认节点
+func (b *Box) SetDeviceNodes(nodes map[string]string) {
+	b.Lock()
+	defer b.Unlock()
+	b.nodes = nodes
+}
+
+// Traffic 返回本次加速各设备的流量
+func (b *Box) Traffic() []DeviceTraffic {
+	b.Lock()
+	defer b.Unlock()
+	if b.traffic == nil {
+		return []DeviceTraffic{}
+	}
+	return b.traffic.snapshot(b.nodes, b.region)
 }
@@ -2,15 +2,191 @@ package core
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"playfast/internal/echo"
+	"playfast/internal/node"
+	"playfast/internal/path"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
 )
 
+// TestBox 需要管理员权限和可用节点，通过 PLAYFAST_TEST_NODE 指定节点名称，
+// PLAYFAST_TEST_ECHO 可指定部署在节点旁的 echo-server 地址用于验证转发
 func TestBox(t *testing.T) {
+	proxy := os.Getenv("PLAYFAST_TEST_NODE")
+	if proxy == "" {
+		t.Skip("PLAYFAST_TEST_NODE not set")
+	}
 	box := New(context.Background())
-	err := box.Start("香港(多线)", false)
+	err := box.Start(proxy, false)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 	defer func() { _ = box.Stop() }()
-	select {}
+	addr := os.Getenv("PLAYFAST_TEST_ECHO")
+	if addr == "" {
+		return
+	}
+	for _, network := range []string{"tcp", "udp"} {
+		client := echo.NewClient(addr, echo.WithNetwork(network), echo.WithTimeout(3*time.Second))
+		if err = client.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		client.MultiTest(context.Background(), []byte("playfast"), 10, 100*time.Millisecond)
+		_ = client.Close()
+		report := client.GetStatsReport()
+		t.Logf("%s %s", network, report)
+		if report.Received == 0 {
+			t.Errorf("%s: no echo received", network)
+		}
+	}
+}
+
+// stubOutbound 替换设备节点的探测，hk 节点经过一个前置节点
+func stubOutbound(t *testing.T) {
+	hosts := map[string]string{"hk": "10.0.0.1", "jp": "10.0.0.2"}
+	getOutbound = func(_ context.Context, id, tag string) (*node.Chain, error) {
+		host, ok := hosts[id]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		chain := &node.Chain{Host: host, Outbounds: []option.Outbound{{Type: constant.TypeSOCKS, Tag: tag}}}
+		if id == "hk" {
+			chain.Outbounds = append(chain.Outbounds, option.Outbound{Type: constant.TypeSOCKS, Tag: tag + "-detour-1"})
+		}
+		return chain, nil
+	}
+	t.Cleanup(func() { getOutbound = node.GetOutbound })
+}
+
+func outboundTags(outbounds []option.Outbound) []string {
+	tags := make([]string, 0, len(outbounds))
+	for _, out := range outbounds {
+		tags = append(tags, out.Tag)
+	}
+	return tags
+}
+
+func TestDeviceRoutes(t *testing.T) {
+	stubOutbound(t)
+	b := &Box{ctx: context.Background(), router: true, nodes: map[string]string{
+		"192.168.1.22": "hk",
+		"192.168.1.21": "jp",
+		"192.168.1.20": "hk",
+		"192.168.1.23": "default",
+	}}
+	hosts := []string{"default.example.com"}
+	devices, rules, err := b.deviceRoutes("default", &hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按设备 IP 排序后依次编号，使用默认节点的设备不生成规则
+	if got, want := outboundTags(devices.Outbounds), []string{"proxy-1", "proxy-1-detour-1", "proxy-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outbounds: got %v, want %v", got, want)
+	}
+	want := map[string][]string{"proxy-1": {"192.168.1.20/32", "192.168.1.22/32"}, "proxy-2": {"192.168.1.21/32"}}
+	if len(rules) != len(want) {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+	for i, tag := range []string{"proxy-1", "proxy-2"} {
+		rule := rules[i].DefaultOptions
+		if rule.Action != constant.RuleActionTypeRoute || rule.RouteOptions.Outbound != tag {
+			t.Errorf("rule %d: unexpected action %s -> %s", i, rule.Action, rule.RouteOptions.Outbound)
+		}
+		if got := []string(rule.SourceIPCIDR); !reflect.DeepEqual(got, want[tag]) {
+			t.Errorf("rule %d: got sources %v, want %v", i, got, want[tag])
+		}
+	}
+	if !reflect.DeepEqual(hosts, []string{"default.example.com", "10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if !reflect.DeepEqual(b.appends, []string{"10.0.0.1/32", "10.0.0.2/32"}) {
+		t.Errorf("unexpected bypass routes %v", b.appends)
+	}
+
+	b = &Box{ctx: context.Background(), nodes: map[string]string{"192.168.1.20": "hk"}}
+	if devices, rules, err = b.deviceRoutes("default", &hosts); err != nil || len(devices.Outbounds) != 0 || len(rules) != 0 {
+		t.Errorf("device routes without gateway mode: %v %v %v", devices, rules, err)
+	}
+	b = &Box{ctx: context.Background(), router: true, nodes: map[string]string{"192.168.1.20": "us"}}
+	if _, _, err = b.deviceRoutes("default", &hosts); err == nil {
+		t.Error("expected error for unavailable device node")
+	}
+}
+
+func TestWithDeviceRoutes(t *testing.T) {
+	stubOutbound(t)
+	b := &Box{ctx: context.Background(), router: true, nodes: map[string]string{"192.168.1.20": "hk", "192.168.1.21": "jp"}}
+	hosts := make([]string, 0)
+	devices, deviceRules, err := b.deviceRoutes("default", &hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sniff := option.Rule{Type: constant.RuleTypeDefault, DefaultOptions: option.DefaultRule{RuleAction: option.RuleAction{Action: constant.RuleActionTypeSniff}}}
+	final := option.Rule{Type: constant.RuleTypeDefault, DefaultOptions: option.DefaultRule{
+		RawDefaultRule: option.RawDefaultRule{Invert: true},
+		RuleAction:     option.RuleAction{Action: constant.RuleActionTypeRoute, RouteOptions: option.RouteActionOptions{Outbound: "proxy"}},
+	}}
+	options := option.Options{
+		Route:     &option.RouteOptions{Rules: []option.Rule{sniff, final}},
+		Outbounds: []option.Outbound{{Type: constant.TypeSOCKS, Tag: "proxy"}, {Type: constant.TypeDirect, Tag: "direct"}},
+	}
+	withDeviceRoutes(&options, devices, deviceRules)
+	// 设备规则在最终代理之前，默认出站 proxy 仍然是第一个出站
+	if got, want := outboundTags(options.Outbounds), []string{"proxy", "direct", "proxy-1", "proxy-1-detour-1", "proxy-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("outbounds: got %v, want %v", got, want)
+	}
+	got := make([]string, 0)
+	for _, rule := range options.Route.Rules {
+		got = append(got, rule.DefaultOptions.Action+":"+rule.DefaultOptions.RouteOptions.Outbound)
+	}
+	if want := []string{"sniff:", "route:proxy-1", "route:proxy-2", "route:proxy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rules: got %v, want %v", got, want)
+	}
+}
+
+func TestUpdateRule(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	body := "downloaded"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	b := &Box{ctx: context.Background()}
+	file := filepath.Join(path.Path(), "rule.json")
+	read := func() string {
+		data, _ := os.ReadFile(file)
+		return string(data)
+	}
+
+	// 没有文件时下载失败写入内置规则
+	body = ""
+	b.updateRule(server.URL, "rule.json", []byte("embedded"))
+	if got := read(); got != "embedded" {
+		t.Errorf("expected embedded rule, got %q", got)
+	}
+	body = "downloaded"
+	b.updateRule(server.URL, "rule.json", []byte("embedded"))
+	if got := read(); got != "downloaded" {
+		t.Errorf("expected downloaded rule, got %q", got)
+	}
+	// 服务端没有返回 ETag，之后下载失败时保留上次下载的文件
+	body = ""
+	b.updateRule(server.URL, "rule.json", []byte("embedded"))
+	if got := read(); got != "downloaded" {
+		t.Errorf("expected last downloaded rule, got %q", got)
+	}
 }
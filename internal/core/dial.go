@@ -0,0 +1,20 @@
+package core
+
+import (
+	"context"
+	"net"
+	"playfast/internal/node"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// dialProxy 供控制面请求经过节点，加速中使用正在运行的 proxy 出站，否则临时创建最近使用节点的出站
+func (b *Box) dialProxy(ctx context.Context, network, addr string) (net.Conn, error) {
+	destination := M.ParseSocksaddr(addr)
+	if running := b.running.Load(); running != nil {
+		if outbound, ok := running.Outbound().Outbound("proxy"); ok {
+			return outbound.DialContext(ctx, network, destination)
+		}
+	}
+	return node.DialTemporary(ctx, network, destination)
+}
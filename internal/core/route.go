@@ -2,100 +2,154 @@ package core
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"net"
 	"net/netip"
 	"playfast/utils"
 
 	"github.com/sagernet/sing-box/common/srs"
-	"github.com/sagernet/sing/common/json/badoption"
+	"go4.org/netipx"
 )
 
-func routeIps(appends []string) badoption.Listable[netip.Prefix] {
-	prefixes := make([]netip.Prefix, 0)
-	read, err := srs.Read(bytes.NewBuffer(geoip), false)
+// RouteError 表示绕行路由写入失败，已写入的路由均已回滚
+type RouteError struct {
+	Prefix netip.Prefix
+	Err    error
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("添加路由 %s 失败: %v", e.Prefix, e.Err)
+}
+func (e *RouteError) Unwrap() error {
+	return e.Err
+}
+
+// RouteProgress 路由写入进度回调，done 为已写入条数，total 为总条数
+type RouteProgress func(done, total int)
+
+// localAddrs 本机各网卡的地址，用于判断第一跳是否在直连的局域网内
+var localAddrs = net.InterfaceAddrs
+
+// bypass 返回直连 host 需要绕过 tun 的网段，host 为本机或直连局域网内的地址时不经过默认网关，返回空
+func bypass(host string) (string, error) {
+	ip, err := utils.GetIPsFromString(host)
 	if err != nil {
-		return prefixes
+		return "", err
 	}
-	for _, rule := range read.Options.Rules {
-		if rule.DefaultOptions.IPSet != nil {
-			for _, ipRange := range rule.DefaultOptions.IPSet.Ranges() {
-				for _, prefix := range ipRange.Prefixes() {
-					if !prefix.Addr().Is4() {
-						continue
-					}
-					prefixes = append(prefixes, prefix)
-				}
-			}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() && onLink(addr) {
+		return "", nil
+	}
+	return fmt.Sprintf("%s/32", addr), nil
+}
+
+// onLink addr 是否在本机某个网卡的网段内
+func onLink(addr netip.Addr) bool {
+	addrs, err := localAddrs()
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(ipNet.String()); err == nil && prefix.Contains(addr) {
+			return true
 		}
 	}
+	return false
+}
+
+// parsePrefixes 解析需要额外绕行的网段，跳过格式错误的条目
+func parsePrefixes(appends []string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(appends))
 	for _, s := range appends {
-		prefixes = append(prefixes, netip.MustParsePrefix(s))
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			log.Println("忽略无效的绕行网段:", err)
+			continue
+		}
+		prefixes = append(prefixes, prefix)
 	}
 	return prefixes
 }
 
-var defaultNetworkInfo *utils.NetworkInfo
-
-func route(appends []string) error {
-	var err error
-	defaultNetworkInfo, err = utils.GetDefaultNetworkInfo()
-	if err != nil {
-		return err
-	}
-	err = utils.SetInterfaceMetric(defaultNetworkInfo.IfIndex, 1)
-	if err != nil {
-		return err
+// routeIps 返回需要绕行的 IPv4 网段，相邻和重叠的网段会合并为最少的 CIDR
+func routeIps(appends []string) []netip.Prefix {
+	var builder netipx.IPSetBuilder
+	read, err := srs.Read(bytes.NewBuffer(geoip), false)
+	if err == nil {
+		for _, rule := range read.Options.Rules {
+			if rule.DefaultOptions.IPSet != nil {
+				builder.AddSet(rule.DefaultOptions.IPSet)
+			}
+		}
 	}
-	err = utils.UpdateDefaultMetric(defaultNetworkInfo.Gateway, defaultNetworkInfo.IfIndex, 10)
-	if err != nil {
-		return err
+	for _, prefix := range parsePrefixes(appends) {
+		builder.AddPrefix(prefix)
 	}
-	defaultNetworkInfo, err = utils.GetDefaultNetworkInfo()
+	// 只保留 IPv4
+	builder.RemovePrefix(netip.MustParsePrefix("::/0"))
+	set, err := builder.IPSet()
 	if err != nil {
-		return err
+		return []netip.Prefix{}
 	}
-	for _, prefix := range routeIps(appends) {
-		if prefix.Addr().Is4() {
-			_, ipNet, _ := net.ParseCIDR(prefix.String())
-			log.Printf("route add %s mask %s %s metric %d if %d", prefix.Addr(), net.IP(ipNet.Mask).String(), defaultNetworkInfo.Gateway, defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
-			err = utils.AddRoute(prefix.Addr(), netip.MustParseAddr(net.IP(ipNet.Mask).String()), netip.MustParseAddr(defaultNetworkInfo.Gateway), defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
-			if err != nil {
-				log.Println("add route error", err, prefix)
+	return set.Prefixes()
+}
+
+// routeTable 写入绕行路由使用的系统路由表操作，测试时替换
+type routeTable struct {
+	add  func(netip.Prefix) error
+	del  func(netip.Prefix) error
+	list func() ([]netip.Prefix, error) // 当前出口网卡上的路由
+}
+
+// apply 分批写入绕行路由，每批结束后上报一次进度，全部写入后校验路由表
+// 防止部分写入被系统静默丢弃，任何一步失败都会删除已写入的路由
+func (t routeTable) apply(prefixes []netip.Prefix, batchSize int, progress RouteProgress) error {
+	applied := make([]netip.Prefix, 0, len(prefixes))
+	for i := 0; i < len(prefixes); i += batchSize {
+		end := min(i+batchSize, len(prefixes))
+		for _, prefix := range prefixes[i:end] {
+			if err := t.add(prefix); err != nil {
+				t.remove(applied)
+				return &RouteError{Prefix: prefix, Err: err}
 			}
+			applied = append(applied, prefix)
+		}
+		if progress != nil {
+			progress(end, len(prefixes))
 		}
 	}
-	interfaces, err := net.Interfaces()
+	exists, err := t.list()
 	if err != nil {
+		t.remove(applied)
 		return err
 	}
-	for _, iface := range interfaces {
-		if iface.Name == "utun25" {
-			err = utils.AddRoute(netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("172.25.0.0"), defaultNetworkInfo.Metric-1, iface.Index)
-			break
-		}
+	set := make(map[netip.Prefix]struct{}, len(exists))
+	for _, prefix := range exists {
+		set[prefix] = struct{}{}
 	}
-	return err
-}
-func deleteRoute(appends []string) {
-	for _, prefix := range routeIps(appends) {
-		if prefix.Addr().Is4() {
-			_, ipNet, _ := net.ParseCIDR(prefix.String())
-			err := utils.DeleteRoute(prefix.Addr(), netip.MustParseAddr(net.IP(ipNet.Mask).String()), netip.MustParseAddr(defaultNetworkInfo.Gateway), defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
-			if err != nil {
-				log.Println("delete route error", err, prefix)
-			}
+	for _, prefix := range applied {
+		if _, ok := set[prefix]; !ok {
+			t.remove(applied)
+			return &RouteError{Prefix: prefix, Err: fmt.Errorf("路由表中不存在")}
 		}
 	}
-	interfaces, err := net.Interfaces()
-	if err != nil {
-		return
-	}
-	for _, iface := range interfaces {
-		if iface.Name == "utun25" {
-			err = utils.DeleteRoute(netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("172.25.0.0"), defaultNetworkInfo.Metric-2, iface.Index)
-			break
+	return nil
+}
+
+// remove 删除绕行路由，失败时记录日志并继续删除其余路由
+func (t routeTable) remove(prefixes []netip.Prefix) {
+	for _, prefix := range prefixes {
+		if err := t.del(prefix); err != nil {
+			log.Println("delete route error", err, prefix)
 		}
 	}
-	err = utils.SetInterfaceMetric(defaultNetworkInfo.IfIndex, 0)
 }
@@ -0,0 +1,20 @@
+package core
+
+import (
+	"github.com/sagernet/sing-box/option"
+)
+
+// tunRoute Linux 下交给 sing-tun 的 auto_route 接管路由
+// 国内地址通过 nftables 集合排除，无需逐条写入路由表，启停只需毫秒级
+func tunRoute(options *option.TunInboundOptions, appends []string) {
+	options.AutoRoute = true
+	options.StrictRoute = true
+	options.AutoRedirect = true
+	options.RouteExcludeAddressSet = append(options.RouteExcludeAddressSet, "geoip-cn")
+	options.RouteExcludeAddress = append(options.RouteExcludeAddress, parsePrefixes(appends)...)
+}
+
+func route(_ []string, _ RouteProgress) error {
+	return nil
+}
+func deleteRoute() {}
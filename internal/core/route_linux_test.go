@@ -0,0 +1,25 @@
+package core
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+func TestTunRoute(t *testing.T) {
+	options := option.TunInboundOptions{}
+	// 无效的条目被跳过而不是 panic
+	tunRoute(&options, []string{"1.2.3.4/32", "<nil>/32", "", "10.0.0.0/8"})
+	if !options.AutoRoute || !options.StrictRoute || !options.AutoRedirect {
+		t.Errorf("auto route not enabled: %+v", options)
+	}
+	if !reflect.DeepEqual([]string(options.RouteExcludeAddressSet), []string{"geoip-cn"}) {
+		t.Errorf("unexpected exclude sets %v", options.RouteExcludeAddressSet)
+	}
+	want := []netip.Prefix{netip.MustParsePrefix("1.2.3.4/32"), netip.MustParsePrefix("10.0.0.0/8")}
+	if !reflect.DeepEqual([]netip.Prefix(options.RouteExcludeAddress), want) {
+		t.Errorf("exclude addresses %v, want %v", options.RouteExcludeAddress, want)
+	}
+}
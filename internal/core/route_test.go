@@ -0,0 +1,133 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestRouteIps(t *testing.T) {
+	prefixes := routeIps([]string{"8.8.8.8/32", "8.8.8.9/32"})
+	if len(prefixes) == 0 {
+		t.Fatal("empty prefixes")
+	}
+	found := false
+	for i, prefix := range prefixes {
+		if !prefix.Addr().Is4() {
+			t.Fatalf("unexpected IPv6 prefix %s", prefix)
+		}
+		if i > 0 && prefixes[i-1].Overlaps(prefix) {
+			t.Fatalf("overlapping prefixes %s %s", prefixes[i-1], prefix)
+		}
+		if prefix == netip.MustParsePrefix("8.8.8.8/31") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("appended prefixes were not merged")
+	}
+}
+
+// fakeRoutes 记录写入的路由，addErr 指定写入失败的网段，dropped 指定被系统静默丢弃的网段
+type fakeRoutes struct {
+	routes  map[netip.Prefix]bool
+	addErr  netip.Prefix
+	dropped netip.Prefix
+	deleted []netip.Prefix
+}
+
+func (f *fakeRoutes) table() routeTable {
+	f.routes = make(map[netip.Prefix]bool)
+	return routeTable{
+		add: func(prefix netip.Prefix) error {
+			if prefix == f.addErr {
+				return errors.New("access denied")
+			}
+			f.routes[prefix] = true
+			return nil
+		},
+		del: func(prefix netip.Prefix) error {
+			f.deleted = append(f.deleted, prefix)
+			delete(f.routes, prefix)
+			return nil
+		},
+		list: func() ([]netip.Prefix, error) {
+			list := make([]netip.Prefix, 0, len(f.routes))
+			for prefix := range f.routes {
+				if prefix != f.dropped {
+					list = append(list, prefix)
+				}
+			}
+			return list, nil
+		},
+	}
+}
+
+func TestRouteTableApply(t *testing.T) {
+	prefixes := make([]netip.Prefix, 5)
+	for i := range prefixes {
+		prefixes[i] = netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i), 0, 0}), 16)
+	}
+	tests := []struct {
+		name     string
+		addErr   netip.Prefix
+		dropped  netip.Prefix
+		failed   netip.Prefix
+		progress []int
+		deleted  int
+	}{
+		{name: "全部写入", progress: []int{2, 4, 5}},
+		{name: "第二批写入失败后回滚", addErr: prefixes[3], failed: prefixes[3], progress: []int{2}, deleted: 3},
+		{name: "校验发现路由缺失后回滚", dropped: prefixes[1], failed: prefixes[1], progress: []int{2, 4, 5}, deleted: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRoutes{addErr: tt.addErr, dropped: tt.dropped}
+			var progress []int
+			err := f.table().apply(prefixes, 2, func(done, total int) {
+				if total != len(prefixes) {
+					t.Errorf("total %d, want %d", total, len(prefixes))
+				}
+				progress = append(progress, done)
+			})
+			if !reflect.DeepEqual(progress, tt.progress) {
+				t.Errorf("progress %v, want %v", progress, tt.progress)
+			}
+			if !tt.failed.IsValid() {
+				if err != nil || len(f.routes) != len(prefixes) {
+					t.Errorf("expected all routes, got %d routes %v", len(f.routes), err)
+				}
+				return
+			}
+			var routeErr *RouteError
+			if !errors.As(err, &routeErr) || routeErr.Prefix != tt.failed {
+				t.Fatalf("expected RouteError for %s, got %v", tt.failed, err)
+			}
+			if len(f.deleted) != tt.deleted || len(f.routes) != 0 {
+				t.Errorf("expected rollback of %d routes, deleted %v, left %d", tt.deleted, f.deleted, len(f.routes))
+			}
+		})
+	}
+}
+
+func TestBypass(t *testing.T) {
+	localAddrs = func() ([]net.Addr, error) {
+		return []net.Addr{&net.IPNet{IP: net.IPv4(192, 168, 1, 10), Mask: net.CIDRMask(24, 32)}}, nil
+	}
+	defer func() { localAddrs = net.InterfaceAddrs }()
+	tests := map[string]string{
+		"127.0.0.1":   "",
+		"::1":         "",
+		"192.168.1.5": "",
+		// 不在直连网段内的私有地址仍然要经过默认网关
+		"192.168.2.5": "192.168.2.5/32",
+		"1.2.3.4":     "1.2.3.4/32",
+	}
+	for host, want := range tests {
+		if got, err := bypass(host); err != nil || got != want {
+			t.Errorf("%s: got %q %v, want %q", host, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package core
+
+import (
+	"log"
+	"net"
+	"net/netip"
+	"playfast/utils"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+// routeBatchSize 每批写入的路由条数，每批结束后上报一次进度
+const routeBatchSize = 256
+
+var defaultNetworkInfo *utils.NetworkInfo
+
+// appliedRoutes 本次实际写入的绕行路由，停止时按此删除
+var appliedRoutes []netip.Prefix
+
+// tunRoute Windows 下由 route 逐条写入系统路由表，tun 本身不接管路由
+func tunRoute(_ *option.TunInboundOptions, _ []string) {}
+
+// systemRoutes 经过默认网关的系统路由表
+var systemRoutes = routeTable{
+	add: addRoute,
+	del: delRoute,
+	list: func() ([]netip.Prefix, error) {
+		return utils.GetRoutes(defaultNetworkInfo.IfIndex)
+	},
+}
+
+func addRoute(prefix netip.Prefix) error {
+	mask := netip.AddrFrom4([4]byte(net.CIDRMask(prefix.Bits(), 32)))
+	return utils.AddRoute(prefix.Addr(), mask, netip.MustParseAddr(defaultNetworkInfo.Gateway), defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
+}
+func delRoute(prefix netip.Prefix) error {
+	mask := netip.AddrFrom4([4]byte(net.CIDRMask(prefix.Bits(), 32)))
+	return utils.DeleteRoute(prefix.Addr(), mask, netip.MustParseAddr(defaultNetworkInfo.Gateway), defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
+}
+
+func route(appends []string, progress RouteProgress) error {
+	var err error
+	defaultNetworkInfo, err = utils.GetDefaultNetworkInfo()
+	if err != nil {
+		return err
+	}
+	err = utils.SetInterfaceMetric(defaultNetworkInfo.IfIndex, 1)
+	if err != nil {
+		return err
+	}
+	err = utils.UpdateDefaultMetric(defaultNetworkInfo.Gateway, defaultNetworkInfo.IfIndex, 10)
+	if err != nil {
+		_ = utils.SetInterfaceMetric(defaultNetworkInfo.IfIndex, 0)
+		return err
+	}
+	defaultNetworkInfo, err = utils.GetDefaultNetworkInfo()
+	if err != nil {
+		return err
+	}
+	prefixes := routeIps(appends)
+	log.Printf("route add %d prefixes via %s metric %d if %d", len(prefixes), defaultNetworkInfo.Gateway, defaultNetworkInfo.Metric-2, defaultNetworkInfo.IfIndex)
+	// 失败时 apply 已删除写入的路由，deleteRoute 只需恢复网卡跃点
+	appliedRoutes = nil
+	if err = systemRoutes.apply(prefixes, routeBatchSize, progress); err != nil {
+		deleteRoute()
+		return err
+	}
+	appliedRoutes = prefixes
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		deleteRoute()
+		return err
+	}
+	for _, iface := range interfaces {
+		if iface.Name == "utun25" {
+			err = utils.AddRoute(netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("172.25.0.0"), defaultNetworkInfo.Metric-1, iface.Index)
+			if err != nil {
+				deleteRoute()
+				return &RouteError{Prefix: netip.MustParsePrefix("0.0.0.0/0"), Err: err}
+			}
+			break
+		}
+	}
+	return nil
+}
+func deleteRoute() {
+	if defaultNetworkInfo == nil {
+		return
+	}
+	systemRoutes.remove(appliedRoutes)
+	appliedRoutes = nil
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return
+	}
+	for _, iface := range interfaces {
+		if iface.Name == "utun25" {
+			_ = utils.DeleteRoute(netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("0.0.0.0"), netip.MustParseAddr("172.25.0.0"), defaultNetworkInfo.Metric-1, iface.Index)
+			break
+		}
+	}
+	_ = utils.SetInterfaceMetric(defaultNetworkInfo.IfIndex, 0)
+}
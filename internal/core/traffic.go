@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/bufio"
+	N "github.com/sagernet/sing/common/network"
+)
+
+// DeviceTraffic 单个设备经过加速器的流量
+type DeviceTraffic struct {
+	IP       string `json:"ip"`
+	Node     string `json:"node"`
+	Upload   int64  `json:"upload"`
+	Download int64  `json:"download"`
+}
+
+type deviceCounter struct {
+	upload   atomic.Int64
+	download atomic.Int64
+}
+
+// trafficTracker 按来源 IP 统计流量
+type trafficTracker struct {
+	mu      sync.Mutex
+	devices map[netip.Addr]*deviceCounter
+}
+
+func newTrafficTracker() *trafficTracker {
+	return &trafficTracker{devices: make(map[netip.Addr]*deviceCounter)}
+}
+
+func (t *trafficTracker) counter(source netip.Addr) *deviceCounter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.devices[source]
+	if !ok {
+		c = &deviceCounter{}
+		t.devices[source] = c
+	}
+	return c
+}
+
+func (t *trafficTracker) RoutedConnection(_ context.Context, conn net.Conn, metadata adapter.InboundContext, _ adapter.Rule, _ adapter.Outbound) net.Conn {
+	c := t.counter(metadata.Source.Addr.Unmap())
+	return bufio.NewInt64CounterConn(conn, []*atomic.Int64{&c.upload}, []*atomic.Int64{&c.download})
+}
+
+func (t *trafficTracker) RoutedPacketConnection(_ context.Context, conn N.PacketConn, metadata adapter.InboundContext, _ adapter.Rule, _ adapter.Outbound) N.PacketConn {
+	c := t.counter(metadata.Source.Addr.Unmap())
+	return bufio.NewInt64CounterPacketConn(conn, []*atomic.Int64{&c.upload}, nil, []*atomic.Int64{&c.download}, nil)
+}
+
+// snapshot 返回各设备的流量，nodes 为设备 IP 到节点名称的映射
+func (t *trafficTracker) snapshot(nodes map[string]string, defaultNode string) []DeviceTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	traffic := make([]DeviceTraffic, 0, len(t.devices))
+	for addr, c := range t.devices {
+		node, ok := nodes[addr.String()]
+		if !ok {
+			node = defaultNode
+		}
+		traffic = append(traffic, DeviceTraffic{
+			IP:       addr.String(),
+			Node:     node,
+			Upload:   c.upload.Load(),
+			Download: c.download.Load(),
+		})
+	}
+	sort.Slice(traffic, func(i, j int) bool {
+		return traffic[i].IP < traffic[j].IP
+	})
+	return traffic
+}
@@ -0,0 +1,57 @@
+package dhcp
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+)
+
+// Config DHCP 服务配置，保存在 dhcp.json
+type Config struct {
+	Enabled   bool     `json:"enabled"`
+	MACs      []string `json:"macs"`       // 非空时只为这些设备分配地址
+	PoolStart string   `json:"pool_start"` // 为空时使用子网末尾的地址
+	PoolSize  int      `json:"pool_size"`
+}
+
+func configFile() string {
+	return filepath.Join(path.Path(), "dhcp.json")
+}
+
+// LoadConfig 读取本地保存的配置，读取失败时返回默认配置
+func LoadConfig() Config {
+	config := Config{}
+	data, err := os.ReadFile(configFile())
+	if err != nil {
+		return config
+	}
+	_ = json.Unmarshal(data, &config)
+	return config
+}
+
+// SaveConfig 保存配置
+func SaveConfig(config Config) error {
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(configFile(), data, 0644)
+}
+
+// Options 将配置转换为服务选项
+func (c Config) Options() []ServerOption {
+	options := make([]ServerOption, 0)
+	if len(c.MACs) > 0 {
+		options = append(options, WithMACs(c.MACs...))
+	}
+	if start := net.ParseIP(c.PoolStart); start != nil && start.To4() != nil {
+		size := c.PoolSize
+		if size <= 0 {
+			size = defaultPoolSize
+		}
+		options = append(options, WithPool(start, size))
+	}
+	return options
+}
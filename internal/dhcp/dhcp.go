@@ -0,0 +1,531 @@
+package dhcp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+const (
+	defaultLeaseTime = time.Hour
+	defaultPoolSize  = 16
+	offerTimeout     = 30 * time.Second // 发出 OFFER 后保留地址的时间
+	declineTimeout   = 10 * time.Minute // 冲突地址的冷却时间
+	maxWorkers       = 32               // 同时处理的请求数，冲突检测期间请求会等待较长时间
+)
+
+// broadcastAddr 冲突检测的 DISCOVER 发往局域网内所有 DHCP 服务
+var broadcastAddr net.Addr = &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ServerPort}
+
+// Lease 表示一条 DHCP 租约
+type Lease struct {
+	MAC      string    `json:"mac"`
+	IP       string    `json:"ip"`
+	Hostname string    `json:"hostname"`
+	Expire   time.Time `json:"expire"`
+	bound    bool
+}
+
+// Server 网关模式下的 DHCP 服务，将本机作为网关和 DNS 下发给局域网设备
+type Server struct {
+	serverIP  net.IP
+	mask      net.IPMask
+	iface     string
+	pool      []net.IP
+	macs      map[string]struct{}
+	leaseTime time.Duration
+	conflicts []func(ip net.IP, mac string) bool // 依次进行的冲突检测，任一返回 true 即视为冲突
+	leases    map[string]*Lease                  // MAC -> 租约
+	declined  map[string]time.Time               // IP -> 冷却截止时间
+	offering  map[string]struct{}                // 正在检测地址冲突的 MAC
+	probes    map[dhcpv4.TransactionID]chan *dhcpv4.DHCPv4
+	probeAddr net.Addr
+	workers   chan struct{}
+	conn      net.PacketConn
+	closed    bool
+	mu        sync.Mutex
+}
+
+// ServerOption 是Server的选项函数类型
+type ServerOption func(*Server)
+
+// NewServer 创建 DHCP 服务，serverIP 为本机局域网地址
+// 未指定地址池时，使用子网末尾的一小段地址
+func NewServer(serverIP net.IP, mask net.IPMask, options ...ServerOption) *Server {
+	s := &Server{
+		serverIP:  serverIP.To4(),
+		mask:      mask,
+		macs:      make(map[string]struct{}),
+		leaseTime: defaultLeaseTime,
+		leases:    make(map[string]*Lease),
+		declined:  make(map[string]time.Time),
+		offering:  make(map[string]struct{}),
+		probes:    make(map[dhcpv4.TransactionID]chan *dhcpv4.DHCPv4),
+		probeAddr: broadcastAddr,
+		workers:   make(chan struct{}, maxWorkers),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if len(s.pool) == 0 {
+		s.pool = tailPool(s.serverIP, s.mask, defaultPoolSize)
+	}
+	return s
+}
+
+// WithInterface 只在指定的局域网网卡上提供服务
+func WithInterface(name string) ServerOption {
+	return func(s *Server) {
+		s.iface = name
+	}
+}
+
+// WithPool 设置地址池，从 start 开始连续 size 个地址
+func WithPool(start net.IP, size int) ServerOption {
+	return func(s *Server) {
+		s.pool = make([]net.IP, 0, size)
+		ip := binary.BigEndian.Uint32(start.To4())
+		for i := 0; i < size; i++ {
+			addr := make(net.IP, 4)
+			binary.BigEndian.PutUint32(addr, ip+uint32(i))
+			s.pool = append(s.pool, addr)
+		}
+	}
+}
+
+// WithMACs 只为指定 MAC 地址的设备分配地址，其余设备交给路由器处理
+func WithMACs(macs ...string) ServerOption {
+	return func(s *Server) {
+		for _, mac := range macs {
+			hw, err := net.ParseMAC(mac)
+			if err != nil {
+				continue
+			}
+			s.macs[hw.String()] = struct{}{}
+		}
+	}
+}
+
+// WithLeaseTime 设置租约时长
+func WithLeaseTime(leaseTime time.Duration) ServerOption {
+	return func(s *Server) {
+		s.leaseTime = leaseTime
+	}
+}
+
+// WithConflictCheck 添加地址冲突检测，返回 true 表示地址已被其他设备占用
+func WithConflictCheck(conflict func(ip net.IP) bool) ServerOption {
+	return func(s *Server) {
+		s.conflicts = append(s.conflicts, func(ip net.IP, _ string) bool {
+			return conflict(ip)
+		})
+	}
+}
+
+// WithOfferCheck 添加冲突检测，分配地址前以中继身份代请求的设备广播 DHCPDISCOVER
+// 路由器等其他 DHCP 服务在 wait 内提供了同一地址时视为冲突，可以与 WithConflictCheck 同时使用
+func WithOfferCheck(wait time.Duration) ServerOption {
+	return func(s *Server) {
+		s.conflicts = append(s.conflicts, func(ip net.IP, mac string) bool {
+			return s.offered(ip, mac, wait)
+		})
+	}
+}
+
+// ListenAndServe 在局域网网卡的 67 端口上监听并处理请求
+func (s *Server) ListenAndServe() error {
+	conn, err := listen(s.iface, s.serverIP)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve 在指定连接上处理请求，直到连接关闭
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return conn.Close()
+	}
+	s.conn = conn
+	s.mu.Unlock()
+	buffer := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		msg, err := dhcpv4.FromBytes(buffer[:n])
+		if err != nil {
+			continue
+		}
+		if msg.OpCode == dhcpv4.OpcodeBootReply {
+			s.deliver(msg)
+			continue
+		}
+		if msg.OpCode != dhcpv4.OpcodeBootRequest || msg.GatewayIPAddr.Equal(s.serverIP) {
+			// 忽略本机冲突检测发出的广播
+			continue
+		}
+		// 冲突检测需要等待其他服务的回复，请求不能阻塞读取
+		// 处理中的请求已满时丢弃，设备会重传
+		select {
+		case s.workers <- struct{}{}:
+			go func() {
+				defer func() { <-s.workers }()
+				s.respond(conn, msg, peer)
+			}()
+		default:
+		}
+	}
+}
+
+func (s *Server) respond(conn net.PacketConn, req *dhcpv4.DHCPv4, peer net.Addr) {
+	resp := s.handle(req)
+	if resp == nil {
+		return
+	}
+	_, err := conn.WriteTo(resp.ToBytes(), replyAddr(req, peer))
+	if err != nil {
+		log.Println("dhcp reply error", err)
+	}
+}
+
+// offered 代设备发送 giaddr 为本机地址的 DISCOVER，其他服务会把 OFFER 单播回本机的 67 端口
+// 设备自己的 DISCOVER 也是广播，其他服务已经为该 MAC 预留了地址，检测不会额外占用租约
+func (s *Server) offered(ip net.IP, mac string, wait time.Duration) bool {
+	hw, err := net.ParseMAC(mac)
+	if err != nil {
+		return false
+	}
+	discover, err := dhcpv4.NewDiscovery(hw,
+		dhcpv4.WithGatewayIP(s.serverIP),
+		dhcpv4.WithOption(dhcpv4.OptRequestedIPAddress(ip)),
+	)
+	if err != nil {
+		return false
+	}
+	offers := make(chan *dhcpv4.DHCPv4, 4)
+	s.mu.Lock()
+	conn := s.conn
+	s.probes[discover.TransactionID] = offers
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.probes, discover.TransactionID)
+		s.mu.Unlock()
+	}()
+	if conn == nil {
+		return false
+	}
+	if _, err = conn.WriteTo(discover.ToBytes(), s.probeAddr); err != nil {
+		log.Println("dhcp conflict check error", err)
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case offer := <-offers:
+			if offer.YourIPAddr.Equal(ip) {
+				return true
+			}
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// deliver 将其他服务回复的 OFFER 交给等待中的冲突检测
+func (s *Server) deliver(reply *dhcpv4.DHCPv4) {
+	if reply.MessageType() != dhcpv4.MessageTypeOffer || reply.ServerIdentifier().Equal(s.serverIP) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if offers, ok := s.probes[reply.TransactionID]; ok {
+		select {
+		case offers <- reply:
+		default:
+		}
+	}
+}
+
+// Close 停止服务
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Leases 返回当前有效的租约
+func (s *Server) Leases() []Lease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	leases := make([]Lease, 0, len(s.leases))
+	for _, lease := range s.leases {
+		if lease.bound && lease.Expire.After(now) {
+			leases = append(leases, *lease)
+		}
+	}
+	sort.Slice(leases, func(i, j int) bool {
+		return leases[i].IP < leases[j].IP
+	})
+	return leases
+}
+
+func (s *Server) handle(req *dhcpv4.DHCPv4) *dhcpv4.DHCPv4 {
+	mac := req.ClientHWAddr.String()
+	if !s.allowed(mac) {
+		return nil
+	}
+	switch req.MessageType() {
+	case dhcpv4.MessageTypeDiscover:
+		if !s.startOffer(mac) {
+			// 重传的 DISCOVER，上一次的冲突检测还没有结束
+			return nil
+		}
+		defer s.endOffer(mac)
+		ip := s.allocate(mac, req.RequestedIPAddress())
+		if ip == nil {
+			log.Println("dhcp pool exhausted", mac)
+			return nil
+		}
+		return s.reply(req, dhcpv4.MessageTypeOffer, ip)
+	case dhcpv4.MessageTypeRequest:
+		serverID := req.ServerIdentifier()
+		if serverID != nil && !serverID.Equal(s.serverIP) {
+			// 客户端选择了路由器或其他 DHCP 服务，释放预留的地址
+			s.release(mac)
+			return nil
+		}
+		requested := req.RequestedIPAddress()
+		if requested == nil || requested.IsUnspecified() {
+			requested = req.ClientIPAddr
+		}
+		if s.bind(mac, requested, req.HostName()) {
+			return s.reply(req, dhcpv4.MessageTypeAck, requested)
+		}
+		if serverID == nil {
+			// INIT-REBOOT 状态下没有该客户端的记录时保持沉默
+			return nil
+		}
+		return s.reply(req, dhcpv4.MessageTypeNak, nil)
+	case dhcpv4.MessageTypeDecline:
+		s.mu.Lock()
+		if ip := req.RequestedIPAddress(); ip != nil {
+			s.declined[ip.String()] = time.Now().Add(declineTimeout)
+		}
+		delete(s.leases, mac)
+		s.mu.Unlock()
+	case dhcpv4.MessageTypeRelease:
+		s.release(mac)
+	case dhcpv4.MessageTypeInform:
+		return s.reply(req, dhcpv4.MessageTypeAck, nil)
+	}
+	return nil
+}
+
+func (s *Server) reply(req *dhcpv4.DHCPv4, messageType dhcpv4.MessageType, ip net.IP) *dhcpv4.DHCPv4 {
+	modifiers := []dhcpv4.Modifier{
+		dhcpv4.WithMessageType(messageType),
+		dhcpv4.WithServerIP(s.serverIP),
+		dhcpv4.WithOption(dhcpv4.OptServerIdentifier(s.serverIP)),
+	}
+	if messageType != dhcpv4.MessageTypeNak {
+		modifiers = append(modifiers,
+			dhcpv4.WithNetmask(s.mask),
+			dhcpv4.WithRouter(s.serverIP),
+			dhcpv4.WithDNS(s.serverIP),
+		)
+	}
+	if ip != nil {
+		modifiers = append(modifiers,
+			dhcpv4.WithYourIP(ip),
+			dhcpv4.WithLeaseTime(uint32(s.leaseTime.Seconds())),
+		)
+	}
+	resp, err := dhcpv4.NewReplyFromRequest(req, modifiers...)
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+func (s *Server) startOffer(mac string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.offering[mac]; ok {
+		return false
+	}
+	s.offering[mac] = struct{}{}
+	return true
+}
+
+func (s *Server) endOffer(mac string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.offering, mac)
+}
+
+func (s *Server) allowed(mac string) bool {
+	if len(s.macs) == 0 {
+		return true
+	}
+	_, ok := s.macs[mac]
+	return ok
+}
+
+// free 判断地址是否可以分配给 mac，调用方需持有锁
+func (s *Server) free(ip net.IP, mac string) bool {
+	if ip.Equal(s.serverIP) {
+		return false
+	}
+	now := time.Now()
+	if until, ok := s.declined[ip.String()]; ok && until.After(now) {
+		return false
+	}
+	for owner, lease := range s.leases {
+		if owner != mac && lease.IP == ip.String() && lease.Expire.After(now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) inPool(ip net.IP) bool {
+	for _, p := range s.pool {
+		if p.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allocate 为 DISCOVER 选择地址，优先沿用已有租约和客户端请求的地址
+func (s *Server) allocate(mac string, requested net.IP) net.IP {
+	s.mu.Lock()
+	if lease, ok := s.leases[mac]; ok && lease.Expire.After(time.Now()) {
+		s.mu.Unlock()
+		return net.ParseIP(lease.IP).To4()
+	}
+	candidates := make([]net.IP, 0, len(s.pool)+1)
+	if requested != nil && s.inPool(requested) {
+		candidates = append(candidates, requested.To4())
+	}
+	for _, ip := range s.pool {
+		if s.free(ip, mac) {
+			candidates = append(candidates, ip)
+		}
+	}
+	s.mu.Unlock()
+	for _, ip := range candidates {
+		// 冲突检测可能较慢，不在锁内进行
+		if s.conflicted(ip, mac) {
+			s.mu.Lock()
+			s.declined[ip.String()] = time.Now().Add(declineTimeout)
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Lock()
+		if s.free(ip, mac) {
+			s.leases[mac] = &Lease{
+				MAC:    mac,
+				IP:     ip.String(),
+				Expire: time.Now().Add(offerTimeout),
+			}
+			s.mu.Unlock()
+			return ip
+		}
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// conflicted 依次进行冲突检测，已经发现冲突时不再进行后面的检测
+func (s *Server) conflicted(ip net.IP, mac string) bool {
+	for _, conflict := range s.conflicts {
+		if conflict(ip, mac) {
+			return true
+		}
+	}
+	return false
+}
+
+// bind 确认 REQUEST 请求的地址并写入租约
+func (s *Server) bind(mac string, ip net.IP, hostname string) bool {
+	if ip == nil || !s.inPool(ip) {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lease, ok := s.leases[mac]
+	if !ok || lease.IP != ip.String() {
+		return false
+	}
+	lease.Hostname = strings.TrimSpace(hostname)
+	lease.Expire = time.Now().Add(s.leaseTime)
+	lease.bound = true
+	return true
+}
+
+func (s *Server) release(mac string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.leases, mac)
+}
+
+// replyAddr 按 RFC 2131 选择回复地址
+func replyAddr(req *dhcpv4.DHCPv4, peer net.Addr) net.Addr {
+	if udp, ok := peer.(*net.UDPAddr); ok && !udp.IP.IsUnspecified() && udp.Port != dhcpv4.ClientPort {
+		// 非标准端口的客户端直接回复原地址
+		return peer
+	}
+	if req.ClientIPAddr != nil && !req.ClientIPAddr.IsUnspecified() {
+		return &net.UDPAddr{IP: req.ClientIPAddr, Port: dhcpv4.ClientPort}
+	}
+	return &net.UDPAddr{IP: net.IPv4bcast, Port: dhcpv4.ClientPort}
+}
+
+// tailPool 取子网末尾 size 个主机地址，通常不在路由器的地址池内
+func tailPool(ip net.IP, mask net.IPMask, size int) []net.IP {
+	network := ip.Mask(mask)
+	if network == nil {
+		return nil
+	}
+	broadcast := make(net.IP, 4)
+	for i := range broadcast {
+		broadcast[i] = network[i] | ^mask[i]
+	}
+	last := binary.BigEndian.Uint32(broadcast)
+	first := binary.BigEndian.Uint32(network)
+	pool := make([]net.IP, 0, size)
+	for v := last - 1; v > first && len(pool) < size; v-- {
+		addr := make(net.IP, 4)
+		binary.BigEndian.PutUint32(addr, v)
+		if bytes.Equal(addr, ip) {
+			continue
+		}
+		pool = append(pool, addr)
+	}
+	return pool
+}
@@ -0,0 +1,179 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// testClient 模拟局域网设备的 DHCP 客户端
+type testClient struct {
+	t    *testing.T
+	conn net.PacketConn
+	addr net.Addr
+	mac  net.HardwareAddr
+}
+
+func newTestServer(t *testing.T, options ...ServerOption) (*Server, net.Addr) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(net.IPv4(192, 168, 1, 10), net.CIDRMask(24, 32), options...)
+	go func() { _ = server.Serve(conn) }()
+	t.Cleanup(func() { _ = server.Close() })
+	return server, conn.LocalAddr()
+}
+
+func newTestClient(t *testing.T, addr net.Addr, mac string) *testClient {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	hw, _ := net.ParseMAC(mac)
+	return &testClient{t: t, conn: conn, addr: addr, mac: hw}
+}
+
+func (c *testClient) exchange(req *dhcpv4.DHCPv4) *dhcpv4.DHCPv4 {
+	_, err := c.conn.WriteTo(req.ToBytes(), c.addr)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	buffer := make([]byte, 1500)
+	n, _, err := c.conn.ReadFrom(buffer)
+	if err != nil {
+		return nil
+	}
+	resp, err := dhcpv4.FromBytes(buffer[:n])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return resp
+}
+
+func (c *testClient) lease() (*dhcpv4.DHCPv4, *dhcpv4.DHCPv4) {
+	discover, err := dhcpv4.NewDiscovery(c.mac, dhcpv4.WithOption(dhcpv4.OptHostName("PS5")))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	offer := c.exchange(discover)
+	if offer == nil {
+		return nil, nil
+	}
+	request, err := dhcpv4.NewRequestFromOffer(offer, dhcpv4.WithOption(dhcpv4.OptHostName("PS5")))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return offer, c.exchange(request)
+}
+
+func TestServerLease(t *testing.T) {
+	server, addr := newTestServer(t)
+	client := newTestClient(t, addr, "00:d9:d1:00:00:01")
+	offer, ack := client.lease()
+	if offer == nil || ack == nil {
+		t.Fatal("no reply")
+	}
+	if offer.MessageType() != dhcpv4.MessageTypeOffer || ack.MessageType() != dhcpv4.MessageTypeAck {
+		t.Fatalf("unexpected message types %s %s", offer.MessageType(), ack.MessageType())
+	}
+	if !ack.YourIPAddr.Equal(net.IPv4(192, 168, 1, 254)) {
+		t.Errorf("unexpected address %s", ack.YourIPAddr)
+	}
+	if len(ack.Router()) != 1 || !ack.Router()[0].Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Errorf("unexpected router %v", ack.Router())
+	}
+	if len(ack.DNS()) != 1 || !ack.DNS()[0].Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Errorf("unexpected dns %v", ack.DNS())
+	}
+	leases := server.Leases()
+	if len(leases) != 1 || leases[0].Hostname != "PS5" || leases[0].IP != "192.168.1.254" {
+		t.Errorf("unexpected leases %+v", leases)
+	}
+}
+
+func TestServerMACs(t *testing.T) {
+	_, addr := newTestServer(t, WithMACs("00:d9:d1:00:00:01"))
+	offer, _ := newTestClient(t, addr, "00:d9:d1:00:00:02").lease()
+	if offer != nil {
+		t.Error("unlisted device got an offer")
+	}
+	_, ack := newTestClient(t, addr, "00:d9:d1:00:00:01").lease()
+	if ack == nil {
+		t.Error("listed device got no lease")
+	}
+}
+
+func TestServerConflict(t *testing.T) {
+	_, addr := newTestServer(t,
+		WithPool(net.IPv4(192, 168, 1, 100), 2),
+		WithConflictCheck(func(ip net.IP) bool {
+			return ip.Equal(net.IPv4(192, 168, 1, 100))
+		}),
+	)
+	_, ack := newTestClient(t, addr, "00:d9:d1:00:00:01").lease()
+	if ack == nil || !ack.YourIPAddr.Equal(net.IPv4(192, 168, 1, 101)) {
+		t.Fatalf("conflicting address was not skipped: %v", ack)
+	}
+	offer, _ := newTestClient(t, addr, "00:d9:d1:00:00:02").lease()
+	if offer != nil {
+		t.Error("offer from exhausted pool")
+	}
+}
+
+func TestServerOtherServer(t *testing.T) {
+	server, addr := newTestServer(t)
+	client := newTestClient(t, addr, "00:d9:d1:00:00:01")
+	discover, _ := dhcpv4.NewDiscovery(client.mac)
+	offer := client.exchange(discover)
+	if offer == nil {
+		t.Fatal("no offer")
+	}
+	// 客户端选择了路由器的 OFFER
+	request, _ := dhcpv4.NewRequestFromOffer(offer, dhcpv4.WithOption(dhcpv4.OptServerIdentifier(net.IPv4(192, 168, 1, 1))))
+	if resp := client.exchange(request); resp != nil {
+		t.Errorf("unexpected reply %s", resp.MessageType())
+	}
+	if len(server.Leases()) != 0 {
+		t.Error("lease kept for other server")
+	}
+}
+
+func TestServerOfferCheck(t *testing.T) {
+	// 模拟路由器，地址池中只有 192.168.1.200
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := NewServer(net.IPv4(192, 168, 1, 1), net.CIDRMask(24, 32), WithPool(net.IPv4(192, 168, 1, 200), 1))
+	go func() { _ = router.Serve(conn) }()
+	t.Cleanup(func() { _ = router.Close() })
+	// 192.168.1.201 已被静态设置了地址的设备占用，只有可达性检测能发现
+	_, addr := newTestServer(t,
+		WithPool(net.IPv4(192, 168, 1, 200), 3),
+		WithConflictCheck(func(ip net.IP) bool {
+			return ip.Equal(net.IPv4(192, 168, 1, 201))
+		}),
+		WithOfferCheck(100*time.Millisecond),
+		func(s *Server) { s.probeAddr = conn.LocalAddr() },
+	)
+	_, ack := newTestClient(t, addr, "00:d9:d1:00:00:01").lease()
+	if ack == nil || !ack.YourIPAddr.Equal(net.IPv4(192, 168, 1, 202)) {
+		t.Fatalf("conflicting addresses were not skipped: %v", ack)
+	}
+	if len(router.Leases()) != 0 {
+		t.Error("conflict check bound a lease on the router")
+	}
+	// 检测使用设备自己的 MAC，路由器上不会出现虚构的设备
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	for mac := range router.leases {
+		if mac != "00:d9:d1:00:00:01" {
+			t.Errorf("offer check reserved an address for %s", mac)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package dhcp
+
+import (
+	"context"
+	"net"
+	"syscall"
+)
+
+// listen 监听通配地址的 67 端口才能收到广播的 DISCOVER，通过 SO_BINDTODEVICE 限定在局域网网卡
+func listen(iface string, _ net.IP) (net.PacketConn, error) {
+	config := net.ListenConfig{}
+	if iface != "" {
+		config.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if controlErr := c.Control(func(fd uintptr) {
+				err = syscall.BindToDevice(int(fd), iface)
+			}); controlErr != nil {
+				return controlErr
+			}
+			return err
+		}
+	}
+	return config.ListenPacket(context.Background(), "udp4", ":67")
+}
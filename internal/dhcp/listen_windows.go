@@ -0,0 +1,13 @@
+package dhcp
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/insomniacslk/dhcp/dhcpv4"
+)
+
+// listen 绑定局域网地址的 67 端口，Windows 会把该网卡收到的广播交给绑定了网卡地址的套接字
+func listen(_ string, ip net.IP) (net.PacketConn, error) {
+	return net.ListenPacket("udp4", net.JoinHostPort(ip.String(), strconv.Itoa(dhcpv4.ServerPort)))
+}
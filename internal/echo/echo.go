@@ -4,19 +4,19 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"math"
 	"net"
-	"sort"
 	"sync"
 	"time"
 
 	"github.com/sagernet/sing/common/metadata"
 )
 
-// Client 表示TCP Echo客户端
+// Client 表示Echo客户端，默认使用TCP，UDP模式下测试包带有序号和时间戳
 type Client struct {
 	serverAddr string
+	network    string
 	timeout    time.Duration
+	seq        *sequence
 	conn       net.Conn
 	stats      *Stats
 	connTime   time.Duration
@@ -52,7 +52,9 @@ type ClientOption func(*Client)
 func NewClient(serverAddr string, options ...ClientOption) *Client {
 	client := &Client{
 		serverAddr: serverAddr,
+		network:    "tcp",
 		timeout:    5 * time.Second,
+		seq:        newSequence(),
 		stats:      &Stats{},
 	}
 
@@ -71,6 +73,14 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithNetwork 设置连接类型，支持 "tcp" 和 "udp"
+// UDP 模式同样经过 WithDialer 设置的拨号器，例如节点出站
+func WithNetwork(network string) ClientOption {
+	return func(c *Client) {
+		c.network = network
+	}
+}
+
 // WithDialer 设置自定义拨号器选项
 // 通过此选项可以自定义TCP连接的各种参数，例如：
 // - 设置本地地址
@@ -113,10 +123,10 @@ func (c *Client) Connect(ctx context.Context) error {
 
 	// 使用自定义拨号器或创建默认拨号器
 	if c.dialer != nil {
-		conn, err = c.dialer(ctxTimeout, "tcp", metadata.ParseSocksaddr(c.serverAddr))
+		conn, err = c.dialer(ctxTimeout, c.network, metadata.ParseSocksaddr(c.serverAddr))
 	} else {
 		var d net.Dialer
-		conn, err = d.DialContext(ctxTimeout, "tcp", c.serverAddr)
+		conn, err = d.DialContext(ctxTimeout, c.network, c.serverAddr)
 	}
 
 	elapsed := time.Since(start)
@@ -166,6 +176,10 @@ func (c *Client) Send(ctx context.Context, data []byte) ([]byte, time.Duration,
 		return nil, 0, fmt.Errorf("未连接到服务器")
 	}
 
+	if c.network == "udp" {
+		return c.sendUDP(data)
+	}
+
 	// 设置读写超时
 	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
 
@@ -267,6 +281,11 @@ func (c *Client) GetStatsSummary() string {
 	return c.stats.getSummary()
 }
 
+// GetStatsReport 返回结构化的统计报告
+func (c *Client) GetStatsReport() StatsReport {
+	return c.stats.report()
+}
+
 // ResetStats 重置所有统计信息
 func (c *Client) ResetStats() {
 	c.stats.mu.Lock()
@@ -306,74 +325,16 @@ func (s *Stats) addError() {
 }
 
 func (s *Stats) getSummary() string {
+	return s.report().String()
+}
+
+func (s *Stats) report() StatsReport {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.Count == 0 {
-		return "没有收集到任何延迟数据"
-	}
-
-	avg := s.Sum / time.Duration(s.Count)
-
-	var median time.Duration
-	if len(s.Latencies) > 0 {
-		// 复制切片，避免修改原始数据
-		sorted := make([]time.Duration, len(s.Latencies))
-		copy(sorted, s.Latencies)
-		sort.Slice(sorted, func(i, j int) bool {
-			return sorted[i] < sorted[j]
-		})
-		median = sorted[len(sorted)/2]
-	}
-
-	var jitter time.Duration
-	if len(s.Latencies) > 1 {
-		var sum time.Duration
-		prev := s.Latencies[0]
-		for i := 1; i < len(s.Latencies); i++ {
-			diff := s.Latencies[i] - prev
-			if diff < 0 {
-				diff = -diff
-			}
-			sum += diff
-			prev = s.Latencies[i]
-		}
-		jitter = sum / time.Duration(len(s.Latencies)-1)
-	}
-
-	// 计算标准差
-	var variance float64
-	mean := float64(avg)
-	for _, v := range s.Latencies {
-		variance += (float64(v) - mean) * (float64(v) - mean)
-	}
-	variance /= float64(len(s.Latencies))
-	stdDev := time.Duration(math.Sqrt(variance))
-
-	return fmt.Sprintf(`
-延迟统计:
-  连接建立时间: %s
-  发送包数: %d
-  接收包数: %d
-  丢包率: %.2f%%
-  最小延迟: %s
-  最大延迟: %s
-  平均延迟: %s
-  中位数延迟: %s
-  抖动: %s
-  标准差: %s
-`,
-		s.ConnectTime,
-		s.Count+s.Errors,
-		s.Count,
-		float64(s.Errors)*100/float64(s.Count+s.Errors),
-		s.Min,
-		s.Max,
-		avg,
-		median,
-		jitter,
-		stdDev,
-	)
+	report := NewReport(s.Latencies, s.Errors)
+	report.ConnectTime = s.ConnectTime
+	return report
 }
 
 // 比较两个数据切片是否相等
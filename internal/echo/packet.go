@@ -0,0 +1,40 @@
+package echo
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// UDP 测试包头：序号(4) + 客户端发送时间(8) + 服务端接收时间(8)，时间均为 UnixNano
+// 服务端接收时间由 Server 回显时写入，普通回显服务保留为 0
+const headerSize = 20
+
+type packet struct {
+	Seq        uint32
+	Sent       int64
+	ServerRecv int64
+}
+
+func (p packet) marshal(b []byte) {
+	binary.BigEndian.PutUint32(b[0:], p.Seq)
+	binary.BigEndian.PutUint64(b[4:], uint64(p.Sent))
+	binary.BigEndian.PutUint64(b[12:], uint64(p.ServerRecv))
+}
+
+func unmarshalPacket(b []byte) (packet, bool) {
+	if len(b) < headerSize {
+		return packet{}, false
+	}
+	return packet{
+		Seq:        binary.BigEndian.Uint32(b[0:]),
+		Sent:       int64(binary.BigEndian.Uint64(b[4:])),
+		ServerRecv: int64(binary.BigEndian.Uint64(b[12:])),
+	}, true
+}
+
+// stampPacket 在回显前写入服务端接收时间
+func stampPacket(b []byte, now time.Time) {
+	if len(b) >= headerSize {
+		binary.BigEndian.PutUint64(b[12:], uint64(now.UnixNano()))
+	}
+}
@@ -0,0 +1,218 @@
+package echo
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// histogramBounds 直方图各桶的上界，超过最后一个上界的样本落入溢出桶
+var histogramBounds = []time.Duration{
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	30 * time.Millisecond,
+	50 * time.Millisecond,
+	75 * time.Millisecond,
+	100 * time.Millisecond,
+	150 * time.Millisecond,
+	200 * time.Millisecond,
+	300 * time.Millisecond,
+	500 * time.Millisecond,
+}
+
+// Bucket 直方图的一个桶，统计延迟不超过 Upper 的样本数，Upper 为 0 表示溢出桶
+type Bucket struct {
+	Upper time.Duration `json:"upper"`
+	Count int           `json:"count"`
+}
+
+// StatsReport 延迟统计报告，时间字段在 JSON 中以纳秒表示
+type StatsReport struct {
+	ConnectTime time.Duration `json:"connectTime"`
+	Sent        int           `json:"sent"`
+	Received    int           `json:"received"`
+	LossRate    float64       `json:"lossRate"` // 百分比
+	Min         time.Duration `json:"min"`
+	Max         time.Duration `json:"max"`
+	Mean        time.Duration `json:"mean"`
+	Median      time.Duration `json:"median"`
+	P90         time.Duration `json:"p90"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+	Jitter      time.Duration `json:"jitter"` // 相邻样本差值按 RFC 3550 平滑
+	StdDev      time.Duration `json:"stdDev"`
+	Histogram   []Bucket      `json:"histogram"`
+}
+
+// NewReport 由按时间顺序排列的延迟样本和失败次数生成报告
+func NewReport(latencies []time.Duration, errors int) StatsReport {
+	report := StatsReport{
+		Sent:      len(latencies) + errors,
+		Received:  len(latencies),
+		Histogram: make([]Bucket, len(histogramBounds)+1),
+	}
+	for i, upper := range histogramBounds {
+		report.Histogram[i].Upper = upper
+	}
+	if report.Sent > 0 {
+		report.LossRate = float64(errors) * 100 / float64(report.Sent)
+	}
+	if len(latencies) == 0 {
+		return report
+	}
+
+	var sum time.Duration
+	var jitter float64
+	for i, v := range latencies {
+		sum += v
+		if i > 0 {
+			d := math.Abs(float64(v - latencies[i-1]))
+			jitter += (d - jitter) / 16
+		}
+		bucket := sort.Search(len(histogramBounds), func(j int) bool {
+			return v <= histogramBounds[j]
+		})
+		report.Histogram[bucket].Count++
+	}
+	report.Jitter = time.Duration(jitter)
+	report.Mean = sum / time.Duration(len(latencies))
+
+	var variance float64
+	mean := float64(report.Mean)
+	for _, v := range latencies {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	variance /= float64(len(latencies))
+	report.StdDev = time.Duration(math.Sqrt(variance))
+
+	// 复制切片，避免修改原始数据
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	report.Min = sorted[0]
+	report.Max = sorted[len(sorted)-1]
+	report.Median = sorted[len(sorted)/2]
+	report.P90 = percentile(sorted, 90)
+	report.P95 = percentile(sorted, 95)
+	report.P99 = percentile(sorted, 99)
+	return report
+}
+
+// percentile 最近秩法，sorted 需已升序排列
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// String 渲染为文本摘要
+func (r StatsReport) String() string {
+	if r.Received == 0 {
+		return "没有收集到任何延迟数据"
+	}
+	var histogram strings.Builder
+	lower := time.Duration(0)
+	for _, b := range r.Histogram {
+		if b.Count == 0 {
+			lower = b.Upper
+			continue
+		}
+		if b.Upper == 0 {
+			fmt.Fprintf(&histogram, "    >%s: %d\n", lower, b.Count)
+		} else {
+			fmt.Fprintf(&histogram, "    %s-%s: %d\n", lower, b.Upper, b.Count)
+		}
+		lower = b.Upper
+	}
+	return fmt.Sprintf(`
+延迟统计:
+  连接建立时间: %s
+  发送包数: %d
+  接收包数: %d
+  丢包率: %.2f%%
+  最小延迟: %s
+  最大延迟: %s
+  平均延迟: %s
+  中位数延迟: %s
+  P90/P95/P99: %s / %s / %s
+  抖动: %s
+  标准差: %s
+  分布:
+%s`,
+		r.ConnectTime,
+		r.Sent,
+		r.Received,
+		r.LossRate,
+		r.Min,
+		r.Max,
+		r.Mean,
+		r.Median,
+		r.P90, r.P95, r.P99,
+		r.Jitter,
+		r.StdDev,
+		histogram.String(),
+	)
+}
+
+// Window 滑动窗口统计，只保留最近 size 个样本，用于持续监测
+type Window struct {
+	mu      sync.Mutex
+	samples []time.Duration // 丢失的样本记为 -1
+	next    int
+	full    bool
+}
+
+// NewWindow 创建大小为 size 的滑动窗口
+func NewWindow(size int) *Window {
+	if size <= 0 {
+		size = 1
+	}
+	return &Window{samples: make([]time.Duration, size)}
+}
+
+// Add 记录一个延迟样本
+func (w *Window) Add(d time.Duration) {
+	w.add(d)
+}
+
+// AddLoss 记录一次丢包或失败
+func (w *Window) AddLoss() {
+	w.add(-1)
+}
+
+func (w *Window) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % len(w.samples)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+// Report 按时间顺序统计窗口内的样本
+func (w *Window) Report() StatsReport {
+	w.mu.Lock()
+	ordered := w.samples[:w.next]
+	if w.full {
+		ordered = append(append([]time.Duration{}, w.samples[w.next:]...), w.samples[:w.next]...)
+	}
+	latencies := make([]time.Duration, 0, len(ordered))
+	errors := 0
+	for _, d := range ordered {
+		if d < 0 {
+			errors++
+		} else {
+			latencies = append(latencies, d)
+		}
+	}
+	w.mu.Unlock()
+	return NewReport(latencies, errors)
+}
@@ -0,0 +1,91 @@
+package echo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ms(v ...int) []time.Duration {
+	d := make([]time.Duration, len(v))
+	for i := range v {
+		d[i] = time.Duration(v[i]) * time.Millisecond
+	}
+	return d
+}
+
+func TestNewReport(t *testing.T) {
+	// 1..100ms，再加 25 次失败
+	latencies := make([]int, 100)
+	for i := range latencies {
+		latencies[i] = i + 1
+	}
+	r := NewReport(ms(latencies...), 25)
+	checks := map[string][2]time.Duration{
+		"min":    {r.Min, time.Millisecond},
+		"max":    {r.Max, 100 * time.Millisecond},
+		"median": {r.Median, 51 * time.Millisecond},
+		"p90":    {r.P90, 90 * time.Millisecond},
+		"p95":    {r.P95, 95 * time.Millisecond},
+		"p99":    {r.P99, 99 * time.Millisecond},
+		"mean":   {r.Mean, 50500 * time.Microsecond},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %s, want %s", name, c[0], c[1])
+		}
+	}
+	if r.Sent != 125 || r.Received != 100 || r.LossRate != 20 {
+		t.Errorf("unexpected counts %+v", r)
+	}
+	total := 0
+	for _, b := range r.Histogram {
+		total += b.Count
+	}
+	if total != 100 || r.Histogram[0].Count != 10 || r.Histogram[len(r.Histogram)-1].Count != 0 {
+		t.Errorf("unexpected histogram %+v", r.Histogram)
+	}
+	// 相邻差值恒为 1ms，平滑后趋近 1ms
+	if r.Jitter <= 900*time.Microsecond || r.Jitter > time.Millisecond {
+		t.Errorf("unexpected jitter %s", r.Jitter)
+	}
+}
+
+func TestReportJSONAndString(t *testing.T) {
+	r := NewReport(ms(10, 20, 600), 0)
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded StatsReport
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.P99 != r.P99 || len(decoded.Histogram) != len(r.Histogram) {
+		t.Errorf("round trip mismatch %+v", decoded)
+	}
+	text := r.String()
+	for _, want := range []string{"平均延迟: 210ms", ">500ms: 1"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("summary missing %q:\n%s", want, text)
+		}
+	}
+	if NewReport(nil, 3).String() != "没有收集到任何延迟数据" {
+		t.Error("unexpected empty summary")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	w := NewWindow(4)
+	for _, d := range ms(100, 100, 10, 20) {
+		w.Add(d)
+	}
+	w.AddLoss()
+	w.Add(30 * time.Millisecond)
+	// 窗口内剩余 10, 20, 丢失, 30
+	r := w.Report()
+	if r.Sent != 4 || r.Received != 3 || r.Max != 30*time.Millisecond || r.Min != 10*time.Millisecond {
+		t.Errorf("unexpected window report %+v", r)
+	}
+}
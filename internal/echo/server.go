@@ -0,0 +1,225 @@
+package echo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Server TCP/UDP Echo 服务端，可以部署在节点旁作为测速点，也可以在测试中直接运行
+type Server struct {
+	mu         sync.Mutex
+	closers    []io.Closer
+	closed     bool
+	timestamps bool
+	limit      rate.Limit
+	burst      int
+	limiters   map[string]*clientLimiter
+	lastSweep  time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ServerOption 是Server的选项函数类型
+type ServerOption func(*Server)
+
+// limiterIdle 超过该时间未活动的客户端限速器会被清理
+const limiterIdle = time.Minute
+
+// minBurst 突发额度的下限，至少放行一次读取，即一个 MTU 大小的 UDP 包
+const minBurst = 1500
+
+// DefaultRate 独立部署时每个客户端每秒回显的字节数，足够延迟测试，又不会被用作反射放大
+const DefaultRate = minBurst
+
+// NewServer 创建 Echo 服务端，默认原样回显且不限速
+func NewServer(options ...ServerOption) *Server {
+	s := &Server{
+		limit:    rate.Inf,
+		limiters: make(map[string]*clientLimiter),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// WithRateLimit 按客户端 IP 限制每秒回显的字节数，burst 为允许的突发字节数，最小为 minBurst
+// UDP 超出限制的包直接丢弃，大于 burst 的包始终被丢弃；TCP 则放慢回显速度
+func WithRateLimit(bytesPerSecond int, burst int) ServerOption {
+	return func(s *Server) {
+		s.limit = rate.Limit(bytesPerSecond)
+		s.burst = max(burst, minBurst)
+	}
+}
+
+// WithTimestamps 开启时间戳反射，UDP 回显时在包头写入服务端接收时间，
+// 客户端据此计算去程单向抖动。TCP 是字节流，始终原样回显
+func WithTimestamps() ServerOption {
+	return func(s *Server) {
+		s.timestamps = true
+	}
+}
+
+// ListenUDP 监听 UDP 地址并在后台回显，返回实际监听地址
+func (s *Server) ListenUDP(addr string) (net.Addr, error) {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() { _ = s.ServeUDP(conn) }()
+	return conn.LocalAddr(), nil
+}
+
+// ListenTCP 监听 TCP 地址并在后台回显，返回实际监听地址
+func (s *Server) ListenTCP(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() { _ = s.ServeTCP(listener) }()
+	return listener.Addr(), nil
+}
+
+func (s *Server) track(c io.Closer) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.closers = append(s.closers, c)
+	return true
+}
+
+func (s *Server) untrack(c io.Closer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, closer := range s.closers {
+		if closer == c {
+			s.closers = append(s.closers[:i], s.closers[i+1:]...)
+			return
+		}
+	}
+}
+
+// limiter 返回客户端 IP 对应的限速器，未限速时返回 nil
+func (s *Server) limiter(addr net.Addr) *rate.Limiter {
+	if s.limit == rate.Inf {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > limiterIdle {
+		for key, c := range s.limiters {
+			if now.Sub(c.lastSeen) > limiterIdle {
+				delete(s.limiters, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	c, ok := s.limiters[host]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[host] = c
+	}
+	c.lastSeen = now
+	return c.limiter
+}
+
+// ServeUDP 在给定连接上回显，直到连接关闭
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	if !s.track(conn) {
+		return net.ErrClosed
+	}
+	defer s.untrack(conn)
+	buffer := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if l := s.limiter(addr); l != nil && !l.AllowN(time.Now(), n) {
+			continue
+		}
+		if s.timestamps {
+			stampPacket(buffer[:n], time.Now())
+		}
+		_, _ = conn.WriteTo(buffer[:n], addr)
+	}
+}
+
+// ServeTCP 接受连接并逐个回显，直到监听关闭
+func (s *Server) ServeTCP(listener net.Listener) error {
+	if !s.track(listener) {
+		return net.ErrClosed
+	}
+	defer s.untrack(listener)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		if !s.track(conn) {
+			_ = conn.Close()
+			return nil
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.untrack(conn)
+	defer func() { _ = conn.Close() }()
+	l := s.limiter(conn.RemoteAddr())
+	buffer := make([]byte, 32*1024)
+	if l != nil {
+		// 每次读取不超过突发额度，读到的数据都能等到令牌
+		buffer = buffer[:min(len(buffer), l.Burst())]
+	}
+	for {
+		n, err := conn.Read(buffer)
+		if n > 0 {
+			if l != nil && l.WaitN(context.Background(), n) != nil {
+				return
+			}
+			if _, werr := conn.Write(buffer[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Close 关闭所有监听和连接
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	var err error
+	for _, c := range s.closers {
+		err = errors.Join(err, c.Close())
+	}
+	s.closers = nil
+	return err
+}
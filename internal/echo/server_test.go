@@ -0,0 +1,120 @@
+package echo
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServerTCP(t *testing.T) {
+	server := NewServer()
+	defer func() { _ = server.Close() }()
+	addr, err := server.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(addr.String(), WithTimeout(time.Second))
+	if err = client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	results := client.MultiTest(context.Background(), []byte("hello echo"), 5, time.Millisecond)
+	for _, result := range results {
+		if !result.Success || !result.IsMatching {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	if report := client.GetStatsReport(); report.Received != 5 || report.LossRate != 0 {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
+
+func TestServerTimestamps(t *testing.T) {
+	for _, reflect := range []bool{false, true} {
+		var options []ServerOption
+		if reflect {
+			options = append(options, WithTimestamps())
+		}
+		server := NewServer(options...)
+		addr, err := server.ListenUDP("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := net.Dial("udp", addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, headerSize)
+		packet{Seq: 1, Sent: time.Now().UnixNano()}.marshal(b)
+		_, _ = conn.Write(b)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _ := unmarshalPacket(b[:n])
+		if (p.ServerRecv != 0) != reflect {
+			t.Errorf("timestamps=%v: unexpected server timestamp %d", reflect, p.ServerRecv)
+		}
+		_ = conn.Close()
+		_ = server.Close()
+	}
+}
+
+func TestServerRateLimit(t *testing.T) {
+	// 每秒 1 字节，只有突发额度内的包会被回显，每个包 1020 字节
+	for _, c := range []struct {
+		burst    int
+		received int
+	}{
+		{0, 1}, // 最小为 1500 字节
+		{4096, 4},
+		{65535, 64},
+	} {
+		server := NewServer(WithRateLimit(1, c.burst))
+		addr, err := server.ListenUDP("127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := NewClient(addr.String(), WithNetwork("udp"), WithTimeout(200*time.Millisecond))
+		if err = client.Connect(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := client.Stream(context.Background(), make([]byte, 1000), 100, 100*time.Microsecond)
+		_ = client.Close()
+		_ = server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Received != c.received || stats.Lost != 100-c.received {
+			t.Errorf("burst %d: unexpected stats %+v", c.burst, stats)
+		}
+	}
+}
+
+func TestServerClose(t *testing.T) {
+	server := NewServer()
+	addr, err := server.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// 等待服务端接受连接
+	_, _ = conn.Write([]byte("x"))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = conn.Read(make([]byte, 1))
+	if err = server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.Read(make([]byte, 1)); err == nil {
+		t.Error("connection still open after Close")
+	}
+	if _, err = net.Dial("tcp", addr.String()); err == nil {
+		t.Error("listener still open after Close")
+	}
+}
@@ -0,0 +1,210 @@
+package echo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SequenceStats UDP 包序列统计
+type SequenceStats struct {
+	Sent       int           `json:"sent"`
+	Received   int           `json:"received"`
+	Lost       int           `json:"lost"`
+	Reordered  int           `json:"reordered"`
+	Duplicates int           `json:"duplicates"`
+	LossRate   float64       `json:"lossRate"`
+	Jitter     time.Duration `json:"jitter"` // 去程单向抖动，按 RFC 3550 计算
+}
+
+// sequence 记录已发送和已收到的序号
+type sequence struct {
+	next       uint32
+	sent       int
+	received   map[uint32]struct{}
+	highest    uint32
+	reordered  int
+	duplicates int
+	// RFC 3550 抖动估计，单位纳秒
+	jitter      float64
+	lastTransit int64
+	hasTransit  bool
+}
+
+func newSequence() *sequence {
+	return &sequence{received: make(map[uint32]struct{})}
+}
+
+// add 记录一个回显包，返回是否为首次收到
+func (s *sequence) add(p packet, arrival time.Time) bool {
+	if _, ok := s.received[p.Seq]; ok {
+		s.duplicates++
+		return false
+	}
+	if len(s.received) > 0 && p.Seq < s.highest {
+		s.reordered++
+	}
+	s.received[p.Seq] = struct{}{}
+	if p.Seq > s.highest {
+		s.highest = p.Seq
+	}
+	// 服务端写入了接收时间时计算去程抖动，否则退化为往返抖动
+	// 两端时钟偏差在相邻包的差值中抵消
+	transit := arrival.UnixNano() - p.Sent
+	if p.ServerRecv != 0 {
+		transit = p.ServerRecv - p.Sent
+	}
+	if s.hasTransit {
+		d := float64(transit - s.lastTransit)
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += (d - s.jitter) / 16
+	}
+	s.lastTransit = transit
+	s.hasTransit = true
+	return true
+}
+
+func (s *sequence) stats() SequenceStats {
+	stats := SequenceStats{
+		Sent:       s.sent,
+		Received:   len(s.received),
+		Reordered:  s.reordered,
+		Duplicates: s.duplicates,
+		Jitter:     time.Duration(s.jitter),
+	}
+	stats.Lost = stats.Sent - stats.Received
+	if stats.Sent > 0 {
+		stats.LossRate = float64(stats.Lost) * 100 / float64(stats.Sent)
+	}
+	return stats
+}
+
+// newPacket 生成带序号和时间戳的 UDP 测试包，data 作为负载
+func (s *sequence) newPacket(data []byte) (packet, []byte) {
+	p := packet{Seq: s.next, Sent: time.Now().UnixNano()}
+	s.next++
+	s.sent++
+	b := make([]byte, headerSize+len(data))
+	p.marshal(b)
+	copy(b[headerSize:], data)
+	return p, b
+}
+
+// sendUDP 发送一个测试包并等待同序号的回显，期间收到的其他包计入序列统计
+func (c *Client) sendUDP(data []byte) ([]byte, time.Duration, error) {
+	p, b := c.seq.newPacket(data)
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	if _, err := c.conn.Write(b); err != nil {
+		c.stats.addError()
+		return nil, 0, fmt.Errorf("发送失败: %w", err)
+	}
+	buffer := make([]byte, 65535)
+	for {
+		n, err := c.conn.Read(buffer)
+		arrival := time.Now()
+		if err != nil {
+			c.stats.addError()
+			return nil, time.Duration(arrival.UnixNano() - p.Sent), fmt.Errorf("接收失败: %w", err)
+		}
+		resp, ok := unmarshalPacket(buffer[:n])
+		if !ok || !c.seq.add(resp, arrival) || resp.Seq != p.Seq {
+			continue
+		}
+		elapsed := time.Duration(arrival.UnixNano() - p.Sent)
+		c.stats.addLatency(elapsed)
+		return append([]byte{}, buffer[headerSize:n]...), elapsed, nil
+	}
+}
+
+// Stream 以固定间隔连续发送 count 个 UDP 测试包，同时接收回显，
+// 最后一个包发出后再等待一个超时时间，未收到的包计为丢失
+// 只在 UDP 模式下可用，返回本轮的序列统计
+func (c *Client) Stream(ctx context.Context, data []byte, count int, interval time.Duration) (SequenceStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return SequenceStats{}, fmt.Errorf("未连接到服务器")
+	}
+	if c.network != "udp" {
+		return SequenceStats{}, fmt.Errorf("仅 UDP 模式支持连续测试")
+	}
+	seq := newSequence()
+	base := c.seq.next
+	seq.next = base
+	c.seq.next += uint32(count)
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(time.Duration(count)*interval + c.timeout))
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	var mu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for i := 0; i < count; i++ {
+			mu.Lock()
+			_, b := seq.newPacket(data)
+			mu.Unlock()
+			if _, err := c.conn.Write(b); err != nil {
+				return
+			}
+			if i == count-1 {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+		// 发送结束后最多再等待一个超时时间
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, err := c.conn.Read(buffer)
+		arrival := time.Now()
+		if err != nil {
+			break
+		}
+		resp, ok := unmarshalPacket(buffer[:n])
+		// 忽略之前测试遗留的迟到包
+		if !ok || resp.Seq < base || resp.Seq-base >= uint32(count) {
+			continue
+		}
+		mu.Lock()
+		if seq.add(resp, arrival) {
+			c.stats.addLatency(time.Duration(arrival.UnixNano() - resp.Sent))
+		}
+		finished := len(seq.received) == count
+		mu.Unlock()
+		if finished {
+			break
+		}
+	}
+	// 中断读取等待发送协程退出
+	_ = c.conn.SetReadDeadline(time.Now())
+	<-done
+
+	stats := seq.stats()
+	for i := 0; i < stats.Lost; i++ {
+		c.stats.addError()
+	}
+	return stats, ctx.Err()
+}
+
+// SequenceStats 返回 UDP 模式下单包测试累计的序列统计
+func (c *Client) SequenceStats() SequenceStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seq.stats()
+}
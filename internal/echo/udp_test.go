@@ -0,0 +1,106 @@
+package echo
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPEcho(t *testing.T) {
+	server := NewServer()
+	defer func() { _ = server.Close() }()
+	addr, err := server.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(addr.String(), WithNetwork("udp"), WithTimeout(time.Second))
+	if err = client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	result := client.Test(context.Background(), []byte("ping"))
+	if !result.Success || !result.IsMatching {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	stats, err := client.Stream(context.Background(), []byte("ping"), 20, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sent != 20 || stats.Received != 20 || stats.Lost != 0 || stats.Duplicates != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+// impairedServer 丢弃、重复并乱序回显指定序号的包
+func impairedServer(t *testing.T, drop map[uint32]bool, dup uint32, hold, release uint32) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		var held []byte
+		buffer := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			p, ok := unmarshalPacket(buffer[:n])
+			if !ok || drop[p.Seq] {
+				continue
+			}
+			stampPacket(buffer[:n], time.Now())
+			switch p.Seq {
+			case hold:
+				held = append([]byte{}, buffer[:n]...)
+				continue
+			case dup:
+				_, _ = conn.WriteTo(buffer[:n], addr)
+			}
+			_, _ = conn.WriteTo(buffer[:n], addr)
+			if p.Seq == release {
+				_, _ = conn.WriteTo(held, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestUDPStreamImpaired(t *testing.T) {
+	addr := impairedServer(t, map[uint32]bool{4: true, 9: true}, 2, 1, 3)
+	client := NewClient(addr, WithNetwork("udp"), WithTimeout(200*time.Millisecond))
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	stats, err := client.Stream(context.Background(), make([]byte, 32), 10, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SequenceStats{Sent: 10, Received: 8, Lost: 2, Reordered: 1, Duplicates: 1, LossRate: 20}
+	stats.Jitter = 0
+	if stats != want {
+		t.Errorf("got %+v, want %+v", stats, want)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	addr := impairedServer(t, map[uint32]bool{0: true}, ^uint32(0), ^uint32(0), ^uint32(0))
+	client := NewClient(addr, WithNetwork("udp"), WithTimeout(10*time.Second))
+	if err := client.Connect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Stream(ctx, nil, 5, time.Millisecond)
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("stream not cancelled: %v after %s", err, time.Since(start))
+	}
+}
@@ -0,0 +1,82 @@
+package http_client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"sync"
+)
+
+// cacheMeta 缓存内容对应的地址和校验信息
+type cacheMeta struct {
+	URL string `json:"url"`
+	Validators
+}
+
+var cacheLock sync.Mutex
+
+// cacheFile 返回 target 在 http-cache 目录中的内容和元数据文件
+func cacheFile(target string) (string, string) {
+	sum := sha256.Sum256([]byte(target))
+	dir := filepath.Join(path.Path(), "http-cache")
+	_ = os.MkdirAll(dir, 0755)
+	name := filepath.Join(dir, hex.EncodeToString(sum[:8]))
+	return name, name + ".json"
+}
+
+// GetCached 使用默认客户端请求 target，并在磁盘上缓存内容
+func GetCached(ctx context.Context, target string) ([]byte, error) {
+	return defaultClient.GetCached(ctx, target)
+}
+
+// GetCached 有缓存时携带 ETag 和 Last-Modified 发起条件请求，未变化时返回缓存的内容
+// 请求失败时同时返回上次缓存的内容和错误，没有缓存时内容为 nil
+func (c *Client) GetCached(ctx context.Context, target string) ([]byte, error) {
+	dataFile, metaFile := cacheFile(target)
+	cacheLock.Lock()
+	cached, meta := readCache(target, dataFile, metaFile)
+	cacheLock.Unlock()
+	res, err := c.GetConditional(ctx, target, meta.Validators)
+	if errors.Is(err, ErrNotModified) {
+		return cached, nil
+	}
+	if err != nil {
+		return cached, err
+	}
+	if res.Validators != (Validators{}) {
+		cacheLock.Lock()
+		writeCache(dataFile, metaFile, cacheMeta{URL: target, Validators: res.Validators}, res.Data)
+		cacheLock.Unlock()
+	}
+	return res.Data, nil
+}
+
+// readCache 读取缓存，内容或元数据缺失时不使用条件请求
+func readCache(target, dataFile, metaFile string) ([]byte, cacheMeta) {
+	meta := cacheMeta{}
+	data, err := os.ReadFile(metaFile)
+	if err != nil || json.Unmarshal(data, &meta) != nil || meta.URL != target {
+		return nil, cacheMeta{}
+	}
+	data, err = os.ReadFile(dataFile)
+	if err != nil {
+		return nil, cacheMeta{}
+	}
+	return data, meta
+}
+
+// writeCache 先写内容再写元数据，中途失败时下次会重新完整下载
+func writeCache(dataFile, metaFile string, meta cacheMeta, data []byte) {
+	_ = os.Remove(metaFile)
+	if os.WriteFile(dataFile, data, 0644) != nil {
+		return
+	}
+	if encoded, err := json.Marshal(meta); err == nil {
+		_ = os.WriteFile(metaFile, encoded, 0644)
+	}
+}
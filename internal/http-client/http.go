@@ -1,42 +1,298 @@
 package http_client
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
 	"sync"
 	"time"
 )
 
 var directClient *http.Client
+var proxyURL *url.URL
+var userAgent = userAgentOf("dev")
 var lock sync.Mutex
 
+// ErrNotModified 条件请求时服务端返回 304
+var ErrNotModified = errors.New("not modified")
+
+// ErrTooLarge 响应超过允许的最大长度
+var ErrTooLarge = errors.New("响应内容过大")
+
+// StatusError 服务端返回了非 2xx 状态码
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("GET %s: %s", e.URL, e.Status)
+}
+
+// SetProxy 设置请求使用的上游代理，支持 http 和 socks5，nil 表示直连
+func SetProxy(u *url.URL) {
+	lock.Lock()
+	defer lock.Unlock()
+	proxyURL = u
+	directClient = nil
+}
+
+// SetVersion 设置 User-Agent 中的应用版本
+func SetVersion(version string) {
+	lock.Lock()
+	defer lock.Unlock()
+	userAgent = userAgentOf(version)
+}
+
+func userAgentOf(version string) string {
+	return fmt.Sprintf("PlayFast/%s (%s; %s)", version, runtime.GOOS, runtime.GOARCH)
+}
+
+// UserAgent 返回请求使用的 User-Agent
+func UserAgent() string {
+	lock.Lock()
+	defer lock.Unlock()
+	return userAgent
+}
+
 func clientDirect() *http.Client {
 	lock.Lock()
 	defer lock.Unlock()
 	if directClient != nil {
 		return directClient
 	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	// 超时由每次请求的 context 控制
 	directClient = &http.Client{
-		Timeout: time.Second * 30,
+		Transport: transport,
 	}
 	return directClient
 }
 
-func get(client *http.Client, url string) ([]byte, error) {
-	// 创建一个新的 GET 请求
-	req, err := http.NewRequest("GET", url, nil)
+// Client 带状态码检查、重试和大小限制的 HTTP 客户端，请求经过 SetProxy 设置的上游代理
+type Client struct {
+	timeout    time.Duration
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	maxSize    int64
+}
+
+// ClientOption 是Client的选项函数类型
+type ClientOption func(*Client)
+
+// New 创建 HTTP 客户端
+func New(options ...ClientOption) *Client {
+	c := &Client{
+		timeout:    30 * time.Second,
+		retries:    2,
+		backoff:    500 * time.Millisecond,
+		maxBackoff: 8 * time.Second,
+		maxSize:    16 << 20,
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// WithTimeout 设置单次请求的超时时间
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries 设置失败后的重试次数，只重试网络错误、408、429 和 5xx
+func WithRetries(retries int) ClientOption {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithBackoff 设置重试的初始等待时间和最长等待时间，每次重试翻倍并加入随机抖动
+func WithBackoff(backoff, maxBackoff time.Duration) ClientOption {
+	return func(c *Client) {
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// WithMaxSize 设置响应内容的最大字节数
+func WithMaxSize(maxSize int64) ClientOption {
+	return func(c *Client) {
+		c.maxSize = maxSize
+	}
+}
+
+// Validators 条件请求使用的 ETag 和 Last-Modified
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+// Response 请求成功时的内容和新的缓存校验信息
+type Response struct {
+	Data []byte
+	Validators
+}
+
+// defaultClient 控制面请求默认使用的客户端
+var defaultClient = New()
+
+// Get 使用默认客户端请求 url，返回响应内容
+func Get(ctx context.Context, target string) ([]byte, error) {
+	return defaultClient.Get(ctx, target)
+}
+
+// GetConditional 使用默认客户端发起条件请求
+func GetConditional(ctx context.Context, target string, validators Validators) (*Response, error) {
+	return defaultClient.GetConditional(ctx, target, validators)
+}
+
+// Get 请求 target，非 2xx 状态码返回 *StatusError
+func (c *Client) Get(ctx context.Context, target string) ([]byte, error) {
+	res, err := c.GetConditional(ctx, target, Validators{})
 	if err != nil {
 		return nil, err
 	}
-	// 添加自定义请求头
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/58.0.3029.110 Safari/537.36")
+	return res.Data, nil
+}
+
+// GetConditional 携带 If-None-Match 和 If-Modified-Since 请求 target
+// 按 LoadRoutes 的策略依次尝试直连、镜像和节点出站，只有最后一个尝试会重试
+// 内容未变化时返回 ErrNotModified，非 2xx 状态码返回 *StatusError
+func (c *Client) GetConditional(ctx context.Context, target string, validators Validators) (*Response, error) {
+	if u, err := url.Parse(target); err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("不支持的地址 %q", target)
+	}
+	candidates := plan(LoadRoutes(), target)
+	errs := make([]error, 0, len(candidates))
+	for i, cand := range candidates {
+		retries := 0
+		if i == len(candidates)-1 {
+			retries = c.retries
+		}
+		res, err := c.fetch(ctx, cand, validators, retries)
+		if err == nil || errors.Is(err, ErrNotModified) {
+			return res, err
+		}
+		if cand.proxy {
+			err = fmt.Errorf("经过节点: %w", err)
+		}
+		errs = append(errs, err)
+		if errors.Is(err, ErrTooLarge) || ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetch 使用指定的出口请求，失败时最多重试 retries 次
+func (c *Client) fetch(ctx context.Context, cand candidate, validators Validators, retries int) (*Response, error) {
+	client := clientDirect()
+	if cand.proxy {
+		if client = clientProxy(); client == nil {
+			return nil, ErrNoProxy
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		res, retryAfter, err := c.do(ctx, client, cand.url, validators)
+		if err == nil || attempt >= retries || !retryable(err) {
+			return res, err
+		}
+		wait := c.delay(attempt)
+		if retryAfter > 0 {
+			wait = min(retryAfter, c.maxBackoff)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// do 发起一次请求，返回服务端要求的重试等待时间
+func (c *Client) do(ctx context.Context, client *http.Client, target string, validators Validators) (*Response, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", UserAgent())
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotModified && validators != (Validators{}) {
+		return nil, 0, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return nil, time.Duration(seconds) * time.Second, &StatusError{URL: target, StatusCode: resp.StatusCode, Status: resp.Status}
 	}
-	return io.ReadAll(resp.Body)
+	if resp.ContentLength > c.maxSize {
+		return nil, 0, fmt.Errorf("GET %s: %w", target, ErrTooLarge)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, c.maxSize+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(data)) > c.maxSize {
+		return nil, 0, fmt.Errorf("GET %s: %w", target, ErrTooLarge)
+	}
+	return &Response{
+		Data: data,
+		Validators: Validators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+	}, 0, nil
 }
-func GET(url string) ([]byte, error) {
-	bytes, err := get(clientDirect(), url)
-	return bytes, err
+
+// delay 第 attempt 次重试前的等待时间，在指数退避的一半到全部之间随机
+func (c *Client) delay(attempt int) time.Duration {
+	d := c.backoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	if d < 2 {
+		return d
+	}
+	return d/2 + rand.N(d/2)
+}
+
+// retryable 是否为可以重试的错误，网络错误和单次请求超时都会重试
+func retryable(err error) bool {
+	if errors.Is(err, ErrNotModified) || errors.Is(err, ErrTooLarge) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusRequestTimeout || status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	return true
 }
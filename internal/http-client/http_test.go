@@ -0,0 +1,150 @@
+package http_client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient 创建重试间隔很短的客户端，并隔离配置目录
+func testClient(t *testing.T, options ...ClientOption) *Client {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	return New(append([]ClientOption{WithBackoff(time.Millisecond, 5*time.Millisecond)}, options...)...)
+}
+
+func TestRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	data, err := testClient(t).Get(context.Background(), server.URL)
+	if err != nil || string(data) != "ok" || requests.Load() != 3 {
+		t.Fatalf("got %q %v after %d requests", data, err, requests.Load())
+	}
+
+	// 重试次数用完后返回最后一次的状态码
+	requests.Store(0)
+	_, err = testClient(t, WithRetries(1)).Get(context.Background(), server.URL)
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusServiceUnavailable || requests.Load() != 2 {
+		t.Errorf("expected 503 after 2 requests, got %v after %d", err, requests.Load())
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	data, err := testClient(t).Get(context.Background(), server.URL)
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusNotFound || data != nil {
+		t.Errorf("expected 404 error, got %q %v", data, err)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("404 should not be retried, got %d requests", requests.Load())
+	}
+	if _, err = testClient(t).Get(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 分块传输，没有 Content-Length
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+	if _, err := testClient(t, WithMaxSize(99)).Get(context.Background(), server.URL); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if data, err := testClient(t, WithMaxSize(100)).Get(context.Background(), server.URL); err != nil || len(data) != 100 {
+		t.Errorf("unexpected result %d %v", len(data), err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := testClient(t, WithRetries(100), WithBackoff(time.Second, time.Second)).Get(ctx, server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("expected deadline exceeded quickly, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.UserAgent()
+	}))
+	defer server.Close()
+	SetVersion("1.2.3")
+	defer SetVersion("dev")
+	if _, err := testClient(t).Get(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(agent, "PlayFast/1.2.3") || !strings.Contains(agent, runtime.GOOS) {
+		t.Errorf("unexpected user agent %q", agent)
+	}
+}
+
+func TestGetCached(t *testing.T) {
+	const modified = "Mon, 02 Jan 2006 15:04:05 GMT"
+	var requests, conditional atomic.Int32
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == modified {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", modified)
+		_, _ = w.Write([]byte("rules"))
+	}))
+	defer server.Close()
+	client := testClient(t)
+	for i := 0; i < 2; i++ {
+		data, err := client.GetCached(context.Background(), server.URL)
+		if err != nil || string(data) != "rules" {
+			t.Fatalf("%d: got %q %v", i, data, err)
+		}
+	}
+	if conditional.Load() != 1 {
+		t.Errorf("second request should revalidate, got %d conditional requests", conditional.Load())
+	}
+	// 请求失败时返回上次缓存的内容和错误，不写入错误页面
+	down.Store(true)
+	data, err := client.GetCached(context.Background(), server.URL)
+	if err == nil || string(data) != "rules" {
+		t.Errorf("expected cached data with error, got %q %v", data, err)
+	}
+	if data, err = client.GetCached(context.Background(), server.URL+"/other"); err == nil || data != nil {
+		t.Errorf("expected no data without cache, got %q %v", data, err)
+	}
+}
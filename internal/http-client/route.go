@@ -0,0 +1,172 @@
+package http_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"strings"
+	"sync"
+)
+
+// Policy 控制面请求的出口策略
+type Policy string
+
+const (
+	PolicyDirect          Policy = "direct"            // 只直连
+	PolicyProxy           Policy = "proxy"             // 只经过节点出站
+	PolicyDirectThenProxy Policy = "direct-then-proxy" // 先直连，失败后经过节点出站
+)
+
+// Route 按地址前缀匹配的出口策略和镜像，保存在 http_routes.json
+type Route struct {
+	Prefix  string   `json:"prefix"`
+	Policy  Policy   `json:"policy"`
+	Mirrors []string `json:"mirrors,omitempty"` // 替换 Prefix 的镜像前缀，在原地址之后依次尝试
+}
+
+// defaultRoutes 没有配置时的策略，GitHub 的规则文件在国内经常无法直连
+var defaultRoutes = []Route{
+	{Prefix: "https://raw.githubusercontent.com/", Policy: PolicyDirectThenProxy},
+}
+
+// ProxyDialer 经过节点出站建立连接
+type ProxyDialer func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// ErrNoProxy 没有可用的节点出站
+var ErrNoProxy = errors.New("没有可用的节点出站")
+
+var proxyDialer ProxyDialer
+var proxyClient *http.Client
+var routesLock sync.Mutex
+
+// SetProxyDialer 设置策略为 proxy 时使用的拨号函数，nil 表示不可用
+func SetProxyDialer(dial ProxyDialer) {
+	lock.Lock()
+	defer lock.Unlock()
+	proxyDialer = dial
+	proxyClient = nil
+}
+
+// clientProxy 返回经过节点出站的客户端，节点出站已经处理了上游代理
+func clientProxy() *http.Client {
+	lock.Lock()
+	defer lock.Unlock()
+	if proxyDialer == nil {
+		return nil
+	}
+	if proxyClient != nil {
+		return proxyClient
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = proxyDialer
+	proxyClient = &http.Client{
+		Transport: transport,
+	}
+	return proxyClient
+}
+
+func routesFile() string {
+	return filepath.Join(path.Path(), "http_routes.json")
+}
+
+// LoadRoutes 读取控制面请求的出口策略，没有配置时返回默认策略
+func LoadRoutes() []Route {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	data, err := os.ReadFile(routesFile())
+	if err != nil {
+		return append([]Route(nil), defaultRoutes...)
+	}
+	routes := make([]Route, 0)
+	if json.Unmarshal(data, &routes) != nil {
+		return append([]Route(nil), defaultRoutes...)
+	}
+	return routes
+}
+
+// SaveRoutes 校验并保存出口策略，nil 表示恢复默认
+func SaveRoutes(routes []Route) error {
+	for _, route := range routes {
+		if err := route.validate(); err != nil {
+			return err
+		}
+	}
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	if routes == nil {
+		err := os.Remove(routesFile())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(routes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(routesFile(), data, 0644)
+}
+
+func (r Route) validate() error {
+	switch r.Policy {
+	case PolicyDirect, PolicyProxy, PolicyDirectThenProxy:
+	default:
+		return fmt.Errorf("%s: 不支持的策略 %q", r.Prefix, r.Policy)
+	}
+	for _, prefix := range append([]string{r.Prefix}, r.Mirrors...) {
+		if u, err := url.Parse(prefix); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("无效的地址前缀 %q", prefix)
+		}
+	}
+	return nil
+}
+
+// match 返回前缀最长的匹配策略，没有匹配时直连
+func match(routes []Route, target string) Route {
+	matched := Route{Policy: PolicyDirect}
+	for _, route := range routes {
+		if strings.HasPrefix(target, route.Prefix) && len(route.Prefix) > len(matched.Prefix) {
+			matched = route
+		}
+	}
+	return matched
+}
+
+// candidate 一次请求的地址和出口
+type candidate struct {
+	url   string
+	proxy bool
+}
+
+// plan 按策略展开请求顺序，每种出口先尝试原地址再尝试镜像
+func plan(routes []Route, target string) []candidate {
+	route := match(routes, target)
+	urls := []string{target}
+	for _, mirror := range route.Mirrors {
+		urls = append(urls, mirror+strings.TrimPrefix(target, route.Prefix))
+	}
+	var exits []bool
+	switch route.Policy {
+	case PolicyProxy:
+		exits = []bool{true}
+	case PolicyDirectThenProxy:
+		exits = []bool{false, true}
+	default:
+		exits = []bool{false}
+	}
+	candidates := make([]candidate, 0, len(urls)*len(exits))
+	for _, proxy := range exits {
+		for _, u := range urls {
+			candidates = append(candidates, candidate{url: u, proxy: proxy})
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,121 @@
+package http_client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	routes := []Route{
+		{Prefix: "https://raw.githubusercontent.com/", Policy: PolicyDirectThenProxy, Mirrors: []string{"https://mirror.example.com/gh/"}},
+		{Prefix: "https://raw.githubusercontent.com/private/", Policy: PolicyProxy},
+	}
+	want := []candidate{
+		{url: "https://raw.githubusercontent.com/a/b.srs"},
+		{url: "https://mirror.example.com/gh/a/b.srs"},
+		{url: "https://raw.githubusercontent.com/a/b.srs", proxy: true},
+		{url: "https://mirror.example.com/gh/a/b.srs", proxy: true},
+	}
+	if got := plan(routes, "https://raw.githubusercontent.com/a/b.srs"); !equalCandidates(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// 前缀最长的策略优先
+	if got := plan(routes, "https://raw.githubusercontent.com/private/c"); !equalCandidates(got, []candidate{{url: "https://raw.githubusercontent.com/private/c", proxy: true}}) {
+		t.Errorf("unexpected plan %+v", got)
+	}
+	if got := plan(routes, "https://api.example.com/proxy.json"); !equalCandidates(got, []candidate{{url: "https://api.example.com/proxy.json"}}) {
+		t.Errorf("unexpected plan %+v", got)
+	}
+}
+
+func equalCandidates(a, b []candidate) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSaveRoutes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	for _, route := range []Route{
+		{Prefix: "https://example.com/", Policy: "bogus"},
+		{Prefix: "example.com", Policy: PolicyDirect},
+		{Prefix: "https://example.com/", Policy: PolicyDirect, Mirrors: []string{"ftp://mirror/"}},
+	} {
+		if err := SaveRoutes([]Route{route}); err == nil {
+			t.Errorf("expected error for %+v", route)
+		}
+	}
+	routes := []Route{{Prefix: "https://example.com/", Policy: PolicyProxy}}
+	if err := SaveRoutes(routes); err != nil {
+		t.Fatal(err)
+	}
+	if got := LoadRoutes(); len(got) != 1 || got[0].Policy != PolicyProxy {
+		t.Errorf("unexpected routes %+v", got)
+	}
+	if err := SaveRoutes(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := LoadRoutes(); len(got) != len(defaultRoutes) {
+		t.Errorf("expected default routes, got %+v", got)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer blocked.Close()
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("rules" + r.URL.Path))
+	}))
+	defer good.Close()
+	client := testClient(t)
+
+	// 直连失败后使用镜像
+	if err := SaveRoutes([]Route{{Prefix: blocked.URL + "/", Policy: PolicyDirect, Mirrors: []string{good.URL + "/mirror/"}}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Get(context.Background(), blocked.URL+"/geoip.srs")
+	if err != nil || string(data) != "rules/mirror/geoip.srs" {
+		t.Errorf("mirror: got %q %v", data, err)
+	}
+
+	// 直连失败后经过节点，这里用连接到另一个服务模拟节点出站
+	if err = SaveRoutes([]Route{{Prefix: blocked.URL + "/", Policy: PolicyDirectThenProxy}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.Get(context.Background(), blocked.URL+"/geoip.srs"); !errors.Is(err, ErrNoProxy) {
+		t.Errorf("expected ErrNoProxy without dialer, got %v", err)
+	}
+	var dialed string
+	SetProxyDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = addr
+		return (&net.Dialer{}).DialContext(ctx, network, strings.TrimPrefix(good.URL, "http://"))
+	})
+	defer SetProxyDialer(nil)
+	data, err = client.Get(context.Background(), blocked.URL+"/geoip.srs")
+	if err != nil || string(data) != "rules/geoip.srs" || dialed != strings.TrimPrefix(blocked.URL, "http://") {
+		t.Errorf("proxy: got %q %v via %q", data, err, dialed)
+	}
+
+	// 只使用直连时不经过节点
+	if err = SaveRoutes([]Route{{Prefix: blocked.URL + "/", Policy: PolicyDirect}}); err != nil {
+		t.Fatal(err)
+	}
+	var status *StatusError
+	if _, err = client.Get(context.Background(), blocked.URL+"/geoip.srs"); !errors.As(err, &status) || status.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403, got %v", err)
+	}
+}
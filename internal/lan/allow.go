@@ -0,0 +1,82 @@
+package lan
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"playfast/utils"
+)
+
+// Allowed 允许使用网关的设备，IP 为最后一次发现时的地址
+type Allowed struct {
+	MAC string `json:"mac"`
+	IP  string `json:"ip"`
+}
+
+func allowedFile() string {
+	return filepath.Join(path.Path(), "devices.json")
+}
+
+// LoadAllowed 读取允许使用网关的设备，为空表示不限制
+func LoadAllowed() []Allowed {
+	allowed := make([]Allowed, 0)
+	data, err := os.ReadFile(allowedFile())
+	if err != nil {
+		return allowed
+	}
+	_ = json.Unmarshal(data, &allowed)
+	return allowed
+}
+
+// SaveAllowed 保存 devices 中被允许的设备
+func SaveAllowed(devices []Device) error {
+	allowed := make([]Allowed, 0)
+	for _, d := range devices {
+		if d.Allowed {
+			allowed = append(allowed, Allowed{MAC: d.MAC, IP: d.IP})
+		}
+	}
+	data, err := json.MarshalIndent(allowed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(allowedFile(), data, 0644)
+}
+
+// AllowedIPs 返回允许设备的当前 IP
+func AllowedIPs() []string {
+	allowed := LoadAllowed()
+	if len(allowed) == 0 {
+		return nil
+	}
+	current := neighbors()
+	ips := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		if ip := currentIP(current, a.MAC, a.IP); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// getNeighbors 读取 ARP 表，测试时替换
+var getNeighbors = utils.GetNeighbors
+
+// neighbors 返回 ARP 表中 MAC 到 IP 的映射
+func neighbors() map[string]string {
+	current := make(map[string]string)
+	list, _ := getNeighbors()
+	for _, n := range list {
+		current[n.MAC] = n.IP
+	}
+	return current
+}
+
+// currentIP 优先使用 ARP 表中的地址，找不到时使用保存的地址
+func currentIP(current map[string]string, mac, fallback string) string {
+	if ip, ok := current[mac]; ok {
+		return ip
+	}
+	return fallback
+}
@@ -0,0 +1,37 @@
+package lan
+
+import (
+	"playfast/utils"
+	"reflect"
+	"testing"
+)
+
+func TestAllowedIPs(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	arp := []utils.Neighbor{{IP: "192.168.1.30", MAC: "00:d9:d1:00:00:01"}}
+	getNeighbors = func() ([]utils.Neighbor, error) { return arp, nil }
+	t.Cleanup(func() { getNeighbors = utils.GetNeighbors })
+	cases := []struct {
+		name    string
+		devices []Device
+		want    []string
+	}{
+		{"none allowed", []Device{{IP: "192.168.1.20", MAC: "00:d9:d1:00:00:01"}}, nil},
+		{"address from arp", []Device{{IP: "192.168.1.20", MAC: "00:d9:d1:00:00:01", Allowed: true}}, []string{"192.168.1.30"}},
+		{"saved address", []Device{{IP: "192.168.1.21", MAC: "7c:ed:8d:00:00:01", Allowed: true}}, []string{"192.168.1.21"}},
+		{"mixed", []Device{
+			{IP: "192.168.1.20", MAC: "00:d9:d1:00:00:01", Allowed: true},
+			{IP: "192.168.1.21", MAC: "7c:ed:8d:00:00:01"},
+			{IP: "192.168.1.22", MAC: "98:b6:e9:00:00:01", Allowed: true},
+		}, []string{"192.168.1.30", "192.168.1.22"}},
+	}
+	for _, c := range cases {
+		if err := SaveAllowed(c.devices); err != nil {
+			t.Fatal(err)
+		}
+		if got := AllowedIPs(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
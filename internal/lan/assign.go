@@ -0,0 +1,66 @@
+package lan
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+)
+
+// Assignment 指定设备使用的节点，MAC 和 IP 至少填写一个
+type Assignment struct {
+	MAC  string `json:"mac"`
+	IP   string `json:"ip"`
+	Node string `json:"node"` // 节点 ID
+}
+
+func assignmentFile() string {
+	return filepath.Join(path.Path(), "assignments.json")
+}
+
+// LoadAssignments 读取设备节点分配
+func LoadAssignments() []Assignment {
+	assignments := make([]Assignment, 0)
+	data, err := os.ReadFile(assignmentFile())
+	if err != nil {
+		return assignments
+	}
+	_ = json.Unmarshal(data, &assignments)
+	return assignments
+}
+
+// SaveAssignments 保存设备节点分配，未指定节点的条目会被忽略
+func SaveAssignments(assignments []Assignment) error {
+	valid := make([]Assignment, 0, len(assignments))
+	for _, a := range assignments {
+		if a.Node == "" || (a.MAC == "" && net.ParseIP(a.IP) == nil) {
+			continue
+		}
+		if hw, err := net.ParseMAC(a.MAC); err == nil {
+			a.MAC = hw.String()
+		}
+		valid = append(valid, a)
+	}
+	data, err := json.MarshalIndent(valid, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(assignmentFile(), data, 0644)
+}
+
+// AssignedNodes 返回设备当前 IP 到节点名称的映射
+func AssignedNodes() map[string]string {
+	nodes := make(map[string]string)
+	assignments := LoadAssignments()
+	if len(assignments) == 0 {
+		return nodes
+	}
+	current := neighbors()
+	for _, a := range assignments {
+		if ip := currentIP(current, a.MAC, a.IP); ip != "" {
+			nodes[ip] = a.Node
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,40 @@
+package lan
+
+import (
+	"playfast/utils"
+	"reflect"
+	"testing"
+)
+
+func TestAssignments(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	getNeighbors = func() ([]utils.Neighbor, error) {
+		return []utils.Neighbor{{IP: "192.168.1.30", MAC: "00:d9:d1:00:00:01"}}, nil
+	}
+	t.Cleanup(func() { getNeighbors = utils.GetNeighbors })
+	err := SaveAssignments([]Assignment{
+		{MAC: "00-D9-D1-00-00-01", IP: "192.168.1.20", Node: "hk"},
+		{MAC: "7c:ed:8d:00:00:01", IP: "192.168.1.21", Node: "jp"},
+		{IP: "192.168.1.22", Node: "us"},
+		{MAC: "98:b6:e9:00:00:01", Node: ""},
+		{IP: "not-an-ip", Node: "sg"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MAC 统一为小写冒号格式，没有节点或没有有效地址的条目被忽略
+	want := []Assignment{
+		{MAC: "00:d9:d1:00:00:01", IP: "192.168.1.20", Node: "hk"},
+		{MAC: "7c:ed:8d:00:00:01", IP: "192.168.1.21", Node: "jp"},
+		{IP: "192.168.1.22", Node: "us"},
+	}
+	if got := LoadAssignments(); !reflect.DeepEqual(got, want) {
+		t.Errorf("assignments: got %+v, want %+v", got, want)
+	}
+	// ARP 表中的地址优先，找不到时使用保存的地址
+	nodes := map[string]string{"192.168.1.30": "hk", "192.168.1.21": "jp", "192.168.1.22": "us"}
+	if got := AssignedNodes(); !reflect.DeepEqual(got, nodes) {
+		t.Errorf("assigned nodes: got %v, want %v", got, nodes)
+	}
+}
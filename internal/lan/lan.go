@@ -0,0 +1,182 @@
+package lan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Device 表示局域网内发现的设备
+type Device struct {
+	IP      string `json:"ip"`
+	MAC     string `json:"mac"`
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Allowed bool   `json:"allowed"`
+}
+
+var (
+	ssdpAddr = &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	mdnsAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+)
+
+// Discover 通过 SSDP/mDNS 探测局域网设备，再结合 ARP 表得到 MAC 和厂商
+// 探测期间设备的响应会刷新 ARP 表，因此探测结束后再读取
+func Discover(ctx context.Context) []Device {
+	names := make(map[string]string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, probe := range []func(context.Context) map[string]string{ssdp, mdns} {
+		wg.Add(1)
+		go func(probe func(context.Context) map[string]string) {
+			defer wg.Done()
+			result := probe(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			for ip, name := range result {
+				if names[ip] == "" {
+					names[ip] = name
+				}
+			}
+		}(probe)
+	}
+	wg.Wait()
+	allowed := make(map[string]struct{})
+	for _, a := range LoadAllowed() {
+		allowed[a.MAC] = struct{}{}
+	}
+	neighbors, _ := getNeighbors()
+	devices := make([]Device, 0, len(neighbors))
+	for _, n := range neighbors {
+		_, ok := allowed[n.MAC]
+		devices = append(devices, Device{
+			IP:      n.IP,
+			MAC:     n.MAC,
+			Vendor:  Vendor(n.MAC),
+			Name:    names[n.IP],
+			Allowed: ok,
+		})
+	}
+	// 主机排在前面
+	sort.SliceStable(devices, func(i, j int) bool {
+		if (devices[i].Vendor != "") != (devices[j].Vendor != "") {
+			return devices[i].Vendor != ""
+		}
+		return devices[i].IP < devices[j].IP
+	})
+	return devices
+}
+
+// ssdp 发送 M-SEARCH 并以 SERVER 头作为设备名称
+func ssdp(ctx context.Context) map[string]string {
+	result := make(map[string]string)
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return result
+	}
+	defer func() { _ = conn.Close() }()
+	request := "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: ssdp:all\r\n\r\n"
+	_, err = conn.WriteTo([]byte(request), ssdpAddr)
+	if err != nil {
+		return result
+	}
+	read(ctx, conn, func(ip string, data []byte) {
+		server, ok := ssdpName(data)
+		if !ok {
+			return
+		}
+		if server != "" {
+			result[ip] = server
+		} else if _, ok := result[ip]; !ok {
+			result[ip] = ""
+		}
+	})
+	return result
+}
+
+// ssdpName 解析 M-SEARCH 响应的 SERVER 头，不是有效的 HTTP 响应时返回 false
+func ssdpName(data []byte) (string, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return "", false
+	}
+	_ = resp.Body.Close()
+	return resp.Header.Get("Server"), true
+}
+
+// mdns 查询 DNS-SD 服务列表，以应答中的主机名作为设备名称
+func mdns(ctx context.Context) map[string]string {
+	result := make(map[string]string)
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return result
+	}
+	defer func() { _ = conn.Close() }()
+	msg := new(dns.Msg)
+	msg.SetQuestion("_services._dns-sd._udp.local.", dns.TypePTR)
+	msg.Id = 0
+	msg.RecursionDesired = false
+	data, err := msg.Pack()
+	if err != nil {
+		return result
+	}
+	_, err = conn.WriteTo(data, mdnsAddr)
+	if err != nil {
+		return result
+	}
+	read(ctx, conn, func(ip string, data []byte) {
+		name, ok := mdnsName(data)
+		if !ok {
+			return
+		}
+		if name != "" || result[ip] == "" {
+			result[ip] = name
+		}
+	})
+	return result
+}
+
+// mdnsName 取应答中第一条 A 记录的主机名，不是有效的 DNS 消息时返回 false
+func mdnsName(data []byte) (string, bool) {
+	resp := new(dns.Msg)
+	if resp.Unpack(data) != nil {
+		return "", false
+	}
+	for _, rr := range append(resp.Answer, resp.Extra...) {
+		if a, ok := rr.(*dns.A); ok {
+			return strings.TrimSuffix(strings.TrimSuffix(a.Hdr.Name, "."), ".local"), true
+		}
+	}
+	return "", true
+}
+
+// read 读取响应直到 ctx 结束，最长等待 2 秒
+func read(ctx context.Context, conn net.PacketConn, handle func(ip string, data []byte)) {
+	deadline := time.Now().Add(2 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetReadDeadline(time.Now())
+	})
+	defer stop()
+	buffer := make([]byte, 4096)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			handle(udp.IP.String(), buffer[:n])
+		}
+	}
+}
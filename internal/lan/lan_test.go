@@ -0,0 +1,64 @@
+package lan
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSSDPName(t *testing.T) {
+	cases := []struct {
+		name   string
+		data   string
+		server string
+		ok     bool
+	}{
+		{"server", "HTTP/1.1 200 OK\r\nCACHE-CONTROL: max-age=1800\r\nSERVER: PS5/1.0 UPnP/1.0\r\nST: upnp:rootdevice\r\n\r\n", "PS5/1.0 UPnP/1.0", true},
+		{"no server", "HTTP/1.1 200 OK\r\nST: upnp:rootdevice\r\n\r\n", "", true},
+		{"not http", "NOTIFY * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n\r\n", "", false},
+		{"garbage", "\x00\x01\x02", "", false},
+	}
+	for _, c := range cases {
+		server, ok := ssdpName([]byte(c.data))
+		if server != c.server || ok != c.ok {
+			t.Errorf("%s: got %q %v, want %q %v", c.name, server, ok, c.server, c.ok)
+		}
+	}
+}
+
+func TestMDNSName(t *testing.T) {
+	pack := func(answer, extra []dns.RR) []byte {
+		msg := new(dns.Msg)
+		msg.Response = true
+		msg.Answer = answer
+		msg.Extra = extra
+		data, err := msg.Pack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	a := func(name string) dns.RR {
+		return &dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET}, A: net.IPv4(192, 168, 1, 20)}
+	}
+	ptr := &dns.PTR{Hdr: dns.RR_Header{Name: "_services._dns-sd._udp.local.", Rrtype: dns.TypePTR, Class: dns.ClassINET}, Ptr: "_airplay._tcp.local."}
+	cases := []struct {
+		name string
+		data []byte
+		host string
+		ok   bool
+	}{
+		{"answer", pack([]dns.RR{a("Nintendo-Switch.local.")}, nil), "Nintendo-Switch", true},
+		{"extra", pack([]dns.RR{ptr}, []dns.RR{a("PS5-123.local.")}), "PS5-123", true},
+		{"first a record", pack([]dns.RR{a("first.local."), a("second.local.")}, nil), "first", true},
+		{"no a record", pack([]dns.RR{ptr}, nil), "", true},
+		{"garbage", []byte{0x00, 0x01}, "", false},
+	}
+	for _, c := range cases {
+		host, ok := mdnsName(c.data)
+		if host != c.host || ok != c.ok {
+			t.Errorf("%s: got %q %v, want %q %v", c.name, host, ok, c.host, c.ok)
+		}
+	}
+}
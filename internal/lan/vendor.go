@@ -0,0 +1,53 @@
+package lan
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	VendorSony      = "Sony"
+	VendorMicrosoft = "Microsoft"
+	VendorNintendo  = "Nintendo"
+)
+
+// ouis 主机厂商的 MAC 前缀
+var ouis = map[string]string{
+	// Sony Interactive Entertainment
+	"00:04:1f": VendorSony, "00:13:15": VendorSony, "00:15:c1": VendorSony, "00:19:c5": VendorSony,
+	"00:1d:0d": VendorSony, "00:24:8d": VendorSony, "00:d9:d1": VendorSony, "00:e4:21": VendorSony,
+	"0c:fe:45": VendorSony, "28:0d:fc": VendorSony, "2c:cc:44": VendorSony, "5c:84:3c": VendorSony,
+	"70:9e:29": VendorSony, "78:c8:81": VendorSony, "a8:e3:ee": VendorSony, "bc:60:a7": VendorSony,
+	"c8:63:f1": VendorSony, "f8:46:1c": VendorSony, "f8:d0:ac": VendorSony, "fc:0f:e6": VendorSony,
+	// Microsoft (Xbox)
+	"00:0d:3a": VendorMicrosoft, "00:12:5a": VendorMicrosoft, "00:17:fa": VendorMicrosoft, "00:1d:d8": VendorMicrosoft,
+	"00:22:48": VendorMicrosoft, "00:25:ae": VendorMicrosoft, "00:50:f2": VendorMicrosoft, "28:18:78": VendorMicrosoft,
+	"30:59:b7": VendorMicrosoft, "50:1a:c5": VendorMicrosoft, "58:82:a8": VendorMicrosoft, "60:45:bd": VendorMicrosoft,
+	"7c:1e:52": VendorMicrosoft, "7c:ed:8d": VendorMicrosoft, "94:9a:a9": VendorMicrosoft, "98:5f:d3": VendorMicrosoft,
+	"bc:83:85": VendorMicrosoft, "c8:3f:26": VendorMicrosoft, "dc:b4:c4": VendorMicrosoft, "e4:f4:c6": VendorMicrosoft,
+	// Nintendo
+	"00:09:bf": VendorNintendo, "00:16:56": VendorNintendo, "00:17:ab": VendorNintendo, "00:19:1d": VendorNintendo,
+	"00:19:fd": VendorNintendo, "00:1a:e9": VendorNintendo, "00:1b:7a": VendorNintendo, "00:1b:ea": VendorNintendo,
+	"00:1c:be": VendorNintendo, "00:1d:bc": VendorNintendo, "00:1e:35": VendorNintendo, "00:1e:a9": VendorNintendo,
+	"00:1f:32": VendorNintendo, "00:1f:c5": VendorNintendo, "00:21:47": VendorNintendo, "00:21:bd": VendorNintendo,
+	"00:22:4c": VendorNintendo, "00:22:aa": VendorNintendo, "00:22:d7": VendorNintendo, "00:23:31": VendorNintendo,
+	"00:23:cc": VendorNintendo, "00:24:1e": VendorNintendo, "00:24:44": VendorNintendo, "00:24:f3": VendorNintendo,
+	"00:25:a0": VendorNintendo, "00:26:59": VendorNintendo, "00:27:09": VendorNintendo, "04:03:d6": VendorNintendo,
+	"2c:10:c1": VendorNintendo, "34:af:2c": VendorNintendo, "40:d2:8a": VendorNintendo, "40:f4:07": VendorNintendo,
+	"58:2f:40": VendorNintendo, "58:bd:a3": VendorNintendo, "5c:52:1e": VendorNintendo, "60:6b:ff": VendorNintendo,
+	"64:b5:c6": VendorNintendo, "78:a2:a0": VendorNintendo, "7c:bb:8a": VendorNintendo, "8c:56:c5": VendorNintendo,
+	"8c:cd:e8": VendorNintendo, "98:41:5c": VendorNintendo, "98:b6:e9": VendorNintendo, "9c:e6:35": VendorNintendo,
+	"a4:38:cc": VendorNintendo, "a4:5c:27": VendorNintendo, "a4:c0:e1": VendorNintendo, "b8:78:26": VendorNintendo,
+	"b8:8a:ec": VendorNintendo, "b8:ae:6e": VendorNintendo, "cc:9e:00": VendorNintendo, "cc:fb:65": VendorNintendo,
+	"d8:6b:f7": VendorNintendo, "dc:68:eb": VendorNintendo, "e0:0c:7f": VendorNintendo, "e0:e7:51": VendorNintendo,
+	"e8:4e:ce": VendorNintendo, "ec:c4:0d": VendorNintendo,
+}
+
+// Vendor 根据 MAC 前缀识别主机厂商，未知时返回空
+func Vendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) < 3 {
+		return ""
+	}
+	return ouis[strings.ToLower(hw[:3].String())]
+}
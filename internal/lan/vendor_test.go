@@ -0,0 +1,24 @@
+package lan
+
+import "testing"
+
+func TestVendor(t *testing.T) {
+	cases := []struct {
+		mac  string
+		want string
+	}{
+		{"00:d9:d1:12:34:56", VendorSony},
+		{"00:D9:D1:12:34:56", VendorSony},
+		{"00-d9-d1-12-34-56", VendorSony},
+		{"7c:ed:8d:00:00:01", VendorMicrosoft},
+		{"98:b6:e9:00:00:01", VendorNintendo},
+		{"00:11:22:33:44:55", ""},
+		{"not-a-mac", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := Vendor(c.mac); got != c.want {
+			t.Errorf("Vendor(%q) = %q, want %q", c.mac, got, c.want)
+		}
+	}
+}
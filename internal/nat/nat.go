@@ -0,0 +1,199 @@
+package nat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultServer 支持 RFC 5780 的公共 STUN 服务器
+const DefaultServer = "stun.stunprotocol.org:3478"
+
+// 映射和过滤行为，参见 RFC 4787
+const (
+	EndpointIndependent  = "endpoint-independent"
+	AddressDependent     = "address-dependent"
+	AddressPortDependent = "address-and-port-dependent"
+	Unknown              = "unknown"
+)
+
+// 主机平台常用的 NAT 类型
+const (
+	TypeOpen     = "Open"
+	TypeModerate = "Moderate"
+	TypeStrict   = "Strict"
+	TypeUnknown  = "Unknown"
+)
+
+var errTimeout = errors.New("stun 请求超时")
+
+// Result NAT 检测结果
+type Result struct {
+	Type      string `json:"type"`
+	Mapping   string `json:"mapping"`
+	Filtering string `json:"filtering"`
+	Public    string `json:"public"`
+	Error     string `json:"error"`
+}
+
+// Detector 在给定的 UDP 连接上按 RFC 5780 检测 NAT 行为
+// 连接可以来自节点出站的 ListenPacket，此时检测的是经过节点后的 NAT
+type Detector struct {
+	conn    net.PacketConn
+	timeout time.Duration
+	retries int
+}
+
+// DetectorOption 是Detector的选项函数类型
+type DetectorOption func(*Detector)
+
+// NewDetector 创建 NAT 检测器
+func NewDetector(conn net.PacketConn, options ...DetectorOption) *Detector {
+	d := &Detector{
+		conn:    conn,
+		timeout: time.Second,
+		retries: 3,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// WithTimeout 设置单次请求的超时时间
+func WithTimeout(timeout time.Duration) DetectorOption {
+	return func(d *Detector) {
+		d.timeout = timeout
+	}
+}
+
+// WithRetries 设置单次请求的重传次数
+func WithRetries(retries int) DetectorOption {
+	return func(d *Detector) {
+		d.retries = retries
+	}
+}
+
+// Detect 依次进行映射和过滤测试，server 为 STUN 服务器地址
+func (d *Detector) Detect(ctx context.Context, server string) (*Result, error) {
+	primary, err := resolve(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Type: TypeUnknown, Mapping: Unknown, Filtering: Unknown}
+	r1, err := d.roundTrip(ctx, primary, 0)
+	if err != nil {
+		return nil, fmt.Errorf("无法连接 STUN 服务器: %w", err)
+	}
+	if r1.Mapped == nil {
+		return nil, errInvalidMessage
+	}
+	result.Public = r1.Mapped.String()
+	if r1.Other == nil {
+		// 服务器不支持 RFC 5780，无法继续检测
+		return result, nil
+	}
+	result.Mapping = d.mapping(ctx, primary, r1)
+	result.Filtering = d.filtering(ctx, primary)
+	switch {
+	case result.Mapping == EndpointIndependent && result.Filtering == EndpointIndependent:
+		result.Type = TypeOpen
+	case result.Mapping == EndpointIndependent:
+		result.Type = TypeModerate
+	case result.Mapping != Unknown:
+		result.Type = TypeStrict
+	}
+	return result, nil
+}
+
+// mapping 向服务器的备用地址发送请求，比较映射地址是否变化
+func (d *Detector) mapping(ctx context.Context, primary *net.UDPAddr, r1 *message) string {
+	r2, err := d.roundTrip(ctx, &net.UDPAddr{IP: r1.Other.IP, Port: primary.Port}, 0)
+	if err != nil || r2.Mapped == nil {
+		return Unknown
+	}
+	if sameAddr(r1.Mapped, r2.Mapped) {
+		return EndpointIndependent
+	}
+	r3, err := d.roundTrip(ctx, r1.Other, 0)
+	if err != nil || r3.Mapped == nil {
+		return Unknown
+	}
+	if sameAddr(r2.Mapped, r3.Mapped) {
+		return AddressDependent
+	}
+	return AddressPortDependent
+}
+
+// filtering 请求服务器从其他地址或端口回复，能收到即说明未被过滤
+func (d *Detector) filtering(ctx context.Context, primary *net.UDPAddr) string {
+	_, err := d.roundTrip(ctx, primary, changeIP|changePort)
+	if err == nil {
+		return EndpointIndependent
+	}
+	if !errors.Is(err, errTimeout) {
+		return Unknown
+	}
+	_, err = d.roundTrip(ctx, primary, changePort)
+	if err == nil {
+		return AddressDependent
+	}
+	if !errors.Is(err, errTimeout) {
+		return Unknown
+	}
+	return AddressPortDependent
+}
+
+// roundTrip 发送绑定请求并等待同一事务的响应，响应可能来自其他地址
+func (d *Detector) roundTrip(ctx context.Context, addr *net.UDPAddr, change uint32) (*message, error) {
+	req := newRequest(change)
+	data := req.marshal()
+	buffer := make([]byte, 1500)
+	for i := 0; i < d.retries; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, err := d.conn.WriteTo(data, addr); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(d.timeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		_ = d.conn.SetReadDeadline(deadline)
+		for {
+			n, _, err := d.conn.ReadFrom(buffer)
+			if err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			resp, err := unmarshal(buffer[:n])
+			if err != nil || resp.Type != typeBindingResponse || resp.TransactionID != req.TransactionID {
+				continue
+			}
+			return resp, nil
+		}
+	}
+	return nil, errTimeout
+}
+
+func resolve(ctx context.Context, server string) (*net.UDPAddr, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+	if err != nil {
+		return nil, err
+	}
+	return net.ResolveUDPAddr("udp4", net.JoinHostPort(ips[0].String(), port))
+}
+
+func sameAddr(a, b *net.UDPAddr) bool {
+	return a.IP.Equal(b.IP) && a.Port == b.Port
+}
@@ -0,0 +1,144 @@
+package nat
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer 模拟支持 RFC 5780 的 STUN 服务器，监听两个 IP 各两个端口
+type testServer struct {
+	conns [2][2]net.PacketConn // [IP][端口]
+	// mapped 模拟 NAT 映射，参数为收到请求的服务器地址和客户端地址
+	mapped func(local, client *net.UDPAddr) *net.UDPAddr
+	// blocked 模拟 NAT 过滤，参数为响应的来源地址和客户端请求的目标地址，返回 true 表示响应被丢弃
+	blocked func(from, local *net.UDPAddr) bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{
+		mapped:  func(_, client *net.UDPAddr) *net.UDPAddr { return client },
+		blocked: func(_, _ *net.UDPAddr) bool { return false },
+	}
+	ips := []string{"127.0.0.1", "127.0.0.2"}
+	ports := []string{"0", "0"}
+	for i, ip := range ips {
+		for j := range ports {
+			conn, err := net.ListenPacket("udp4", net.JoinHostPort(ip, ports[j]))
+			if err != nil {
+				t.Skip("loopback alias unavailable:", err)
+			}
+			s.conns[i][j] = conn
+			ports[j] = strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port)
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}
+	return s
+}
+
+// start 开始响应请求，测试需要在此之前设置好 mapped 和 blocked
+func (s *testServer) start() {
+	for i := range s.conns {
+		for j := range s.conns[i] {
+			go s.serve(i, j)
+		}
+	}
+}
+
+func (s *testServer) addr(i, j int) *net.UDPAddr {
+	return s.conns[i][j].LocalAddr().(*net.UDPAddr)
+}
+
+func (s *testServer) serve(i, j int) {
+	conn := s.conns[i][j]
+	buffer := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		req, err := unmarshal(buffer[:n])
+		if err != nil || req.Type != typeBindingRequest {
+			continue
+		}
+		ri, rj := i, j
+		if req.Change&changeIP != 0 {
+			ri = 1 - i
+		}
+		if req.Change&changePort != 0 {
+			rj = 1 - j
+		}
+		from := s.addr(ri, rj)
+		if s.blocked(from, s.addr(i, j)) {
+			continue
+		}
+		resp := &message{
+			Type:          typeBindingResponse,
+			TransactionID: req.TransactionID,
+			Mapped:        s.mapped(s.addr(i, j), peer.(*net.UDPAddr)),
+			Origin:        from,
+			Other:         s.addr(1-i, 1-j),
+		}
+		_, _ = s.conns[ri][rj].WriteTo(resp.marshal(), peer)
+	}
+}
+
+func detect(t *testing.T, s *testServer) *Result {
+	s.start()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := NewDetector(conn, WithTimeout(100*time.Millisecond), WithRetries(2)).Detect(ctx, s.addr(0, 0).String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestDetectOpen(t *testing.T) {
+	result := detect(t, newTestServer(t))
+	if result.Type != TypeOpen || result.Mapping != EndpointIndependent || result.Filtering != EndpointIndependent {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestDetectModerate(t *testing.T) {
+	s := newTestServer(t)
+	// 端口限制型锥形 NAT：只接受来自请求目标地址的响应
+	s.blocked = func(from, local *net.UDPAddr) bool {
+		return !sameAddr(from, local)
+	}
+	result := detect(t, s)
+	if result.Type != TypeModerate || result.Filtering != AddressPortDependent {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestDetectStrict(t *testing.T) {
+	s := newTestServer(t)
+	// 对称型 NAT：每个目标地址使用不同的映射端口
+	var mu sync.Mutex
+	ports := make(map[string]int)
+	s.mapped = func(local, _ *net.UDPAddr) *net.UDPAddr {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := ports[local.String()]; !ok {
+			ports[local.String()] = 40000 + len(ports)
+		}
+		return &net.UDPAddr{IP: net.IPv4(203, 0, 113, 1), Port: ports[local.String()]}
+	}
+	result := detect(t, s)
+	if result.Type != TypeStrict || result.Mapping != AddressPortDependent {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.Public != "203.0.113.1:40000" {
+		t.Errorf("unexpected public address %s", result.Public)
+	}
+}
@@ -0,0 +1,153 @@
+package nat
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// STUN 报文常量，参见 RFC 5389 / RFC 5780
+const (
+	magicCookie = 0x2112A442
+
+	typeBindingRequest  = 0x0001
+	typeBindingResponse = 0x0101
+
+	attrMappedAddress    = 0x0001
+	attrChangeRequest    = 0x0003
+	attrXorMappedAddress = 0x0020
+	attrResponseOrigin   = 0x802b
+	attrOtherAddress     = 0x802c
+
+	changeIP   = 0x04
+	changePort = 0x02
+
+	headerSize = 20
+)
+
+var errInvalidMessage = errors.New("invalid stun message")
+
+// message 只包含 NAT 检测需要的字段
+type message struct {
+	Type          uint16
+	TransactionID [12]byte
+	Mapped        *net.UDPAddr
+	Origin        *net.UDPAddr
+	Other         *net.UDPAddr
+	Change        uint32
+}
+
+func newRequest(change uint32) *message {
+	m := &message{Type: typeBindingRequest, Change: change}
+	_, _ = rand.Read(m.TransactionID[:])
+	return m
+}
+
+func (m *message) marshal() []byte {
+	attrs := make([]byte, 0, 32)
+	if m.Change != 0 {
+		attrs = appendAttr(attrs, attrChangeRequest, binary.BigEndian.AppendUint32(nil, m.Change))
+	}
+	if m.Mapped != nil {
+		attrs = appendAttr(attrs, attrXorMappedAddress, encodeAddr(m.Mapped, true))
+	}
+	if m.Origin != nil {
+		attrs = appendAttr(attrs, attrResponseOrigin, encodeAddr(m.Origin, false))
+	}
+	if m.Other != nil {
+		attrs = appendAttr(attrs, attrOtherAddress, encodeAddr(m.Other, false))
+	}
+	b := make([]byte, headerSize, headerSize+len(attrs))
+	binary.BigEndian.PutUint16(b[0:], m.Type)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(attrs)))
+	binary.BigEndian.PutUint32(b[4:], magicCookie)
+	copy(b[8:], m.TransactionID[:])
+	return append(b, attrs...)
+}
+
+func unmarshal(b []byte) (*message, error) {
+	if len(b) < headerSize || binary.BigEndian.Uint32(b[4:]) != magicCookie {
+		return nil, errInvalidMessage
+	}
+	length := int(binary.BigEndian.Uint16(b[2:]))
+	if len(b) < headerSize+length {
+		return nil, errInvalidMessage
+	}
+	m := &message{Type: binary.BigEndian.Uint16(b[0:])}
+	copy(m.TransactionID[:], b[8:20])
+	attrs := b[headerSize : headerSize+length]
+	for len(attrs) >= 4 {
+		t := binary.BigEndian.Uint16(attrs[0:])
+		l := int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+l {
+			return nil, errInvalidMessage
+		}
+		value := attrs[4 : 4+l]
+		switch t {
+		case attrMappedAddress:
+			if m.Mapped == nil {
+				m.Mapped = decodeAddr(value, false)
+			}
+		case attrXorMappedAddress:
+			m.Mapped = decodeAddr(value, true)
+		case attrResponseOrigin:
+			m.Origin = decodeAddr(value, false)
+		case attrOtherAddress:
+			m.Other = decodeAddr(value, false)
+		case attrChangeRequest:
+			if l == 4 {
+				m.Change = binary.BigEndian.Uint32(value)
+			}
+		}
+		// 属性按 4 字节对齐
+		attrs = attrs[4+(l+3)&^3:]
+	}
+	return m, nil
+}
+
+func appendAttr(b []byte, t uint16, value []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, t)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(value)))
+	b = append(b, value...)
+	for i := len(value); i%4 != 0; i++ {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// encodeAddr 只支持 IPv4，IPv4 的 XOR 只用到 magic cookie
+func encodeAddr(addr *net.UDPAddr, xor bool) []byte {
+	b := make([]byte, 8)
+	b[1] = 0x01
+	port := uint16(addr.Port)
+	ip := append(net.IP{}, addr.IP.To4()...)
+	if xor {
+		ip, port = xorAddr(ip, port)
+	}
+	binary.BigEndian.PutUint16(b[2:], port)
+	copy(b[4:], ip)
+	return b
+}
+
+func decodeAddr(b []byte, xor bool) *net.UDPAddr {
+	// 只处理 IPv4
+	if len(b) < 8 || b[1] != 0x01 {
+		return nil
+	}
+	port := binary.BigEndian.Uint16(b[2:])
+	ip := net.IPv4(b[4], b[5], b[6], b[7]).To4()
+	if xor {
+		ip, port = xorAddr(ip, port)
+	}
+	return &net.UDPAddr{IP: ip, Port: int(port)}
+}
+
+func xorAddr(ip net.IP, port uint16) (net.IP, uint16) {
+	var cookie [4]byte
+	binary.BigEndian.PutUint32(cookie[:], magicCookie)
+	for i := range ip {
+		ip[i] ^= cookie[i]
+	}
+	return ip, port ^ uint16(magicCookie>>16)
+}
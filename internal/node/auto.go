@@ -0,0 +1,61 @@
+package node
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// Auto 智能选择的虚拟节点名称，"智能选择/香港" 表示只在该地区的节点中选择
+const Auto = "智能选择"
+
+// ParseAuto 判断名称是否为智能选择，返回限定的地区，不限定时为空
+func ParseAuto(name string) (region string, ok bool) {
+	if name == Auto {
+		return "", true
+	}
+	if region, ok = strings.CutPrefix(name, Auto+"/"); ok && region != "" {
+		return region, true
+	}
+	return "", false
+}
+
+// AutoNames 返回智能选择的虚拟节点列表，包括不限地区和每个地区各一项
+func AutoNames(proxies []Proxy) []string {
+	names := []string{Auto}
+	regions := make(map[string]struct{})
+	for _, p := range proxies {
+		if p.Region != "" {
+			regions[p.Region] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(regions))
+	for region := range regions {
+		sorted = append(sorted, region)
+	}
+	sort.Strings(sorted)
+	for _, region := range sorted {
+		names = append(names, Auto+"/"+region)
+	}
+	return names
+}
+
+// Best 从排序结果中选出得分最优的可用节点，region 非空时只考虑该地区
+func Best(ranking Ranking, region string) (Quality, error) {
+	var best *Quality
+	for i, q := range ranking.Nodes {
+		if q.Error != "" || (region != "" && q.Region != region) {
+			continue
+		}
+		if best == nil || q.Score < best.Score {
+			best = &ranking.Nodes[i]
+		}
+	}
+	if best == nil {
+		if region != "" {
+			return Quality{}, errors.New("地区 " + region + " 没有可用节点")
+		}
+		return Quality{}, errors.New("没有可用节点")
+	}
+	return *best, nil
+}
@@ -0,0 +1,37 @@
+package node
+
+import "testing"
+
+func TestParseAuto(t *testing.T) {
+	for name, want := range map[string]string{Auto: "", Auto + "/香港": "香港"} {
+		if region, ok := ParseAuto(name); !ok || region != want {
+			t.Errorf("ParseAuto(%q) = %q, %v", name, region, ok)
+		}
+	}
+	for _, name := range []string{"香港(多线)", Auto + "/"} {
+		if _, ok := ParseAuto(name); ok {
+			t.Errorf("ParseAuto(%q) should not be auto", name)
+		}
+	}
+	names := AutoNames([]Proxy{{Region: "日本"}, {Region: "香港"}, {Region: "日本"}, {}})
+	if len(names) != 3 || names[0] != Auto || names[1] != Auto+"/日本" || names[2] != Auto+"/香港" {
+		t.Errorf("unexpected auto names %v", names)
+	}
+}
+
+func TestBest(t *testing.T) {
+	ranking := Ranking{Nodes: []Quality{
+		{Name: "a", Region: "香港", Score: 30},
+		{Name: "b", Region: "日本", Score: 20},
+		{Name: "c", Region: "日本", Error: "timeout"},
+	}}
+	if best, err := Best(ranking, ""); err != nil || best.Name != "b" {
+		t.Errorf("Best() = %+v, %v", best, err)
+	}
+	if best, err := Best(ranking, "香港"); err != nil || best.Name != "a" {
+		t.Errorf("Best(香港) = %+v, %v", best, err)
+	}
+	if _, err := Best(ranking, "美国"); err == nil {
+		t.Error("expected error for region without nodes")
+	}
+}
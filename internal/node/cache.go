@@ -0,0 +1,206 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"playfast/internal/api"
+	"playfast/internal/http-client"
+	"playfast/internal/path"
+	"sync"
+	"time"
+)
+
+// remoteTTL 服务端节点列表的缓存时间，过期后携带 ETag 重新验证
+const remoteTTL = 5 * time.Minute
+
+// CacheInfo 服务端节点列表的缓存状态
+type CacheInfo struct {
+	UpdatedAt time.Time `json:"updatedAt"` // 最近一次从服务端确认的时间，从未成功时为零值
+	Stale     bool      `json:"stale"`     // 最近一次刷新失败，正在使用上次成功的结果
+	Error     string    `json:"error"`
+}
+
+// cacheEntry 保存在内存和磁盘上的 proxy.json
+type cacheEntry struct {
+	http_client.Validators
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// remoteCache 带 TTL 和 ETag 重新验证的 proxy.json 缓存，服务端不可用时返回上次成功的结果
+// 请求在后台进行，不持有锁；有缓存时立即返回缓存，失败后在 TTL 内不再重试
+type remoteCache struct {
+	mu       sync.Mutex
+	url      func() string
+	file     func() string
+	ttl      time.Duration
+	entry    *cacheEntry
+	loaded   bool
+	err      error
+	failedAt time.Time     // 最近一次请求失败的时间
+	inflight chan struct{} // 正在进行的请求，完成时关闭
+}
+
+var remote = &remoteCache{
+	url:  func() string { return fmt.Sprintf("%s/proxy.json", api.GetApiDomain()) },
+	file: func() string { return filepath.Join(path.Path(), "proxy_cache.json") },
+	ttl:  remoteTTL,
+}
+
+// RemoteCache 返回服务端节点列表的缓存状态
+func RemoteCache() CacheInfo {
+	return remote.info()
+}
+
+// RefreshRemote 忽略 TTL 立即向服务端重新验证节点列表
+func RefreshRemote(ctx context.Context) CacheInfo {
+	remote.get(ctx, true)
+	return remote.info()
+}
+
+func (c *remoteCache) info() CacheInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	info := CacheInfo{}
+	if c.entry != nil {
+		info.UpdatedAt = c.entry.UpdatedAt
+	}
+	if c.err != nil {
+		info.Stale = true
+		info.Error = c.err.Error()
+	}
+	return info
+}
+
+// load 首次使用时读取磁盘缓存
+func (c *remoteCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	data, err := os.ReadFile(c.file())
+	if err != nil {
+		return
+	}
+	entry := &cacheEntry{}
+	if json.Unmarshal(data, entry) == nil {
+		c.entry = entry
+	}
+}
+
+func (c *remoteCache) save() {
+	data, err := json.Marshal(c.entry)
+	if err == nil {
+		err = os.WriteFile(c.file(), data, 0644)
+	}
+	if err != nil {
+		log.Println("保存节点缓存失败:", err)
+	}
+}
+
+// get 返回服务端节点列表，缓存过期时在后台重新验证并立即返回缓存
+// 没有缓存或 force 为 true 时等待请求完成，force 同时忽略失败后的退避
+// ctx 结束时不再等待，请求仍在后台完成并更新缓存
+func (c *remoteCache) get(ctx context.Context, force bool) []Proxy {
+	c.mu.Lock()
+	c.load()
+	if force || c.due() {
+		done := c.refresh(ctx)
+		if force || c.entry == nil {
+			c.mu.Unlock()
+			select {
+			case <-done:
+			case <-ctx.Done():
+			}
+			c.mu.Lock()
+		}
+	}
+	data := make([]Proxy, 0)
+	if c.entry != nil {
+		_ = json.Unmarshal(c.entry.Data, &data)
+	}
+	c.mu.Unlock()
+	return data
+}
+
+// due 缓存是否需要重新验证，失败后的 TTL 内不再重试，调用时需持有锁
+func (c *remoteCache) due() bool {
+	if !c.failedAt.IsZero() && time.Since(c.failedAt) < c.ttl {
+		return false
+	}
+	return c.entry == nil || time.Since(c.entry.UpdatedAt) >= c.ttl
+}
+
+// refresh 在后台重新验证，已有请求在进行时复用该请求，调用时需持有锁
+// 请求由多个调用方共享，不随 ctx 取消
+func (c *remoteCache) refresh(ctx context.Context) <-chan struct{} {
+	if c.inflight != nil {
+		return c.inflight
+	}
+	done := make(chan struct{})
+	c.inflight = done
+	validators := http_client.Validators{}
+	if c.entry != nil {
+		validators = c.entry.Validators
+	}
+	go func() {
+		entry, err := c.revalidate(context.WithoutCancel(ctx), validators)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.err = err
+		if err != nil {
+			c.failedAt = time.Now()
+			log.Println("获取节点列表失败，使用缓存:", err)
+		} else {
+			c.failedAt = time.Time{}
+			if entry != nil {
+				c.entry = entry
+			} else if c.entry != nil {
+				// 内容未变化时只更新确认时间
+				c.entry.UpdatedAt = time.Now()
+			}
+			c.save()
+		}
+		c.inflight = nil
+		close(done)
+	}()
+	return done
+}
+
+// peek 直接读取磁盘上的缓存，不加锁也不发起请求
+// 没有缓存时 get 会等待请求完成，请求经过节点出站时通过 peek 读取节点，避免互相等待
+func (c *remoteCache) peek() []Proxy {
+	data := make([]Proxy, 0)
+	raw, err := os.ReadFile(c.file())
+	if err != nil {
+		return data
+	}
+	entry := &cacheEntry{}
+	if json.Unmarshal(raw, entry) == nil {
+		_ = json.Unmarshal(entry.Data, &data)
+	}
+	return data
+}
+
+// revalidate 携带 ETag 和 Last-Modified 请求 proxy.json，未变化时返回 nil
+func (c *remoteCache) revalidate(ctx context.Context, validators http_client.Validators) (*cacheEntry, error) {
+	res, err := http_client.GetConditional(ctx, c.url(), validators)
+	if errors.Is(err, http_client.ErrNotModified) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// 内容无法解析时不覆盖上次成功的结果
+	var proxies []Proxy
+	if err = json.Unmarshal(res.Data, &proxies); err != nil {
+		return nil, fmt.Errorf("节点列表格式错误: %w", err)
+	}
+	return &cacheEntry{Validators: res.Validators, UpdatedAt: time.Now(), Data: res.Data}, nil
+}
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteCache(t *testing.T) {
+	var requests, modified atomic.Int32
+	body := `[{"name":"香港","protocol":"trojan","host":"1.2.3.4","port":443,"password":"a"}]`
+	down := atomic.Bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		modified.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "proxy_cache.json")
+	newCache := func() *remoteCache {
+		return &remoteCache{url: func() string { return server.URL }, file: func() string { return file }, ttl: time.Hour}
+	}
+
+	c := newCache()
+	if proxies := c.get(context.Background(), false); len(proxies) != 1 || proxies[0].Name != "香港" {
+		t.Fatalf("unexpected nodes %+v", proxies)
+	}
+	// TTL 内不重复请求
+	c.get(context.Background(), false)
+	if requests.Load() != 1 {
+		t.Errorf("expected 1 request, got %d", requests.Load())
+	}
+	// 强制刷新时携带 ETag，服务端返回 304
+	c.get(context.Background(), true)
+	if requests.Load() != 2 || modified.Load() != 1 {
+		t.Errorf("expected revalidation, got %d requests %d bodies", requests.Load(), modified.Load())
+	}
+
+	// 缓存过期且服务端不可用时立即返回磁盘上的缓存，请求在后台进行
+	down.Store(true)
+	c = newCache()
+	c.load()
+	c.entry.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	if proxies := c.get(context.Background(), false); len(proxies) != 1 {
+		t.Fatalf("expected last known good nodes, got %+v", proxies)
+	}
+	waitCache(c)
+	if info := c.info(); !info.Stale || info.Error == "" || info.UpdatedAt.IsZero() {
+		t.Errorf("unexpected cache info %+v", info)
+	}
+	// 失败后在 TTL 内不再重试
+	failed := requests.Load()
+	c.get(context.Background(), false)
+	waitCache(c)
+	if requests.Load() != failed {
+		t.Errorf("expected backoff after failure, got %d requests", requests.Load()-failed)
+	}
+
+	// 服务端恢复后强制刷新忽略退避并清除错误
+	down.Store(false)
+	c.get(context.Background(), true)
+	if info := c.info(); info.Stale {
+		t.Errorf("cache should be fresh, got %+v", info)
+	}
+}
+
+func TestRemoteCacheStale(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	defer close(release)
+	file := filepath.Join(t.TempDir(), "proxy_cache.json")
+	c := &remoteCache{url: func() string { return server.URL }, file: func() string { return file }, ttl: time.Hour}
+	c.entry = &cacheEntry{UpdatedAt: time.Now().Add(-2 * time.Hour), Data: []byte(`[{"name":"香港"}]`)}
+	c.loaded = true
+
+	// 请求未完成时 get 和 info 都不会等待
+	done := make(chan []Proxy)
+	go func() {
+		proxies := c.get(context.Background(), false)
+		c.info()
+		done <- proxies
+	}()
+	select {
+	case proxies := <-done:
+		if len(proxies) != 1 {
+			t.Errorf("expected stale nodes, got %+v", proxies)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("get blocked on revalidation")
+	}
+}
+
+// waitCache 等待后台请求完成
+func waitCache(c *remoteCache) {
+	c.mu.Lock()
+	done := c.inflight
+	c.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+func TestRemoteCacheInvalidBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>"))
+	}))
+	defer server.Close()
+	c := &remoteCache{url: func() string { return server.URL }, file: func() string { return filepath.Join(t.TempDir(), "c.json") }, ttl: time.Hour}
+	if proxies := c.get(context.Background(), false); len(proxies) != 0 {
+		t.Errorf("unexpected nodes %+v", proxies)
+	}
+	if info := c.info(); !info.Stale {
+		t.Errorf("invalid body should be reported, got %+v", info)
+	}
+}
@@ -0,0 +1,322 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"strconv"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+)
+
+// DetourUpstream 节点的 detour 为该值时经过本地配置的上游代理
+const DetourUpstream = "upstream"
+
+// maxDetours 链路中最多的前置节点数
+const maxDetours = 3
+
+// Upstream 本地配置的上游代理，保存在 upstream.json
+type Upstream struct {
+	Type         string `json:"type"` // socks 或 http，为空表示不使用
+	Host         string `json:"host"`
+	Port         uint16 `json:"port"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	AllNodes     bool   `json:"allNodes"`     // 没有设置 detour 的节点也经过上游代理
+	ControlPlane bool   `json:"controlPlane"` // 加速器自身的 HTTP 请求也经过上游代理
+}
+
+var upstreamLock sync.Mutex
+
+func upstreamFile() string {
+	return filepath.Join(path.Path(), "upstream.json")
+}
+
+// LoadUpstream 读取上游代理配置
+func LoadUpstream() Upstream {
+	upstreamLock.Lock()
+	defer upstreamLock.Unlock()
+	upstream := Upstream{}
+	data, err := os.ReadFile(upstreamFile())
+	if err != nil {
+		return upstream
+	}
+	_ = json.Unmarshal(data, &upstream)
+	return upstream
+}
+
+// SaveUpstream 校验并保存上游代理配置
+func SaveUpstream(upstream Upstream) error {
+	if upstream.Enabled() {
+		if err := upstream.proxy().Validate(); err != nil {
+			return err
+		}
+	}
+	upstreamLock.Lock()
+	defer upstreamLock.Unlock()
+	data, err := json.MarshalIndent(upstream, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(upstreamFile(), data, 0644)
+}
+
+// Enabled 是否配置了上游代理
+func (u Upstream) Enabled() bool {
+	return u.Type != ""
+}
+
+// URL 返回供 net/http 使用的代理地址，未配置时返回 nil
+func (u Upstream) URL() *url.URL {
+	if !u.Enabled() {
+		return nil
+	}
+	scheme := "http"
+	if u.Type == ProtocolSOCKS {
+		scheme = "socks5"
+	}
+	proxyURL := &url.URL{Scheme: scheme, Host: net.JoinHostPort(u.Host, strconv.Itoa(int(u.Port)))}
+	if u.Username != "" || u.Password != "" {
+		proxyURL.User = url.UserPassword(u.Username, u.Password)
+	}
+	return proxyURL
+}
+
+// proxy 将上游代理转换为节点，作为链路的第一跳
+func (u Upstream) proxy() Proxy {
+	p := Proxy{
+		ID:       DetourUpstream,
+		Name:     "上游代理",
+		Protocol: u.Type,
+		Host:     u.Host,
+		Port:     u.Port,
+		Username: u.Username,
+		Password: u.Password,
+	}
+	if u.Type == ProtocolSOCKS {
+		// 上游代理通常不支持 UDP over TCP，节点自己的 UDP 会在 TCP 中转发
+		off := false
+		p.Tuning = &Tuning{UoT: &off}
+	}
+	return p
+}
+
+// Chain 节点的出站链路，tag 为节点本身，其余依次为它的前置出站
+type Chain struct {
+	Outbounds []option.Outbound
+	Endpoints []option.Endpoint // wireguard 节点以端点的形式加入
+	Host      string            // 链路第一跳的地址，需要直连
+}
+
+// chainOf 按 detour 展开节点链路，返回从节点本身到第一跳的各节点，并设置好前置出站的 tag
+// proxies 用于查找前置节点，upstream 为本地的上游代理配置
+func chainOf(p Proxy, tag string, proxies []Proxy, upstream Upstream) ([]Proxy, error) {
+	hops := make([]Proxy, 0, 2)
+	seen := map[string]bool{}
+	current := p
+	for {
+		if current.Maintenance {
+			return nil, fmt.Errorf("节点 %s: %w", current.Name, ErrMaintenance)
+		}
+		if err := current.Validate(); err != nil {
+			return nil, err
+		}
+		seen[current.ID] = true
+		detour := current.Detour
+		if detour == "" && upstream.AllNodes && upstream.Enabled() && current.ID != DetourUpstream {
+			detour = DetourUpstream
+		}
+		if detour == "" {
+			return append(hops, current), nil
+		}
+		if len(hops) >= maxDetours {
+			return nil, fmt.Errorf("节点 %s %w: 前置节点超过 %d 层", p.Name, ErrInvalid, maxDetours)
+		}
+		current.detourTag = fmt.Sprintf("%s-detour-%d", tag, len(hops)+1)
+		hops = append(hops, current)
+		var next Proxy
+		if detour == DetourUpstream {
+			if !upstream.Enabled() {
+				return nil, fmt.Errorf("节点 %s %w: 没有配置上游代理", current.Name, ErrInvalid)
+			}
+			next = upstream.proxy()
+		} else {
+			var err error
+			if next, err = Find(proxies, detour); err != nil {
+				return nil, err
+			}
+			if seen[next.ID] {
+				return nil, fmt.Errorf("节点 %s %w: 前置节点形成循环", p.Name, ErrInvalid)
+			}
+		}
+		// http 前置只能转发 TCP，基于 UDP 的节点无法经过它连接
+		if current.overUDP() && next.Protocol == ProtocolHTTP {
+			return nil, fmt.Errorf("节点 %s %w: %s 基于 UDP，不能经过只支持 TCP 的 %s", current.Name, ErrInvalid, current.Protocol, next.Name)
+		}
+		current = next
+	}
+}
+
+// chainProxies 展开节点链路，只有在用到其他节点作为前置时才读取节点列表
+func chainProxies(ctx context.Context, p Proxy, tag string) ([]Proxy, error) {
+	var proxies []Proxy
+	if p.Detour != "" && p.Detour != DetourUpstream {
+		proxies = Get(ctx)
+	}
+	return chainOf(p, tag, proxies, LoadUpstream())
+}
+
+// hopOptions 生成链路中每一跳的出站配置，前置出站的 tag 由 chainOf 设置
+func hopOptions(hops []Proxy, tag string) ([]option.Outbound, error) {
+	outbounds := make([]option.Outbound, 0, len(hops))
+	for i, hop := range hops {
+		out, err := outboundOptions(hop)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			out.Tag = hops[i-1].detourTag
+		} else {
+			out.Tag = tag
+		}
+		outbounds = append(outbounds, out)
+	}
+	return outbounds, nil
+}
+
+// options 生成链路的配置，wireguard 作为端点放入 Endpoints
+func options(hops []Proxy, tag string) (Chain, error) {
+	outbounds, err := hopOptions(hops, tag)
+	if err != nil {
+		return Chain{}, err
+	}
+	chain := Chain{Host: hops[len(hops)-1].Host}
+	for _, out := range outbounds {
+		if out.Type == constant.TypeWireGuard {
+			chain.Endpoints = append(chain.Endpoints, option.Endpoint{Type: out.Type, Tag: out.Tag, Options: out.Options})
+		} else {
+			chain.Outbounds = append(chain.Outbounds, out)
+		}
+	}
+	return chain, nil
+}
+
+// dialChain 为探测创建链路出站，从第一跳开始依次创建，返回节点本身的出站
+// 独立的出站没有 DNS 模块，第一跳的域名需要先解析为 IP，之后的各跳由前置出站远程解析
+// wireguard 端点总是在本地解析对端地址，所以任意一跳都需要预先解析
+func dialChain(ctx context.Context, hops []Proxy, tag string) (adapter.Outbound, error) {
+	hops = append([]Proxy(nil), hops...)
+	for i := range hops {
+		if i != len(hops)-1 && hops[i].Protocol != ProtocolWireGuard {
+			continue
+		}
+		if err := hops[i].resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+	outbounds, err := hopOptions(hops, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(hops) == 1 {
+		return newOutbound(ctx, outbounds[0])
+	}
+	manager := &chainManager{outbounds: make(map[string]adapter.Outbound)}
+	ctx = service.ContextWith[adapter.OutboundManager](ctx, manager)
+	var outbound adapter.Outbound
+	for i := len(outbounds) - 1; i >= 0; i-- {
+		if outbound, err = newOutbound(ctx, outbounds[i]); err != nil {
+			_ = manager.Close()
+			return nil, err
+		}
+		manager.outbounds[outbounds[i].Tag] = outbound
+	}
+	return &chainOutbound{Outbound: outbound, manager: manager}, nil
+}
+
+// resolve 将节点的域名解析为 IP，TLS 仍然使用原来的域名
+func (p *Proxy) resolve(ctx context.Context) error {
+	if _, err := netip.ParseAddr(p.Host); err == nil {
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupNetIP(ctx, "ip4", p.Host)
+	if err != nil {
+		return err
+	}
+	if p.TLS != nil || p.requiresTLS() {
+		t := TLS{}
+		if p.TLS != nil {
+			t = *p.TLS
+		}
+		if t.ServerName == "" {
+			t.ServerName = p.Host
+		}
+		p.TLS = &t
+	}
+	p.Host = ips[0].String()
+	return nil
+}
+
+// chainOutbound 关闭时同时关闭链路中的所有出站
+type chainOutbound struct {
+	adapter.Outbound
+	manager *chainManager
+}
+
+func (o *chainOutbound) Close() error {
+	return o.manager.Close()
+}
+
+// chainManager 探测链路时供前置出站按 tag 查找的最小 OutboundManager
+type chainManager struct {
+	outbounds map[string]adapter.Outbound
+}
+
+func (m *chainManager) Start(adapter.StartStage) error {
+	return nil
+}
+
+func (m *chainManager) Close() error {
+	for _, outbound := range m.outbounds {
+		_ = common.Close(outbound)
+	}
+	return nil
+}
+
+func (m *chainManager) Outbounds() []adapter.Outbound {
+	outbounds := make([]adapter.Outbound, 0, len(m.outbounds))
+	for _, outbound := range m.outbounds {
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds
+}
+
+func (m *chainManager) Outbound(tag string) (adapter.Outbound, bool) {
+	outbound, ok := m.outbounds[tag]
+	return outbound, ok
+}
+
+func (m *chainManager) Default() adapter.Outbound {
+	return nil
+}
+
+func (m *chainManager) Remove(string) error {
+	return E.New("not supported")
+}
+
+func (m *chainManager) Create(context.Context, adapter.Router, log.ContextLogger, string, string, any) error {
+	return E.New("not supported")
+}
@@ -0,0 +1,327 @@
+package node
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func TestChainOf(t *testing.T) {
+	upstream := Upstream{Type: ProtocolHTTP, Host: "10.0.0.1", Port: 3128}
+	proxies := []Proxy{
+		{ID: "jp", Name: "日本", Protocol: ProtocolTrojan, Host: "jp.example.com", Port: 443, Password: "a", Detour: "relay"},
+		{ID: "relay", Name: "中转", Protocol: ProtocolShadowsocks, Host: "1.2.3.4", Port: 8388, Method: "aes-128-gcm", Password: "a", Detour: DetourUpstream},
+		{ID: "loop", Name: "循环", Protocol: ProtocolTrojan, Host: "1.2.3.5", Port: 443, Password: "a", Detour: "loop2"},
+		{ID: "loop2", Name: "循环2", Protocol: ProtocolTrojan, Host: "1.2.3.6", Port: 443, Password: "a", Detour: "loop"},
+		{ID: "hk", Name: "香港", Protocol: ProtocolTrojan, Host: "1.2.3.7", Port: 443, Password: "a"},
+	}
+	hops, err := chainOf(proxies[0], "proxy-1", proxies, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := options(hops, "proxy-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Outbounds) != 3 || chain.Host != "10.0.0.1" {
+		t.Fatalf("unexpected chain %+v", chain)
+	}
+	tags := []string{"proxy-1", "proxy-1-detour-1", "proxy-1-detour-2"}
+	for i, out := range chain.Outbounds {
+		if out.Tag != tags[i] {
+			t.Errorf("hop %d: tag %s, want %s", i, out.Tag, tags[i])
+		}
+	}
+	if detour := chain.Outbounds[0].Options.(*option.TrojanOutboundOptions).Detour; detour != tags[1] {
+		t.Errorf("node detour %q, want %q", detour, tags[1])
+	}
+	if detour := chain.Outbounds[1].Options.(*option.ShadowsocksOutboundOptions).Detour; detour != tags[2] {
+		t.Errorf("relay detour %q, want %q", detour, tags[2])
+	}
+	if http := chain.Outbounds[2].Options.(*option.HTTPOutboundOptions); http.Detour != "" || http.Server != "10.0.0.1" {
+		t.Errorf("unexpected upstream %+v", http)
+	}
+
+	if _, err = chainOf(proxies[2], "proxy", proxies, upstream); err == nil {
+		t.Error("expected loop error")
+	}
+	if _, err = chainOf(proxies[1], "proxy", proxies, Upstream{}); err == nil {
+		t.Error("expected error without upstream")
+	}
+	if _, err = chainOf(Proxy{ID: "x", Protocol: ProtocolTrojan, Host: "1.2.3.4", Port: 443, Password: "a", Detour: "missing"}, "proxy", proxies, upstream); errorKind(err) != ErrorKindUnknownNode {
+		t.Errorf("expected unknown node, got %v", err)
+	}
+
+	// 所有节点经过上游代理
+	upstream.AllNodes = true
+	if hops, err = chainOf(proxies[4], "proxy", proxies, upstream); err != nil || len(hops) != 2 {
+		t.Errorf("expected hk via upstream, got %d hops %v", len(hops), err)
+	}
+}
+
+// socksServer 只支持 CONNECT 的 SOCKS5 服务端，记录每次请求的目标地址
+type socksServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	targets  []string
+}
+
+func newSocksServer(t *testing.T) *socksServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socksServer{listener: listener}
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socksServer) port() uint16 {
+	return uint16(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *socksServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	methods := buf[1]
+	if _, err := io.ReadFull(conn, buf[:methods]); err != nil {
+		return
+	}
+	method := byte(0)
+	for _, m := range buf[:methods] {
+		if m == 2 {
+			method = 2
+		}
+	}
+	_, _ = conn.Write([]byte{5, method})
+	if method == 2 {
+		// 用户名密码认证，接受任意凭据
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		userLen := int(buf[1])
+		if _, err := io.ReadFull(conn, buf[:userLen+1]); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, buf[:buf[userLen]]); err != nil {
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	}
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case 1:
+		if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+			return
+		}
+		host = net.IP(buf[:4]).String()
+	case 3:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return
+		}
+		n := int(buf[0])
+		if _, err := io.ReadFull(conn, buf[:n]); err != nil {
+			return
+		}
+		host = string(buf[:n])
+	default:
+		return
+	}
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(buf[:2]))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+	remote, err := net.DialTimeout("tcp", target, time.Second)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer func() { _ = remote.Close() }()
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	go func() { _, _ = io.Copy(remote, conn) }()
+	_, _ = io.Copy(conn, remote)
+}
+
+func (s *socksServer) seen() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+func TestDialChain(t *testing.T) {
+	upstreamServer := newSocksServer(t)
+	nodeServer := newSocksServer(t)
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	p := Proxy{ID: "node", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: nodeServer.port(), Password: "secret", Detour: DetourUpstream}
+	upstream := Upstream{Type: ProtocolSOCKS, Host: "127.0.0.1", Port: upstreamServer.port()}
+	hops, err := chainOf(p, "proxy", nil, upstream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbound, err := dialChain(context.Background(), hops, "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = common.Close(outbound) }()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := outbound.DialContext(ctx, "tcp", M.ParseSocksaddr(target.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	nodeAddr := net.JoinHostPort("127.0.0.1", strconv.Itoa(int(nodeServer.port())))
+	if seen := upstreamServer.seen(); len(seen) != 1 || seen[0] != nodeAddr {
+		t.Errorf("upstream should connect to the node, got %v", seen)
+	}
+	if seen := nodeServer.seen(); len(seen) != 1 || seen[0] != target.Addr().String() {
+		t.Errorf("node should connect to the target, got %v", seen)
+	}
+}
+
+func TestQUICDetour(t *testing.T) {
+	hops, err := chainOf(Proxy{ID: "hy2", Protocol: ProtocolHysteria2, Host: "1.2.3.4", Port: 443, Password: "a", Detour: DetourUpstream}, "proxy", nil, Upstream{Type: ProtocolSOCKS, Host: "127.0.0.1", Port: 1080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := options(hops, "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if detour := chain.Outbounds[0].Options.(*option.Hysteria2OutboundOptions).Detour; detour != "proxy-detour-1" {
+		t.Errorf("hysteria2 detour %q, want proxy-detour-1", detour)
+	}
+}
+
+func TestUDPDetour(t *testing.T) {
+	httpUpstream := Upstream{Type: ProtocolHTTP, Host: "10.0.0.1", Port: 3128}
+	socksUpstream := Upstream{Type: ProtocolSOCKS, Host: "10.0.0.1", Port: 1080}
+	proxies := []Proxy{
+		{ID: "http", Name: "http 中转", Protocol: ProtocolHTTP, Host: "1.2.3.4", Port: 8080},
+		{ID: "ss", Name: "ss 中转", Protocol: ProtocolShadowsocks, Host: "1.2.3.5", Port: 8388, Method: "aes-128-gcm", Password: "a", Detour: "http"},
+	}
+	hy2 := Proxy{ID: "hy2", Name: "hy2", Protocol: ProtocolHysteria2, Host: "1.2.3.6", Port: 443, Password: "a"}
+	tuic := Proxy{ID: "tuic", Name: "tuic", Protocol: ProtocolTUIC, Host: "1.2.3.7", Port: 443, UUID: "b831381d-6324-4d53-ad4f-8cda48b30811", Password: "a"}
+	trojan := Proxy{ID: "trojan", Name: "trojan", Protocol: ProtocolTrojan, Host: "1.2.3.8", Port: 443, Password: "a"}
+	quic := Proxy{ID: "quic", Name: "quic", Protocol: ProtocolShadowsocks, Host: "1.2.3.9", Port: 8388, Method: "aes-128-gcm", Password: "a", Plugin: "v2ray-plugin", PluginOpts: "mode=quic;tls"}
+	with := func(p Proxy, detour string) Proxy {
+		p.Detour = detour
+		return p
+	}
+	tests := []struct {
+		name     string
+		proxy    Proxy
+		upstream Upstream
+		ok       bool
+	}{
+		{"hysteria2 经过 http 上游", with(hy2, DetourUpstream), httpUpstream, false},
+		{"hysteria2 经过 socks 上游", with(hy2, DetourUpstream), socksUpstream, true},
+		{"tuic 经过 http 节点", with(tuic, "http"), Upstream{}, false},
+		{"tuic 经过 ss 节点再经过 http", with(tuic, "ss"), Upstream{}, true},
+		{"trojan 经过 http 节点", with(trojan, "http"), Upstream{}, true},
+		{"v2ray-plugin quic 经过 http 节点", with(quic, "http"), Upstream{}, false},
+		{"所有节点经过 http 上游", hy2, Upstream{Type: ProtocolHTTP, Host: "10.0.0.1", Port: 3128, AllNodes: true}, false},
+		{"所有节点经过 socks 上游", hy2, Upstream{Type: ProtocolSOCKS, Host: "10.0.0.1", Port: 1080, AllNodes: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := chainOf(tt.proxy, "proxy", proxies, tt.upstream)
+			if (err == nil) != tt.ok {
+				t.Errorf("ok %v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestDialTemporary(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = target.Close() }()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	destination := M.ParseSocksaddr(target.Addr().String())
+	if _, err = DialTemporary(context.Background(), "tcp", destination); err == nil {
+		t.Fatal("expected error without nodes")
+	}
+
+	// 最近使用的节点已经无法连接，尝试下一个节点
+	first, recent, dead := newSocksServer(t), newSocksServer(t), newSocksServer(t)
+	_ = dead.listener.Close()
+	data, _ := json.Marshal([]Proxy{
+		{ID: "maintenance", Name: "维护", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: 1, Maintenance: true},
+		{ID: "first", Name: "第一个", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: first.port()},
+		{ID: "recent", Name: "最近使用", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: recent.port()},
+		{ID: "dead", Name: "不可用", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: dead.port()},
+	})
+	entry, _ := json.Marshal(cacheEntry{UpdatedAt: time.Now(), Data: data})
+	if err = os.WriteFile(remote.file(), entry, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"recent", "dead"} {
+		if err = AddRecent(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conn, err := DialTemporary(context.Background(), "tcp", destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if len(recent.seen()) != 1 || len(first.seen()) != 0 {
+		t.Errorf("expected the recent node, got recent=%v first=%v", recent.seen(), first.seen())
+	}
+}
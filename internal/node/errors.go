@@ -0,0 +1,128 @@
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// 节点错误的分类，同时作为传给前端的错误代码
+const (
+	ErrorKindUnknownNode    = "unknown_node"
+	ErrorKindUnsupported    = "unsupported"
+	ErrorKindInvalid        = "invalid"
+	ErrorKindMaintenance    = "maintenance"
+	ErrorKindDNS            = "dns"
+	ErrorKindRefused        = "refused"
+	ErrorKindConnectTimeout = "connect_timeout"
+	ErrorKindTLS            = "tls"     // TLS 握手失败
+	ErrorKindTimeout        = "timeout" // 已连接到节点，但探测超时
+	ErrorKindUnknown        = "unknown"
+)
+
+// ErrUnknownNode 节点不存在
+var ErrUnknownNode = errors.New("节点不存在")
+
+var kindText = map[string]string{
+	ErrorKindUnknownNode:    "不存在",
+	ErrorKindUnsupported:    "协议不受支持",
+	ErrorKindInvalid:        "配置错误",
+	ErrorKindMaintenance:    "维护中",
+	ErrorKindDNS:            "域名解析失败",
+	ErrorKindRefused:        "拒绝连接",
+	ErrorKindConnectTimeout: "连接超时",
+	ErrorKindTLS:            "TLS 握手失败",
+	ErrorKindTimeout:        "探测超时",
+	ErrorKindUnknown:        "不可用",
+}
+
+// Error 使用节点失败的原因，Kind 为 ErrorKind 常量之一
+type Error struct {
+	Kind string
+	Node string // 节点 ID
+	Name string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("节点 %s %s: %v", e.Name, kindText[e.Kind], e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// nodeError 将错误归类并带上节点 ID，已经是 *Error 时原样返回
+func nodeError(p Proxy, err error) error {
+	var e *Error
+	if err == nil || errors.As(err, &e) {
+		return err
+	}
+	return &Error{Kind: errorKind(err), Node: p.ID, Name: p.Name, Err: err}
+}
+
+// errorKind 将错误归类，便于界面展示
+func errorKind(err error) string {
+	var nodeErr *Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var netErr net.Error
+	var alertErr tls.AlertError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &nodeErr):
+		return nodeErr.Kind
+	case errors.Is(err, ErrUnknownNode):
+		return ErrorKindUnknownNode
+	case errors.Is(err, ErrUnsupported):
+		return ErrorKindUnsupported
+	case errors.Is(err, ErrInvalid):
+		return ErrorKindInvalid
+	case errors.Is(err, ErrMaintenance):
+		return ErrorKindMaintenance
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindRefused
+	case errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout():
+		return ErrorKindConnectTimeout
+	case errors.As(err, &alertErr), errors.As(err, &certErr), errors.As(err, &recordErr):
+		return ErrorKindTLS
+	// 服务端直接断开连接，可能是密码错误，也可能是端口被拦截
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNRESET):
+		return ErrorKindRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorKindTimeout
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout
+	}
+	return ErrorKindUnknown
+}
+
+// ErrorInfo 传给前端的错误，Code 为 ErrorKind 常量之一
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Node    string `json:"node"`
+	Message string `json:"message"`
+}
+
+// NewErrorInfo 将错误转换为前端使用的结构，err 为 nil 时返回 nil
+func NewErrorInfo(err error) *ErrorInfo {
+	if err == nil {
+		return nil
+	}
+	info := &ErrorInfo{Code: errorKind(err), Message: err.Error()}
+	var e *Error
+	if errors.As(err, &e) {
+		info.Node = e.Node
+	}
+	return info
+}
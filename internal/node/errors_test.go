@@ -0,0 +1,58 @@
+package node
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestErrorKind(t *testing.T) {
+	timeout := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	tests := map[string]error{
+		ErrorKindUnsupported:    errUnsupported("wireguard"),
+		ErrorKindInvalid:        Proxy{Name: "a", Protocol: ProtocolTrojan}.Validate(),
+		ErrorKindMaintenance:    fmt.Errorf("节点 a: %w", ErrMaintenance),
+		ErrorKindDNS:            &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true},
+		ErrorKindRefused:        &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED},
+		ErrorKindConnectTimeout: fmt.Errorf("dial: %w", timeout),
+		ErrorKindTLS:            tls.AlertError(40),
+		ErrorKindTimeout:        fmt.Errorf("probe: %w", context.DeadlineExceeded),
+		ErrorKindUnknown:        errors.New("other"),
+	}
+	for want, err := range tests {
+		if got := errorKind(err); got != want {
+			t.Errorf("%v: got %s, want %s", err, got, want)
+		}
+	}
+	// 服务端断开连接不是 TLS 错误
+	for _, err := range []error{fmt.Errorf("read: %w", io.EOF), &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}} {
+		if got := errorKind(err); got != ErrorKindRefused {
+			t.Errorf("%v: closed connection should be refused, got %s", err, got)
+		}
+	}
+}
+
+func TestNodeError(t *testing.T) {
+	p := Proxy{ID: "remote-1", Name: "香港"}
+	err := fmt.Errorf("设备 1.2.3.4 的节点不可用: %w", nodeError(p, &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}))
+	info := NewErrorInfo(err)
+	if info == nil || info.Code != ErrorKindRefused || info.Node != "remote-1" {
+		t.Errorf("unexpected error info %+v", info)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Error("node error should unwrap to the cause")
+	}
+	if NewErrorInfo(nil) != nil {
+		t.Error("nil error should have no info")
+	}
+	_, err = Find([]Proxy{p}, "missing")
+	if info = NewErrorInfo(err); info.Code != ErrorKindUnknownNode || info.Node != "missing" {
+		t.Errorf("unexpected unknown node info %+v", info)
+	}
+}
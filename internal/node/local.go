@@ -0,0 +1,186 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"strconv"
+	"sync"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// SourceLocal 用户在本地添加的节点
+const SourceLocal = "local"
+
+var localLock sync.Mutex
+
+func localFile() string {
+	return filepath.Join(path.Path(), "nodes.json")
+}
+
+// LoadLocal 读取本地节点列表 nodes.json
+func LoadLocal() []Proxy {
+	localLock.Lock()
+	defer localLock.Unlock()
+	return loadLocal()
+}
+
+func loadLocal() []Proxy {
+	proxies := make([]Proxy, 0)
+	data, err := os.ReadFile(localFile())
+	if err != nil {
+		return proxies
+	}
+	_ = json.Unmarshal(data, &proxies)
+	seen := make(map[string]int, len(proxies))
+	for i := range proxies {
+		proxies[i].Source = SourceLocal
+		// 手动编辑的 nodes.json 可能没有 ID，完全相同的节点与 merge 一样加上序号，
+		// 保证列表中显示的 ID 可以用来修改和删除
+		if proxies[i].ID == "" {
+			id := stableID(proxies[i])
+			if seen[id]++; seen[id] > 1 {
+				id += "-" + strconv.Itoa(seen[id])
+			}
+			proxies[i].ID = id
+		}
+	}
+	return proxies
+}
+
+// updateLocal 在锁内读取、修改并保存本地节点
+func updateLocal(update func([]Proxy) ([]Proxy, error)) error {
+	localLock.Lock()
+	defer localLock.Unlock()
+	proxies, err := update(loadLocal())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(proxies, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(localFile(), data, 0644)
+}
+
+// AddNode 添加本地节点并返回分配的 ID
+// ID 与服务端或导入的节点相同时，本地节点会覆盖该节点
+func AddNode(p Proxy) (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	if p.ID == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return "", err
+		}
+		p.ID = id.String()
+	}
+	p.Source = SourceLocal
+	return p.ID, updateLocal(func(proxies []Proxy) ([]Proxy, error) {
+		for _, local := range proxies {
+			if local.ID == p.ID {
+				return nil, fmt.Errorf("节点 %s 已存在", p.ID)
+			}
+		}
+		return append(proxies, p), nil
+	})
+}
+
+// UpdateNode 按 ID 修改本地节点
+func UpdateNode(p Proxy) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	p.Source = SourceLocal
+	return updateLocal(func(proxies []Proxy) ([]Proxy, error) {
+		for i := range proxies {
+			if proxies[i].ID == p.ID {
+				proxies[i] = p
+				return proxies, nil
+			}
+		}
+		return nil, fmt.Errorf("本地节点 %s 不存在", p.ID)
+	})
+}
+
+// DeleteNode 按 ID 删除本地节点
+func DeleteNode(id string) error {
+	return updateLocal(func(proxies []Proxy) ([]Proxy, error) {
+		for i := range proxies {
+			if proxies[i].ID == id {
+				return append(proxies[:i], proxies[i+1:]...), nil
+			}
+		}
+		return nil, fmt.Errorf("本地节点 %s 不存在", id)
+	})
+}
+
+// merge 合并各来源的节点，补全缺失的 ID，本地节点覆盖 ID 相同的其他节点
+func merge(remote, local, imported []Proxy) []Proxy {
+	proxies := make([]Proxy, 0, len(remote)+len(local)+len(imported))
+	proxies = append(append(proxies, remote...), imported...)
+	for i := range proxies {
+		if proxies[i].ID == "" {
+			proxies[i].ID = stableID(proxies[i])
+		}
+	}
+	index := make(map[string]int, len(proxies))
+	for i, p := range proxies {
+		if _, ok := index[p.ID]; !ok {
+			index[p.ID] = i
+		}
+	}
+	for _, p := range local {
+		if p.ID == "" {
+			p.ID = stableID(p)
+		}
+		if i, ok := index[p.ID]; ok {
+			proxies[i] = p
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	// 完全相同的节点出现多次时加上序号区分
+	seen := make(map[string]int, len(proxies))
+	for i := range proxies {
+		id := proxies[i].ID
+		if seen[id]++; seen[id] > 1 {
+			proxies[i].ID = id + "-" + strconv.Itoa(seen[id])
+		}
+	}
+	return proxies
+}
+
+// stableID 由节点来源和连接参数生成 ID，改名不会改变 ID
+func stableID(p Proxy) string {
+	prefix := p.Source
+	switch p.Source {
+	case SourceRemote, SourceLink, SourceLocal:
+	default:
+		prefix = "sub"
+	}
+	key := fmt.Sprintf("%s|%s|%s|%d|%s|%s", p.Source, p.Protocol, p.Host, p.Port, p.UUID, p.Password)
+	sum := sha256.Sum256([]byte(key))
+	return prefix + "-" + hex.EncodeToString(sum[:6])
+}
+
+// Find 按 ID 查找节点，找不到时按名称查找以兼容以前按名称保存的配置
+func Find(proxies []Proxy, id string) (Proxy, error) {
+	for _, p := range proxies {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	for _, p := range proxies {
+		if p.Name == id {
+			return p, nil
+		}
+	}
+	return Proxy{}, &Error{Kind: ErrorKindUnknownNode, Node: id, Name: id, Err: ErrUnknownNode}
+}
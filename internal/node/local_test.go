@@ -0,0 +1,142 @@
+package node
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	remote := []Proxy{
+		{Name: "香港", Protocol: ProtocolTrojan, Host: "1.2.3.4", Port: 443, Password: "a", Source: SourceRemote},
+		{Name: "香港", Protocol: ProtocolTrojan, Host: "1.2.3.5", Port: 443, Password: "a", Source: SourceRemote},
+		{ID: "jp", Name: "日本", Protocol: ProtocolTrojan, Host: "1.2.3.6", Port: 443, Password: "a", Source: SourceRemote},
+	}
+	imported := []Proxy{
+		{Name: "dup", Protocol: ProtocolTrojan, Host: "1.2.3.7", Port: 443, Password: "a", Source: SourceLink},
+		{Name: "dup", Protocol: ProtocolTrojan, Host: "1.2.3.7", Port: 443, Password: "a", Source: SourceLink},
+	}
+	local := []Proxy{
+		{ID: "jp", Name: "日本测试", Protocol: ProtocolTrojan, Host: "10.0.0.1", Port: 443, Password: "b", Source: SourceLocal},
+		{ID: "qa", Name: "QA", Protocol: ProtocolTrojan, Host: "10.0.0.2", Port: 443, Password: "b", Source: SourceLocal},
+	}
+	proxies := merge(remote, local, imported)
+	if len(proxies) != 6 {
+		t.Fatalf("unexpected nodes %+v", proxies)
+	}
+	ids := make(map[string]bool)
+	for _, p := range proxies {
+		if p.ID == "" || ids[p.ID] {
+			t.Errorf("duplicate or empty id %q", p.ID)
+		}
+		ids[p.ID] = true
+	}
+	if p := proxies[2]; p.ID != "jp" || p.Source != SourceLocal || p.Host != "10.0.0.1" {
+		t.Errorf("local node should override remote node, got %+v", p)
+	}
+	if proxies[4].ID != proxies[3].ID+"-2" {
+		t.Errorf("unexpected duplicate ids %s %s", proxies[3].ID, proxies[4].ID)
+	}
+
+	// 改名不影响 ID
+	renamed := remote[0]
+	renamed.Name = "香港 2"
+	if stableID(renamed) != proxies[0].ID {
+		t.Error("id should not depend on name")
+	}
+
+	if p, err := Find(proxies, proxies[1].ID); err != nil || p.Host != "1.2.3.5" {
+		t.Errorf("find by id: %+v %v", p, err)
+	}
+	if p, err := Find(proxies, "QA"); err != nil || p.ID != "qa" {
+		t.Errorf("find by name: %+v %v", p, err)
+	}
+	if _, err := Find(proxies, "missing"); err == nil {
+		t.Error("expected error for unknown node")
+	}
+}
+
+func TestMergeLocalWithoutID(t *testing.T) {
+	local := []Proxy{
+		{Name: "a", Protocol: ProtocolTrojan, Host: "10.0.0.1", Port: 443, Password: "b", Source: SourceLocal},
+		{Name: "b", Protocol: ProtocolTrojan, Host: "10.0.0.2", Port: 443, Password: "b", Source: SourceLocal},
+		{Name: "a 副本", Protocol: ProtocolTrojan, Host: "10.0.0.1", Port: 443, Password: "b", Source: SourceLocal},
+	}
+	proxies := merge(nil, local, nil)
+	if len(proxies) != 3 {
+		t.Fatalf("unexpected nodes %+v", proxies)
+	}
+	// 没有 ID 的本地节点使用稳定 ID，改名不会改变
+	if proxies[0].ID != stableID(local[0]) || proxies[1].ID != stableID(local[1]) {
+		t.Errorf("unexpected ids %s %s", proxies[0].ID, proxies[1].ID)
+	}
+	if proxies[2].ID != proxies[0].ID+"-2" {
+		t.Errorf("unexpected duplicate id %s", proxies[2].ID)
+	}
+	if again := merge(nil, local, nil); again[1].ID != proxies[1].ID {
+		t.Error("id changed between merges")
+	}
+}
+
+func TestLocalNodes(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	p := Proxy{Name: "QA", Protocol: ProtocolTrojan, Host: "10.0.0.1", Port: 443, Password: "a"}
+	id, err := AddNode(p)
+	if err != nil || id == "" {
+		t.Fatal(id, err)
+	}
+	if _, err = AddNode(Proxy{Name: "bad", Protocol: ProtocolTrojan}); err == nil {
+		t.Error("invalid node should be rejected")
+	}
+	p.ID, p.Port = id, 8443
+	if err = UpdateNode(p); err != nil {
+		t.Fatal(err)
+	}
+	local := LoadLocal()
+	if len(local) != 1 || local[0].Port != 8443 || local[0].Source != SourceLocal {
+		t.Fatalf("unexpected local nodes %+v", local)
+	}
+	if err = DeleteNode(id); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteNode(id); err == nil {
+		t.Error("expected error deleting missing node")
+	}
+	if len(LoadLocal()) != 0 {
+		t.Error("node not deleted")
+	}
+
+	// 手动编辑的 nodes.json 中没有 ID 的节点可以按稳定 ID 删除
+	data := `[{"name":"manual","protocol":"trojan","host":"10.0.0.3","port":443,"password":"a"}]`
+	if err = os.WriteFile(localFile(), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if local = LoadLocal(); len(local) != 1 || local[0].ID != stableID(local[0]) {
+		t.Fatalf("unexpected local nodes %+v", local)
+	}
+	if err = DeleteNode(local[0].ID); err != nil {
+		t.Error(err)
+	}
+
+	// 完全相同的手动节点在列表中带序号，可以按列表中的 ID 修改和删除
+	data = `[{"name":"a","protocol":"trojan","host":"10.0.0.4","port":443,"password":"a"},
+{"name":"b","protocol":"trojan","host":"10.0.0.4","port":443,"password":"a"}]`
+	if err = os.WriteFile(localFile(), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	proxies := merge(nil, LoadLocal(), nil)
+	if len(proxies) != 2 || proxies[1].ID != proxies[0].ID+"-2" {
+		t.Fatalf("unexpected nodes %+v", proxies)
+	}
+	second := proxies[1]
+	second.Name = "b 改"
+	if err = UpdateNode(second); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeleteNode(proxies[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if local = LoadLocal(); len(local) != 1 || local[0].ID != second.ID || local[0].Name != "b 改" {
+		t.Errorf("unexpected local nodes %+v", local)
+	}
+}
@@ -0,0 +1,27 @@
+package node
+
+import (
+	"context"
+	"playfast/internal/nat"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// DetectNAT 通过节点出站向 STUN 服务器发起检测，得到经过节点后的 NAT 类型
+// 启用了 UDP over TCP 的节点同样适用，UDP 包由出站封装后转发
+func DetectNAT(ctx context.Context, proxy string) (*nat.Result, error) {
+	p, err := Find(Get(ctx), proxy)
+	if err != nil {
+		return nil, err
+	}
+	outbound, err := dialOutbound(ctx, p)
+	if err != nil {
+		return nil, nodeError(p, err)
+	}
+	conn, err := outbound.ListenPacket(ctx, M.ParseSocksaddr(nat.DefaultServer))
+	if err != nil {
+		return nil, nodeError(p, err)
+	}
+	defer func() { _ = conn.Close() }()
+	return nat.NewDetector(conn).Detect(ctx, nat.DefaultServer)
+}
@@ -2,123 +2,205 @@ package node
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"playfast/internal/api"
-	"playfast/internal/echo"
-	"playfast/internal/http-client"
+	"net"
+	"playfast/internal/probe"
 	"time"
 
+	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/include"
 	slog "github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	M "github.com/sagernet/sing/common/metadata"
 )
 
 type Proxy struct {
-	Name     string `json:"name"`
-	Method   string `json:"method"`
-	Password string `json:"password"`
-	Host     string `json:"host"`
-	Port     uint16 `json:"port"`
-	Protocol string `json:"protocol"`
+	ID       string        `json:"id,omitempty"` // 稳定的节点标识，服务端未提供时由连接参数生成
+	Name     string        `json:"name"`
+	Method   string        `json:"method"`
+	Password string        `json:"password"`
+	Host     string        `json:"host"`
+	Port     uint16        `json:"port"`
+	Protocol string        `json:"protocol"`
+	Region   string        `json:"region,omitempty"` // 地区代码，如 HK、JP，用于分组和限定智能选择的范围
+	Source   string        `json:"source,omitempty"` // 节点来源：remote、link 或订阅地址
+	Probe    *probe.Config `json:"probe,omitempty"`  // 节点指定的延迟探测目标
+
+	Tags        []string `json:"tags,omitempty"`        // 标签，如 PSN、Steam
+	Load        int      `json:"load,omitempty"`        // 服务端上报的负载百分比
+	Multiplier  float64  `json:"multiplier,omitempty"`  // 流量倍率，0 表示 1 倍
+	Maintenance bool     `json:"maintenance,omitempty"` // 维护中的节点不参与智能选择，也不能使用
+
+	Username          string     `json:"username,omitempty"`           // socks 用户名，有密码时默认 playfast
+	UUID              string     `json:"uuid,omitempty"`               // vless/vmess/tuic，vless 和 vmess 为空时使用 Password
+	Security          string     `json:"security,omitempty"`           // vmess 加密方式，默认 auto
+	AlterID           int        `json:"alter_id,omitempty"`           // vmess
+	UpMbps            int        `json:"up_mbps,omitempty"`            // hysteria2
+	DownMbps          int        `json:"down_mbps,omitempty"`          // hysteria2
+	Obfs              string     `json:"obfs,omitempty"`               // hysteria2 混淆密码，使用 salamander
+	CongestionControl string     `json:"congestion_control,omitempty"` // tuic 拥塞控制，默认 bbr
+	TLS               *TLS       `json:"tls,omitempty"`                // trojan/vmess/vless/hysteria2/tuic/anytls
+	Flow              string     `json:"flow,omitempty"`               // vless 流控，仅支持 xtls-rprx-vision
+	Transport         *Transport `json:"transport,omitempty"`          // vless/vmess/trojan
+	Tuning            *Tuning    `json:"tuning,omitempty"`             // 多路复用、TFO、MPTCP 和 UDP over TCP
+	Plugin            string     `json:"plugin,omitempty"`             // shadowsocks SIP003 插件，支持 obfs-local 和 v2ray-plugin
+	PluginOpts        string     `json:"plugin_opts,omitempty"`        // 插件参数，如 obfs=http;obfs-host=example.com
+	Detour            string     `json:"detour,omitempty"`             // 前置节点 ID，或 upstream 表示本地配置的上游代理
+
+	PrivateKey   string   `json:"private_key,omitempty"`    // wireguard 本地私钥
+	PublicKey    string   `json:"public_key,omitempty"`     // wireguard 对端公钥
+	PreSharedKey string   `json:"pre_shared_key,omitempty"` // wireguard 预共享密钥
+	Address      []string `json:"address,omitempty"`        // wireguard 本地地址，如 10.0.0.2/32
+	AllowedIPs   []string `json:"allowed_ips,omitempty"`    // wireguard 允许的地址，默认全部
+	Reserved     []int    `json:"reserved,omitempty"`       // wireguard reserved 字节，如 WARP 需要
+	MTU          uint32   `json:"mtu,omitempty"`            // wireguard MTU，默认 1408
+
+	detourTag string // 生成出站时前置出站的 tag
 }
 
-func Get() []Proxy {
-	data := make([]Proxy, 0)
-	all, err := http_client.GET(fmt.Sprintf("%s/proxy.json", api.GetApiDomain()))
+// Get 返回服务端下发的节点、本地节点和导入的节点，并标记来源
+// 服务端节点列表在 remoteTTL 内使用缓存，服务端不可用时使用上次成功的结果
+func Get(ctx context.Context) []Proxy {
+	return withLocal(remote.get(ctx, false))
+}
+
+// withLocal 标记服务端节点的来源，合并本地节点和导入的节点并应用调优
+func withLocal(data []Proxy) []Proxy {
+	for i := range data {
+		data[i].Source = SourceRemote
+	}
+	return applyTuning(merge(data, LoadLocal(), LoadImported().nodes()), LoadTuning())
+}
+
+// GetOutbound 按节点 ID 生成以 tag 命名的出站链路，探测通过后返回
+// 失败时返回带有节点 ID 和错误分类的 *Error
+func GetOutbound(ctx context.Context, proxy, tag string) (*Chain, error) {
+	p, err := Find(Get(ctx), proxy)
+	if err != nil {
+		return nil, err
+	}
+	hops, err := chainProxies(ctx, p, tag)
+	if err != nil {
+		return nil, nodeError(p, err)
+	}
+	chain, err := options(hops, tag)
 	if err != nil {
-		return data
+		return nil, nodeError(p, err)
 	}
-	_ = json.Unmarshal(all, &data)
-	return data
+	createOutbound, err := dialChain(ctx, hops, tag)
+	if err != nil {
+		return nil, nodeError(p, err)
+	}
+	defer func() { _ = common.Close(createOutbound) }()
+	ms, err := Latency(ctx, p, createOutbound.DialContext)
+	if err != nil {
+		log.Println(fmt.Sprintf("节点选择:ID:%s 节点:%s 探测失败: %v\n", p.ID, p.Name, err))
+		return nil, nodeError(p, err)
+	}
+	log.Println(fmt.Sprintf("节点选择:ID:%s 节点:%s 延迟=%dms\n", p.ID, p.Name, ms))
+	return &chain, nil
 }
-func GetOutbound(proxy string) (*option.Outbound, string, error) {
-	data := Get()
-	for i, p := range data {
-		if p.Name != proxy {
-			continue
-		}
-		var out option.Outbound
-		switch p.Protocol {
-		case "shadowsocks":
-			out = option.Outbound{
-				Type: constant.TypeShadowsocks,
-				Tag:  "proxy",
-				Options: &option.ShadowsocksOutboundOptions{
-					ServerOptions: option.ServerOptions{
-						Server:     p.Host,
-						ServerPort: p.Port,
-					},
-					Method:   p.Method,
-					Password: p.Password,
-					UDPOverTCP: &option.UDPOverTCPOptions{
-						Enabled: true,
-						Version: 2,
-					},
-				},
-			}
-		case "vless":
-			out = option.Outbound{
-				Type: constant.TypeVLESS,
-				Tag:  "proxy",
-				Options: &option.VLESSOutboundOptions{
-					ServerOptions: option.ServerOptions{
-						Server:     p.Host,
-						ServerPort: p.Port,
-					},
-					UUID:                        p.Password,
-					OutboundTLSOptionsContainer: option.OutboundTLSOptionsContainer{},
-					Multiplex: &option.OutboundMultiplexOptions{
-						Enabled:        true,
-						Protocol:       "h2mux",
-						MaxConnections: 8,
-						MinStreams:     16,
-						Padding:        false,
-					},
-				},
-			}
-		case "socks":
-			out = option.Outbound{
-				Type: constant.TypeSOCKS,
-				Tag:  "proxy",
-				Options: &option.SOCKSOutboundOptions{
-					ServerOptions: option.ServerOptions{
-						Server:     p.Host,
-						ServerPort: p.Port,
-					},
-					Version:  "5",
-					Username: "playfast",
-					Password: p.Password,
-					UDPOverTCP: &option.UDPOverTCPOptions{
-						Enabled: true,
-						Version: 2,
-					},
-				},
-			}
-		default:
-			continue
+
+// Latency 按当前游戏或节点配置的探测目标测量经过出站的延迟，单位毫秒
+func Latency(ctx context.Context, p Proxy, dial probe.Dialer) (int64, error) {
+	pr, err := probe.LoadTargets().Resolve(p.Probe).New()
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	latency, err := pr.Probe(ctx, dial)
+	if err != nil {
+		return 0, err
+	}
+	return latency.Milliseconds(), nil
+}
+
+// dialOutbound 为探测创建节点出站，节点设置了前置节点时创建整条链路
+func dialOutbound(ctx context.Context, p Proxy) (adapter.Outbound, error) {
+	hops, err := chainProxies(ctx, p, "proxy")
+	if err != nil {
+		return nil, err
+	}
+	return dialChain(ctx, hops, "proxy")
+}
+
+// maxTemporaryAttempts 临时出站连接失败时最多尝试的节点数
+const maxTemporaryAttempts = 3
+
+// DialTemporary 为控制面请求临时创建节点出站并连接 destination，优先使用最近使用的节点
+// 节点无法创建或连接失败时尝试下一个节点，关闭返回的连接时同时关闭临时出站
+// 只使用缓存的节点列表，不会发起请求，避免获取节点列表本身需要经过节点时重入
+func DialTemporary(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	proxies := withLocal(remote.peek())
+	candidates := make([]Proxy, 0, len(proxies))
+	for _, id := range LoadPreferences().Recent {
+		if p, err := Find(proxies, id); err == nil {
+			candidates = append(candidates, p)
 		}
-		registryOut := include.OutboundRegistry()
-		createOutbound, err := registryOut.CreateOutbound(context.Background(), nil, slog.StdLogger(), out.Type, out.Type, out.Options)
+	}
+	candidates = append(candidates, proxies...)
+	upstream := LoadUpstream()
+	errs := make([]error, 0, maxTemporaryAttempts)
+	for _, p := range candidates {
+		hops, err := chainOf(p, "proxy", proxies, upstream)
 		if err != nil {
+			// 维护中或配置错误的节点
 			continue
 		}
-		client := echo.NewClient("1.1.1.1:80", echo.WithTimeout(3*time.Second), echo.WithDialer(createOutbound.DialContext))
-		err = client.Connect(context.Background())
-		if err != nil {
-			continue
+		conn, err := dialTemporary(ctx, hops, network, destination)
+		if err == nil {
+			return conn, nil
 		}
-		var ms int64
-		result := client.Test(context.Background(), []byte("GET / HTTP/1.1\r\nHost: 1.1.1.1\r\nAccept: *\r\n\r\n\r\n"))
-		ms = result.Latency.Milliseconds()
-		log.Println(fmt.Sprintf("节点选择:ID:%d 节点:%s 延迟=%dms\n", i, p.Name, ms))
-		if ms <= 0 {
-			return nil, "", errors.New("节点超时")
+		if errs = append(errs, nodeError(p, err)); len(errs) >= maxTemporaryAttempts {
+			break
 		}
-		return &out, p.Host, nil
 	}
-	return nil, "", errors.New("not fount Outbound")
+	if len(errs) == 0 {
+		return nil, errors.New("没有可用的节点")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// dialTemporary 创建链路出站并连接 destination，失败时关闭出站
+func dialTemporary(ctx context.Context, hops []Proxy, network string, destination M.Socksaddr) (net.Conn, error) {
+	outbound, err := dialChain(ctx, hops, "proxy")
+	if err != nil {
+		return nil, err
+	}
+	conn, err := outbound.DialContext(ctx, network, destination)
+	if err != nil {
+		_ = common.Close(outbound)
+		return nil, err
+	}
+	return &temporaryConn{Conn: conn, outbound: outbound}, nil
+}
+
+// temporaryConn 关闭连接时同时关闭临时出站
+type temporaryConn struct {
+	net.Conn
+	outbound adapter.Outbound
+}
+
+func (c *temporaryConn) Close() error {
+	err := c.Conn.Close()
+	_ = common.Close(c.outbound)
+	return err
+}
+
+// newOutbound 创建出站，测试时替换
+var newOutbound = createOutbound
+
+// createOutbound 根据配置创建可直接拨号的出站
+// ctx 中带有加速器的 NetworkManager 时，出站会绑定物理网卡，不经过 tun
+func createOutbound(ctx context.Context, out option.Outbound) (adapter.Outbound, error) {
+	if out.Type == constant.TypeWireGuard {
+		return newEndpoint(ctx, out)
+	}
+	registryOut := include.OutboundRegistry()
+	return registryOut.CreateOutbound(ctx, nil, slog.StdLogger(), out.Type, out.Type, out.Options)
 }
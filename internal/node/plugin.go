@@ -0,0 +1,124 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sagernet/sing-box/transport/sip003"
+)
+
+// shadowsocksPlugins 支持的 SIP003 插件及对应的 sing-box 插件名
+var shadowsocksPlugins = map[string]string{
+	"obfs-local":   "obfs-local",
+	"simple-obfs":  "obfs-local",
+	"v2ray-plugin": "v2ray-plugin",
+}
+
+// pluginName 返回节点插件在 sing-box 中的名称
+func (p Proxy) pluginName() string {
+	return shadowsocksPlugins[p.Plugin]
+}
+
+// pluginOverUDP 插件是否通过 UDP 连接服务端，即 v2ray-plugin 的 quic 模式
+func (p Proxy) pluginOverUDP() bool {
+	if p.pluginName() != "v2ray-plugin" {
+		return false
+	}
+	opts, err := sip003.ParsePluginOptions(p.PluginOpts)
+	if err != nil {
+		return false
+	}
+	mode, _ := opts.Get("mode")
+	return mode == "quic"
+}
+
+// validatePlugin 检查 shadowsocks 插件及其参数
+func (p Proxy) validatePlugin() error {
+	if p.Plugin == "" {
+		return nil
+	}
+	if p.Protocol != ProtocolShadowsocks {
+		return fmt.Errorf("节点 %s (%s) %w: 只有 shadowsocks 支持插件", p.Name, p.Protocol, ErrInvalid)
+	}
+	name, ok := shadowsocksPlugins[p.Plugin]
+	if !ok {
+		return fmt.Errorf("节点 %s (%s) %w: 插件 %s，仅支持 obfs-local 和 v2ray-plugin", p.Name, p.Protocol, ErrUnsupported, p.Plugin)
+	}
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("节点 %s (%s) %w: 插件 %s %s", p.Name, p.Protocol, ErrInvalid, p.Plugin, fmt.Sprintf(format, args...))
+	}
+	opts, err := sip003.ParsePluginOptions(p.PluginOpts)
+	if err != nil {
+		return invalid("参数无效: %v", err)
+	}
+	switch name {
+	case "obfs-local":
+		if mode, ok := opts.Get("obfs"); ok && mode != "http" && mode != "tls" {
+			return invalid("不支持的 obfs 模式 %q", mode)
+		}
+	case "v2ray-plugin":
+		// sing-box 支持 websocket 和 quic 两种模式，quic 需要 with_quic 构建标签并且必须开启 TLS
+		switch mode, _ := opts.Get("mode"); mode {
+		case "", "websocket":
+		case "quic":
+			if _, ok := opts.Get("tls"); !ok {
+				return invalid("quic 模式需要开启 tls")
+			}
+		default:
+			return invalid("不支持的模式 %q", mode)
+		}
+	}
+	return nil
+}
+
+// splitPlugin 拆分 SIP002 链接中 "obfs-local;obfs=http;obfs-host=example.com" 形式的插件参数
+func splitPlugin(plugin string) (string, string) {
+	name, opts, _ := strings.Cut(plugin, ";")
+	return name, opts
+}
+
+// clashPlugin 将 Clash 的 plugin 和 plugin-opts 转换为 SIP003 插件参数
+func clashPlugin(plugin string, opts map[string]any) (string, string) {
+	var args [][2]string
+	switch plugin {
+	case "":
+		return "", ""
+	case "obfs":
+		plugin = "obfs-local"
+		for key, name := range map[string]string{"mode": "obfs", "host": "obfs-host"} {
+			if value, ok := opts[key]; ok {
+				args = append(args, [2]string{name, fmt.Sprint(value)})
+			}
+		}
+	case "v2ray-plugin":
+		for _, key := range []string{"mode", "host", "path"} {
+			if value, ok := opts[key]; ok {
+				args = append(args, [2]string{key, fmt.Sprint(value)})
+			}
+		}
+		// tls 是开关，mux 为 false 时关闭多路复用
+		if tls, _ := opts["tls"].(bool); tls {
+			args = append(args, [2]string{"tls", ""})
+		}
+		if mux, ok := opts["mux"].(bool); ok && !mux {
+			args = append(args, [2]string{"mux", "0"})
+		}
+	}
+	sort.Slice(args, func(i, j int) bool { return args[i][0] < args[j][0] })
+	return plugin, joinPluginOptions(args)
+}
+
+// joinPluginOptions 按 SIP003 格式拼接插件参数，值为空时只写入键
+func joinPluginOptions(args [][2]string) string {
+	escape := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`)
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg[1] == "" {
+			parts = append(parts, escape.Replace(arg[0]))
+		} else {
+			parts = append(parts, escape.Replace(arg[0])+"="+escape.Replace(arg[1]))
+		}
+	}
+	return strings.Join(parts, ";")
+}
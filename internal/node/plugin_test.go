@@ -0,0 +1,109 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+func TestShadowsocksPlugin(t *testing.T) {
+	tests := []struct {
+		plugin, opts, name string
+	}{
+		{"obfs-local", "obfs=http;obfs-host=www.bing.com", "obfs-local"},
+		{"simple-obfs", "obfs=tls", "obfs-local"},
+		{"v2ray-plugin", "mode=websocket;host=cdn.example.com;path=/ws;tls", "v2ray-plugin"},
+	}
+	for _, test := range tests {
+		p := Proxy{Name: test.plugin, Protocol: ProtocolShadowsocks, Host: "127.0.0.1", Port: 8388, Method: "aes-128-gcm", Password: "secret", Plugin: test.plugin, PluginOpts: test.opts}
+		out, err := outboundOptions(p)
+		if err != nil {
+			t.Errorf("%s: %v", test.plugin, err)
+			continue
+		}
+		ss := out.Options.(*option.ShadowsocksOutboundOptions)
+		if ss.Plugin != test.name || ss.PluginOptions != test.opts {
+			t.Errorf("%s: unexpected plugin %s %q", test.plugin, ss.Plugin, ss.PluginOptions)
+		}
+		if _, err = newOutbound(context.Background(), out); err != nil {
+			t.Errorf("%s: create outbound: %v", test.plugin, err)
+		}
+	}
+
+	invalid := []Proxy{
+		{Protocol: ProtocolShadowsocks, Method: "aes-128-gcm", Password: "secret", Plugin: "obfs-local", PluginOpts: "obfs=ws"},
+		{Protocol: ProtocolShadowsocks, Method: "aes-128-gcm", Password: "secret", Plugin: "v2ray-plugin", PluginOpts: "mode=quic"},
+		{Protocol: ProtocolShadowsocks, Method: "aes-128-gcm", Password: "secret", Plugin: "v2ray-plugin", PluginOpts: "mode=grpc;tls"},
+		{Protocol: ProtocolShadowsocks, Method: "aes-128-gcm", Password: "secret", Plugin: "obfs-local", PluginOpts: `obfs=http\`},
+		{Protocol: ProtocolTrojan, Password: "secret", Plugin: "obfs-local"},
+	}
+	for i, p := range invalid {
+		p.Name, p.Host, p.Port = "test", "127.0.0.1", 443
+		if err := p.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%d: expected ErrInvalid, got %v", i, err)
+		}
+	}
+	// quic 模式开启 TLS 时有效
+	quic := Proxy{Name: "quic", Protocol: ProtocolShadowsocks, Host: "127.0.0.1", Port: 8388, Method: "aes-128-gcm", Password: "secret", Plugin: "v2ray-plugin", PluginOpts: "mode=quic;host=cdn.example.com;tls"}
+	if err := quic.Validate(); err != nil {
+		t.Errorf("quic: %v", err)
+	}
+	err := Proxy{Name: "kcp", Protocol: ProtocolShadowsocks, Host: "127.0.0.1", Port: 8388, Method: "aes-128-gcm", Password: "secret", Plugin: "kcptun"}.Validate()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestParsePlugin(t *testing.T) {
+	link := "ss://YWVzLTEyOC1nY206c2VjcmV0@1.2.3.4:8388/?plugin=" + url.QueryEscape("obfs-local;obfs=http;obfs-host=www.bing.com") + "#obfs"
+	p, err := ParseLink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Plugin != "obfs-local" || p.PluginOpts != "obfs=http;obfs-host=www.bing.com" {
+		t.Errorf("unexpected link plugin %q %q", p.Plugin, p.PluginOpts)
+	}
+
+	clash := `
+proxies:
+  - name: obfs
+    type: ss
+    server: 1.2.3.4
+    port: 8388
+    cipher: aes-128-gcm
+    password: secret
+    plugin: obfs
+    plugin-opts:
+      mode: tls
+      host: www.bing.com
+  - name: v2ray
+    type: ss
+    server: 1.2.3.4
+    port: 8389
+    cipher: aes-128-gcm
+    password: secret
+    plugin: v2ray-plugin
+    plugin-opts:
+      mode: websocket
+      host: cdn.example.com
+      path: /a;b
+      tls: true
+      mux: false
+`
+	proxies, err := ParseSubscription([]byte(clash))
+	if err != nil || len(proxies) != 2 {
+		t.Fatalf("unexpected clash result %v %v", proxies, err)
+	}
+	want := [][2]string{
+		{"obfs-local", "obfs=tls;obfs-host=www.bing.com"},
+		{"v2ray-plugin", `host=cdn.example.com;mode=websocket;mux=0;path=/a\;b;tls`},
+	}
+	for i, p := range proxies {
+		if p.Plugin != want[i][0] || p.PluginOpts != want[i][1] {
+			t.Errorf("%s: got %q %q, want %q", p.Name, p.Plugin, p.PluginOpts, want[i])
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package node
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"slices"
+	"sync"
+)
+
+// maxRecent 保留的最近使用节点数
+const maxRecent = 5
+
+// Preferences 本地保存的收藏和最近使用的节点 ID
+type Preferences struct {
+	Favorites []string `json:"favorites"`
+	Recent    []string `json:"recent"` // 最近使用的在前
+}
+
+// Item 节点列表中的一项，带有本地的收藏和使用记录
+type Item struct {
+	Proxy
+	Favorite bool `json:"favorite"`
+	Recent   int  `json:"recent"` // 最近使用的顺序，从 1 开始，0 表示没有使用过
+}
+
+var prefsLock sync.Mutex
+
+func prefsFile() string {
+	return filepath.Join(path.Path(), "preferences.json")
+}
+
+// LoadPreferences 读取收藏和最近使用的节点
+func LoadPreferences() Preferences {
+	prefsLock.Lock()
+	defer prefsLock.Unlock()
+	return loadPreferences()
+}
+
+func loadPreferences() Preferences {
+	prefs := Preferences{}
+	data, err := os.ReadFile(prefsFile())
+	if err != nil {
+		return prefs
+	}
+	_ = json.Unmarshal(data, &prefs)
+	return prefs
+}
+
+func updatePreferences(update func(*Preferences)) error {
+	prefsLock.Lock()
+	defer prefsLock.Unlock()
+	prefs := loadPreferences()
+	update(&prefs)
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prefsFile(), data, 0644)
+}
+
+// SetFavorite 收藏或取消收藏节点
+func SetFavorite(id string, favorite bool) error {
+	return updatePreferences(func(prefs *Preferences) {
+		prefs.Favorites = slices.DeleteFunc(prefs.Favorites, func(f string) bool { return f == id })
+		if favorite {
+			prefs.Favorites = append(prefs.Favorites, id)
+		}
+	})
+}
+
+// AddRecent 记录使用过的节点，只保留最近 maxRecent 个
+func AddRecent(id string) error {
+	return updatePreferences(func(prefs *Preferences) {
+		recent := slices.DeleteFunc(prefs.Recent, func(r string) bool { return r == id })
+		prefs.Recent = append([]string{id}, recent...)
+		if len(prefs.Recent) > maxRecent {
+			prefs.Recent = prefs.Recent[:maxRecent]
+		}
+	})
+}
+
+// Items 为节点附加收藏和最近使用信息
+func (prefs Preferences) Items(proxies []Proxy) []Item {
+	items := make([]Item, len(proxies))
+	for i, p := range proxies {
+		items[i] = Item{
+			Proxy:    p,
+			Favorite: slices.Contains(prefs.Favorites, p.ID),
+			Recent:   slices.Index(prefs.Recent, p.ID) + 1,
+		}
+	}
+	return items
+}
@@ -0,0 +1,24 @@
+package node
+
+import "testing"
+
+func TestPreferences(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	for _, id := range []string{"a", "b", "c", "a", "d", "e", "f"} {
+		if err := AddRecent(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = SetFavorite("b", true)
+	_ = SetFavorite("c", true)
+	_ = SetFavorite("c", false)
+	prefs := LoadPreferences()
+	if len(prefs.Recent) != maxRecent || prefs.Recent[0] != "f" || prefs.Recent[4] != "c" {
+		t.Errorf("unexpected recent %v", prefs.Recent)
+	}
+	items := prefs.Items([]Proxy{{ID: "a"}, {ID: "b"}, {ID: "x"}})
+	if items[0].Recent != 4 || items[0].Favorite || !items[1].Favorite || items[2].Recent != 0 {
+		t.Errorf("unexpected items %+v", items)
+	}
+}
@@ -0,0 +1,440 @@
+package node
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json/badoption"
+)
+
+// 支持的节点协议
+const (
+	ProtocolShadowsocks = "shadowsocks"
+	ProtocolVLESS       = "vless"
+	ProtocolSOCKS       = "socks"
+	ProtocolTrojan      = "trojan"
+	ProtocolVMess       = "vmess"
+	ProtocolHysteria2   = "hysteria2"
+	ProtocolTUIC        = "tuic"
+	ProtocolAnyTLS      = "anytls"
+	ProtocolHTTP        = "http" // 只支持 TCP，主要用作上游代理
+	ProtocolWireGuard   = "wireguard"
+)
+
+// ErrUnsupported 节点协议不受支持
+var ErrUnsupported = errors.New("不支持的节点协议")
+
+// ErrMaintenance 节点正在维护
+var ErrMaintenance = errors.New("节点维护中")
+
+// ErrInvalid 节点配置错误
+var ErrInvalid = errors.New("配置错误")
+
+func errUnsupported(protocol string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, protocol)
+}
+
+// TLS 节点的 TLS 设置，ServerName 为空时使用节点地址
+type TLS struct {
+	ServerName  string   `json:"server_name,omitempty"`
+	Insecure    bool     `json:"insecure,omitempty"`
+	ALPN        []string `json:"alpn,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"` // uTLS 指纹，Reality 默认 chrome
+	Reality     *Reality `json:"reality,omitempty"`
+}
+
+// Reality 节点的 Reality 设置
+type Reality struct {
+	PublicKey string `json:"public_key"`
+	ShortID   string `json:"short_id,omitempty"`
+}
+
+// Transport V2Ray 传输层设置，用于 vless/vmess/trojan
+type Transport struct {
+	Type        string            `json:"type"`                   // ws/grpc/httpupgrade
+	Path        string            `json:"path,omitempty"`         // ws/httpupgrade
+	Host        string            `json:"host,omitempty"`         // ws/httpupgrade 的 Host 头
+	Headers     map[string]string `json:"headers,omitempty"`      // ws/httpupgrade
+	ServiceName string            `json:"service_name,omitempty"` // grpc
+}
+
+// FlowVision vless 支持的流控
+const FlowVision = "xtls-rprx-vision"
+
+// uTLS 支持的指纹
+var fingerprints = map[string]struct{}{
+	"chrome":     {},
+	"firefox":    {},
+	"edge":       {},
+	"safari":     {},
+	"360":        {},
+	"qq":         {},
+	"ios":        {},
+	"android":    {},
+	"random":     {},
+	"randomized": {},
+}
+
+// shadowsocks 传统加密方式
+var shadowsocksMethods = map[string]struct{}{
+	"aes-128-gcm":             {},
+	"aes-192-gcm":             {},
+	"aes-256-gcm":             {},
+	"chacha20-ietf-poly1305":  {},
+	"xchacha20-ietf-poly1305": {},
+	"none":                    {},
+}
+
+// shadowsocks 2022 加密方式及对应的密钥长度
+var shadowsocks2022Methods = map[string]int{
+	"2022-blake3-aes-128-gcm":       16,
+	"2022-blake3-aes-256-gcm":       32,
+	"2022-blake3-chacha20-poly1305": 32,
+}
+
+// Validate 按协议检查节点配置是否完整
+func (p Proxy) Validate() error {
+	switch p.Protocol {
+	case ProtocolShadowsocks, ProtocolVLESS, ProtocolSOCKS, ProtocolTrojan, ProtocolVMess, ProtocolHysteria2, ProtocolTUIC, ProtocolAnyTLS, ProtocolHTTP, ProtocolWireGuard:
+	default:
+		return errUnsupported(p.Protocol)
+	}
+	if p.Host == "" || p.Port == 0 {
+		return fmt.Errorf("节点 %s %w: 缺少地址或端口", p.Name, ErrInvalid)
+	}
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("节点 %s (%s) %w: %s", p.Name, p.Protocol, ErrInvalid, fmt.Sprintf(format, args...))
+	}
+	if err := p.validatePlugin(); err != nil {
+		return err
+	}
+	switch p.Protocol {
+	case ProtocolShadowsocks:
+		if size, ok := shadowsocks2022Methods[p.Method]; ok {
+			// 多用户时为 "服务端密钥:用户密钥"
+			for _, key := range strings.Split(p.Password, ":") {
+				decoded, err := base64.StdEncoding.DecodeString(key)
+				if err != nil || len(decoded) != size {
+					return invalid("%s 需要 %d 字节的 base64 密钥", p.Method, size)
+				}
+			}
+			return nil
+		}
+		if _, ok := shadowsocksMethods[p.Method]; !ok {
+			return invalid("不支持的加密方式 %q", p.Method)
+		}
+		if p.Password == "" && p.Method != "none" {
+			return invalid("缺少密码")
+		}
+	case ProtocolVLESS, ProtocolVMess:
+		if _, err := uuid.FromString(p.uuid()); err != nil {
+			return invalid("无效的 UUID")
+		}
+	case ProtocolTUIC:
+		if _, err := uuid.FromString(p.UUID); err != nil {
+			return invalid("无效的 UUID")
+		}
+		if p.Password == "" {
+			return invalid("缺少密码")
+		}
+	case ProtocolSOCKS, ProtocolHTTP:
+	case ProtocolWireGuard:
+		if err := p.validateWireGuard(invalid); err != nil {
+			return err
+		}
+	case ProtocolTrojan, ProtocolHysteria2, ProtocolAnyTLS:
+		if p.Password == "" {
+			return invalid("缺少密码")
+		}
+	}
+	if p.Flow != "" {
+		if p.Protocol != ProtocolVLESS || p.Flow != FlowVision {
+			return invalid("不支持的流控 %q", p.Flow)
+		}
+		if p.TLS == nil || p.Transport != nil {
+			return invalid("流控 %s 需要 TLS 且不能使用传输层", p.Flow)
+		}
+	}
+	if p.Transport != nil {
+		switch p.Protocol {
+		case ProtocolVLESS, ProtocolVMess, ProtocolTrojan:
+		default:
+			return invalid("不支持传输层")
+		}
+		switch p.Transport.Type {
+		case constant.V2RayTransportTypeWebsocket, constant.V2RayTransportTypeGRPC, constant.V2RayTransportTypeHTTPUpgrade:
+		default:
+			return invalid("不支持的传输层 %q", p.Transport.Type)
+		}
+	}
+	if p.TLS != nil {
+		if _, ok := fingerprints[p.TLS.Fingerprint]; p.TLS.Fingerprint != "" && !ok {
+			return invalid("不支持的 uTLS 指纹 %q", p.TLS.Fingerprint)
+		}
+		if r := p.TLS.Reality; r != nil {
+			if key, err := base64.RawURLEncoding.DecodeString(r.PublicKey); err != nil || len(key) != 32 {
+				return invalid("无效的 Reality 公钥")
+			}
+			if _, err := hex.DecodeString(r.ShortID); err != nil || len(r.ShortID) > 16 {
+				return invalid("无效的 Reality short id")
+			}
+		}
+	}
+	if p.Detour != "" && p.Detour == p.ID {
+		return invalid("不能以自身作为前置节点")
+	}
+	return p.Tuning.validate(p, invalid)
+}
+
+// requiresTLS 协议是否始终使用 TLS
+func (p Proxy) requiresTLS() bool {
+	switch p.Protocol {
+	case ProtocolTrojan, ProtocolHysteria2, ProtocolTUIC, ProtocolAnyTLS:
+		return true
+	}
+	return false
+}
+
+// overUDP 节点是否通过 UDP 连接服务端，前置出站需要支持 UDP
+func (p Proxy) overUDP() bool {
+	switch p.Protocol {
+	case ProtocolHysteria2, ProtocolTUIC, ProtocolWireGuard:
+		return true
+	}
+	return p.pluginOverUDP()
+}
+
+// socksUsername 有密码但没有设置用户名时使用默认的 playfast
+// 没有密码时不发送用户名，客户端只协商无认证方式，可以连接匿名的 socks 服务
+func (p Proxy) socksUsername() string {
+	if p.Username == "" && p.Password != "" {
+		return "playfast"
+	}
+	return p.Username
+}
+
+func (p Proxy) uuid() string {
+	if p.UUID != "" {
+		return p.UUID
+	}
+	return p.Password
+}
+
+// tlsOptions 生成出站 TLS 配置，required 为 true 时即使节点未配置也启用 TLS
+func (p Proxy) tlsOptions(required bool) option.OutboundTLSOptionsContainer {
+	if p.TLS == nil && !required {
+		return option.OutboundTLSOptionsContainer{}
+	}
+	t := TLS{}
+	if p.TLS != nil {
+		t = *p.TLS
+	}
+	if t.ServerName == "" {
+		t.ServerName = p.Host
+	}
+	options := &option.OutboundTLSOptions{
+		Enabled:    true,
+		ServerName: t.ServerName,
+		Insecure:   t.Insecure,
+		ALPN:       t.ALPN,
+	}
+	fingerprint := t.Fingerprint
+	if t.Reality != nil {
+		options.Reality = &option.OutboundRealityOptions{
+			Enabled:   true,
+			PublicKey: t.Reality.PublicKey,
+			ShortID:   t.Reality.ShortID,
+		}
+		// Reality 依赖 uTLS
+		if fingerprint == "" {
+			fingerprint = "chrome"
+		}
+	}
+	if fingerprint != "" {
+		options.UTLS = &option.OutboundUTLSOptions{
+			Enabled:     true,
+			Fingerprint: fingerprint,
+		}
+	}
+	return option.OutboundTLSOptionsContainer{TLS: options}
+}
+
+// transportOptions 生成 V2Ray 传输层配置，未配置时返回 nil
+func (p Proxy) transportOptions() *option.V2RayTransportOptions {
+	t := p.Transport
+	if t == nil {
+		return nil
+	}
+	headers := make(badoption.HTTPHeader)
+	for key, value := range t.Headers {
+		headers[key] = badoption.Listable[string]{value}
+	}
+	options := &option.V2RayTransportOptions{Type: t.Type}
+	switch t.Type {
+	case constant.V2RayTransportTypeWebsocket:
+		if t.Host != "" {
+			headers["Host"] = badoption.Listable[string]{t.Host}
+		}
+		options.WebsocketOptions = option.V2RayWebsocketOptions{
+			Path:    t.Path,
+			Headers: headers,
+		}
+	case constant.V2RayTransportTypeGRPC:
+		options.GRPCOptions = option.V2RayGRPCOptions{
+			ServiceName: t.ServiceName,
+		}
+	case constant.V2RayTransportTypeHTTPUpgrade:
+		options.HTTPUpgradeOptions = option.V2RayHTTPUpgradeOptions{
+			Host:    t.Host,
+			Path:    t.Path,
+			Headers: headers,
+		}
+	}
+	return options
+}
+
+// outboundOptions 校验节点并转换为 sing-box 出站配置
+// wireguard 的 Options 为端点配置，生成链路时放入 Endpoints
+func outboundOptions(p Proxy) (option.Outbound, error) {
+	if err := p.Validate(); err != nil {
+		return option.Outbound{}, err
+	}
+	server := option.ServerOptions{
+		Server:     p.Host,
+		ServerPort: p.Port,
+	}
+	out := option.Outbound{Tag: "proxy"}
+	dialer := p.Tuning.dialerOptions()
+	dialer.Detour = p.detourTag
+	// QUIC 和 wireguard 不使用 TFO 和 MPTCP，只需要前置出站
+	quicDialer := option.DialerOptions{Detour: p.detourTag}
+	switch p.Protocol {
+	case ProtocolShadowsocks:
+		out.Type = constant.TypeShadowsocks
+		out.Options = &option.ShadowsocksOutboundOptions{
+			DialerOptions: dialer,
+			ServerOptions: server,
+			Method:        p.Method,
+			Password:      p.Password,
+			Plugin:        p.pluginName(),
+			PluginOptions: p.PluginOpts,
+			UDPOverTCP:    p.Tuning.uot(),
+			Multiplex:     p.Tuning.multiplex(nil),
+		}
+	case ProtocolVLESS:
+		options := &option.VLESSOutboundOptions{
+			DialerOptions:               dialer,
+			ServerOptions:               server,
+			UUID:                        p.uuid(),
+			Flow:                        p.Flow,
+			OutboundTLSOptionsContainer: p.tlsOptions(false),
+			Transport:                   p.transportOptions(),
+		}
+		// vision 流控自行处理 UDP，不能与多路复用同时使用
+		if p.Flow == "" {
+			options.Multiplex = p.Tuning.multiplex(&defaultMultiplex)
+		} else {
+			options.PacketEncoding = common.Ptr("xudp")
+		}
+		out.Type = constant.TypeVLESS
+		out.Options = options
+	case ProtocolSOCKS:
+		out.Type = constant.TypeSOCKS
+		out.Options = &option.SOCKSOutboundOptions{
+			DialerOptions: dialer,
+			ServerOptions: server,
+			Version:       "5",
+			Username:      p.socksUsername(),
+			Password:      p.Password,
+			UDPOverTCP:    p.Tuning.uot(),
+		}
+	case ProtocolHTTP:
+		out.Type = constant.TypeHTTP
+		out.Options = &option.HTTPOutboundOptions{
+			DialerOptions:               dialer,
+			ServerOptions:               server,
+			Username:                    p.Username,
+			Password:                    p.Password,
+			OutboundTLSOptionsContainer: p.tlsOptions(false),
+		}
+	case ProtocolTrojan:
+		out.Type = constant.TypeTrojan
+		out.Options = &option.TrojanOutboundOptions{
+			DialerOptions:               dialer,
+			ServerOptions:               server,
+			Password:                    p.Password,
+			OutboundTLSOptionsContainer: p.tlsOptions(true),
+			Transport:                   p.transportOptions(),
+			Multiplex:                   p.Tuning.multiplex(nil),
+		}
+	case ProtocolVMess:
+		security := p.Security
+		if security == "" {
+			security = "auto"
+		}
+		out.Type = constant.TypeVMess
+		out.Options = &option.VMessOutboundOptions{
+			DialerOptions:               dialer,
+			ServerOptions:               server,
+			UUID:                        p.uuid(),
+			Security:                    security,
+			AlterId:                     p.AlterID,
+			PacketEncoding:              "xudp",
+			OutboundTLSOptionsContainer: p.tlsOptions(false),
+			Transport:                   p.transportOptions(),
+			Multiplex:                   p.Tuning.multiplex(nil),
+		}
+	case ProtocolHysteria2:
+		options := &option.Hysteria2OutboundOptions{
+			DialerOptions:               quicDialer,
+			ServerOptions:               server,
+			UpMbps:                      p.UpMbps,
+			DownMbps:                    p.DownMbps,
+			Password:                    p.Password,
+			OutboundTLSOptionsContainer: p.tlsOptions(true),
+		}
+		if p.Obfs != "" {
+			options.Obfs = &option.Hysteria2Obfs{Type: "salamander", Password: p.Obfs}
+		}
+		out.Type = constant.TypeHysteria2
+		out.Options = options
+	case ProtocolTUIC:
+		congestion := p.CongestionControl
+		if congestion == "" {
+			congestion = "bbr"
+		}
+		tls := p.tlsOptions(true)
+		if len(tls.TLS.ALPN) == 0 {
+			tls.TLS.ALPN = []string{"h3"}
+		}
+		out.Type = constant.TypeTUIC
+		out.Options = &option.TUICOutboundOptions{
+			DialerOptions:               quicDialer,
+			ServerOptions:               server,
+			UUID:                        p.UUID,
+			Password:                    p.Password,
+			CongestionControl:           congestion,
+			UDPRelayMode:                "native",
+			OutboundTLSOptionsContainer: tls,
+		}
+	case ProtocolWireGuard:
+		out.Type = constant.TypeWireGuard
+		out.Options = p.wireGuardOptions(quicDialer)
+	case ProtocolAnyTLS:
+		out.Type = constant.TypeAnyTLS
+		out.Options = &option.AnyTLSOutboundOptions{
+			DialerOptions:               dialer,
+			ServerOptions:               server,
+			Password:                    p.Password,
+			OutboundTLSOptionsContainer: p.tlsOptions(true),
+		}
+	}
+	return out, nil
+}
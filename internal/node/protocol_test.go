@@ -0,0 +1,165 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+const testUUID = "b831381d-6324-4d53-ad4f-8cda48b30811"
+
+func TestProtocols(t *testing.T) {
+	proxies := map[string]Proxy{
+		constant.TypeShadowsocks: {Protocol: ProtocolShadowsocks, Method: "aes-128-gcm", Password: "secret"},
+		constant.TypeVLESS:       {Protocol: ProtocolVLESS, Password: testUUID},
+		constant.TypeSOCKS:       {Protocol: ProtocolSOCKS, Password: "secret"},
+		constant.TypeTrojan:      {Protocol: ProtocolTrojan, Password: "secret"},
+		constant.TypeVMess:       {Protocol: ProtocolVMess, UUID: testUUID},
+		constant.TypeHysteria2:   {Protocol: ProtocolHysteria2, Password: "secret", Obfs: "obfs"},
+		constant.TypeTUIC:        {Protocol: ProtocolTUIC, UUID: testUUID, Password: "secret"},
+		constant.TypeAnyTLS:      {Protocol: ProtocolAnyTLS, Password: "secret"},
+		"shadowsocks-2022":       {Protocol: ProtocolShadowsocks, Method: "2022-blake3-aes-128-gcm", Password: "AAAAAAAAAAAAAAAAAAAAAA=="},
+	}
+	for name, p := range proxies {
+		p.Name, p.Host, p.Port = name, "127.0.0.1", 443
+		out, err := outboundOptions(p)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if out.Tag != "proxy" || (out.Type != name && out.Type != p.Protocol) {
+			t.Errorf("%s: unexpected outbound %s/%s", name, out.Type, out.Tag)
+		}
+		// QUIC 协议需要 with_quic 编译标签，测试默认不启用
+		if out.Type == constant.TypeHysteria2 || out.Type == constant.TypeTUIC {
+			continue
+		}
+		if _, err = newOutbound(context.Background(), out); err != nil {
+			t.Errorf("%s: create outbound: %v", name, err)
+		}
+	}
+}
+
+func TestSOCKSUsername(t *testing.T) {
+	cases := []struct {
+		username, password, want string
+	}{
+		{"", "secret", "playfast"},
+		{"qa", "secret", "qa"},
+		{"", "", ""},
+		{"qa", "", "qa"},
+	}
+	for _, c := range cases {
+		out, err := outboundOptions(Proxy{Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: 1080, Username: c.username, Password: c.password})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.Options.(*option.SOCKSOutboundOptions).Username; got != c.want {
+			t.Errorf("username %q password %q: got %q, want %q", c.username, c.password, got, c.want)
+		}
+	}
+}
+
+func TestProtocolTLSDefaults(t *testing.T) {
+	out, err := outboundOptions(Proxy{Protocol: ProtocolTUIC, Host: "example.com", Port: 443, UUID: testUUID, Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls := out.Options.(*option.TUICOutboundOptions).TLS
+	if tls == nil || !tls.Enabled || tls.ServerName != "example.com" || len(tls.ALPN) != 1 || tls.ALPN[0] != "h3" {
+		t.Errorf("unexpected tuic tls %+v", tls)
+	}
+	out, err = outboundOptions(Proxy{Protocol: ProtocolTrojan, Host: "1.2.3.4", Port: 443, Password: "secret", TLS: &TLS{ServerName: "example.com", Insecure: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tls = out.Options.(*option.TrojanOutboundOptions).TLS
+	if tls == nil || tls.ServerName != "example.com" || !tls.Insecure {
+		t.Errorf("unexpected trojan tls %+v", tls)
+	}
+}
+
+func TestProtocolValidation(t *testing.T) {
+	invalid := []Proxy{
+		{Protocol: ProtocolShadowsocks, Method: "rc4-md5", Password: "secret"},
+		{Protocol: ProtocolShadowsocks, Method: "aes-128-gcm"},
+		{Protocol: ProtocolShadowsocks, Method: "2022-blake3-aes-256-gcm", Password: "AAAAAAAAAAAAAAAAAAAAAA=="},
+		{Protocol: ProtocolShadowsocks, Method: "2022-blake3-aes-128-gcm", Password: "not base64"},
+		{Protocol: ProtocolVLESS, Password: "not-a-uuid"},
+		{Protocol: ProtocolVMess},
+		{Protocol: ProtocolTUIC, UUID: testUUID},
+		{Protocol: ProtocolTrojan},
+		{Protocol: ProtocolHysteria2},
+		{Protocol: ProtocolAnyTLS},
+		{Protocol: ProtocolTrojan, Password: "secret", Port: 0},
+	}
+	for i, p := range invalid {
+		p.Name, p.Host = "test", "example.com"
+		if p.Port == 0 && i != len(invalid)-1 {
+			p.Port = 443
+		}
+		if err := p.Validate(); err == nil {
+			t.Errorf("%d: expected error for %+v", i, p)
+		}
+	}
+	err := Proxy{Protocol: "ssh", Host: "example.com", Port: 22}.Validate()
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestDialOutboundDomainWithoutTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	first := make(chan byte, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		buf := make([]byte, 1)
+		if _, err := conn.Read(buf); err == nil {
+			first <- buf[0]
+		}
+		// 测试结束前保持连接，避免客户端写入时连接已关闭
+		<-done
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// 域名节点解析为 IP 后，没有配置 TLS 的 VLESS 节点仍然使用明文连接
+	// 关闭多路复用，直接检查 VLESS 连接的第一个字节
+	p := Proxy{Name: "vless", Protocol: ProtocolVLESS, Host: "localhost", Port: uint16(listener.Addr().(*net.TCPAddr).Port), Password: testUUID, Tuning: &Tuning{Multiplex: &Multiplex{}}}
+	outbound, err := dialOutbound(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := outbound.DialContext(ctx, "tcp", M.ParseSocksaddr("example.com:80"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case b := <-first:
+		// VLESS 请求以版本号 0 开头，TLS 握手以 0x16 开头
+		if b != 0 {
+			t.Errorf("unexpected first byte %#x", b)
+		}
+	case <-ctx.Done():
+		t.Fatal("server received nothing")
+	}
+}
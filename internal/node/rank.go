@@ -0,0 +1,245 @@
+package node
+
+import (
+	"context"
+	"playfast/internal/echo"
+	"playfast/internal/probe"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing/common"
+)
+
+// Quality 单个节点的探测结果，延迟和抖动单位为毫秒，丢包为百分比
+type Quality struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Region    string  `json:"region"`
+	Latency   int64   `json:"latency"`
+	Jitter    int64   `json:"jitter"`
+	Loss      float64 `json:"loss"`
+	Score     float64 `json:"score"` // 越小越好，全部失败时为 0
+	Error     string  `json:"error"`
+	ErrorKind string  `json:"errorKind"`
+}
+
+// Ranking 按质量排序的节点列表及测量时间
+type Ranking struct {
+	Nodes     []Quality `json:"nodes"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Ranker 并发探测所有节点并缓存结果
+type Ranker struct {
+	mu        sync.Mutex
+	refreshMu sync.Mutex  // 同一时间只进行一轮探测
+	pending   atomic.Bool // 后台刷新是否已在进行
+	ranking   Ranking
+	workers   int
+	samples   int
+	timeout   time.Duration
+	onUpdate  func(Ranking)
+	network   func(context.Context) context.Context
+}
+
+// RankerOption 是Ranker的选项函数类型
+type RankerOption func(*Ranker)
+
+// NewRanker 创建节点排序器
+func NewRanker(options ...RankerOption) *Ranker {
+	r := &Ranker{
+		workers: 8,
+		samples: 5,
+		timeout: 3 * time.Second,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// WithWorkers 设置同时探测的节点数
+func WithWorkers(workers int) RankerOption {
+	return func(r *Ranker) {
+		r.workers = max(workers, 1)
+	}
+}
+
+// WithSamples 设置每个节点的探测次数
+func WithSamples(samples int) RankerOption {
+	return func(r *Ranker) {
+		r.samples = max(samples, 1)
+	}
+}
+
+// WithProbeTimeout 设置单次探测的超时时间
+func WithProbeTimeout(timeout time.Duration) RankerOption {
+	return func(r *Ranker) {
+		r.timeout = timeout
+	}
+}
+
+// WithUpdate 设置每次刷新完成后的回调
+func WithUpdate(onUpdate func(Ranking)) RankerOption {
+	return func(r *Ranker) {
+		r.onUpdate = onUpdate
+	}
+}
+
+// WithNetwork 设置每轮探测前对 ctx 的调整，加速中可带上加速器的 NetworkManager 使探测绕过 tun
+func WithNetwork(network func(context.Context) context.Context) RankerOption {
+	return func(r *Ranker) {
+		r.network = network
+	}
+}
+
+// Cached 返回缓存的结果，从未刷新时 UpdatedAt 为零值
+func (r *Ranker) Cached() Ranking {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ranking
+}
+
+// Get 返回不超过 maxAge 的缓存结果，缓存过期时重新探测
+func (r *Ranker) Get(ctx context.Context, maxAge time.Duration) Ranking {
+	if ranking := r.Cached(); !ranking.UpdatedAt.IsZero() && time.Since(ranking.UpdatedAt) < maxAge {
+		return ranking
+	}
+	return r.Refresh(ctx, Get(ctx))
+}
+
+// Latest 立即返回缓存的结果，超过 maxAge 时在后台刷新，完成后通过 WithUpdate 的回调通知
+func (r *Ranker) Latest(ctx context.Context, maxAge time.Duration) Ranking {
+	ranking := r.Cached()
+	if !ranking.UpdatedAt.IsZero() && time.Since(ranking.UpdatedAt) < maxAge {
+		return ranking
+	}
+	if r.pending.CompareAndSwap(false, true) {
+		go func() {
+			defer r.pending.Store(false)
+			r.Refresh(ctx, Get(ctx))
+		}()
+	}
+	return ranking
+}
+
+// Ready 与 Latest 相同，但从未探测过时等待第一次探测完成，供必须有结果才能继续的调用方使用
+func (r *Ranker) Ready(ctx context.Context, maxAge time.Duration) Ranking {
+	if ranking := r.Latest(ctx, maxAge); !ranking.UpdatedAt.IsZero() {
+		return ranking
+	}
+	return r.Refresh(ctx, Get(ctx))
+}
+
+// Refresh 重新探测 proxies 中的所有节点并更新缓存
+// 已有一轮探测在进行时等待其完成并直接返回它的结果
+func (r *Ranker) Refresh(ctx context.Context, proxies []Proxy) Ranking {
+	start := time.Now()
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+	if ranking := r.Cached(); ranking.UpdatedAt.After(start) {
+		return ranking
+	}
+	if r.network != nil {
+		ctx = r.network(ctx)
+	}
+	nodes := make([]Quality, len(proxies))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(r.workers, len(proxies)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				nodes[i] = r.measure(ctx, proxies[i])
+			}
+		}()
+	}
+	for i := range proxies {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if (nodes[i].Error == "") != (nodes[j].Error == "") {
+			return nodes[i].Error == ""
+		}
+		return nodes[i].Score < nodes[j].Score
+	})
+	ranking := Ranking{Nodes: nodes, UpdatedAt: time.Now()}
+	r.mu.Lock()
+	r.ranking = ranking
+	r.mu.Unlock()
+	if r.onUpdate != nil {
+		r.onUpdate(ranking)
+	}
+	return ranking
+}
+
+// Run 每隔 interval 刷新一次，直到 ctx 结束
+func (r *Ranker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Refresh(ctx, Get(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// measure 对单个节点连续探测多次，统计延迟、抖动和丢包
+func (r *Ranker) measure(ctx context.Context, p Proxy) Quality {
+	quality := Quality{ID: p.ID, Name: p.Name, Region: p.Region}
+	fail := func(err error) Quality {
+		quality.Loss = 100
+		quality.Error = err.Error()
+		quality.ErrorKind = errorKind(err)
+		return quality
+	}
+	outbound, err := dialOutbound(ctx, p)
+	if err != nil {
+		return fail(err)
+	}
+	defer func() { _ = common.Close(outbound) }()
+	pr, err := probe.LoadTargets().Resolve(p.Probe).New()
+	if err != nil {
+		return fail(err)
+	}
+	latencies := make([]time.Duration, 0, r.samples)
+	var lastErr error
+	for i := 0; i < r.samples; i++ {
+		probeCtx, cancel := context.WithTimeout(ctx, r.timeout)
+		latency, err := pr.Probe(probeCtx, outbound.DialContext)
+		cancel()
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		latencies = append(latencies, latency)
+	}
+	if len(latencies) == 0 {
+		return fail(lastErr)
+	}
+	report := echo.NewReport(latencies, r.samples-len(latencies))
+	quality.Latency = report.Median.Milliseconds()
+	quality.Jitter = report.Jitter.Milliseconds()
+	quality.Loss = report.LossRate
+	quality.Score = score(report)
+	return quality
+}
+
+// score 综合延迟、抖动和丢包，每 1% 丢包按 10ms 计
+func score(report echo.StatsReport) float64 {
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	return ms(report.Median) + 2*ms(report.Jitter) + 10*report.LossRate
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	"context"
+	"net"
+	"playfast/internal/probe"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+)
+
+func closedPort(t *testing.T) uint16 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	_ = listener.Close()
+	return uint16(port)
+}
+
+func TestRankerErrors(t *testing.T) {
+	target := &probe.Config{Type: probe.TypeTCP, Target: "127.0.0.1:9"}
+	proxies := []Proxy{
+		{Name: "unsupported", Protocol: "unknown"},
+		{Name: "refused", Protocol: "socks", Host: "127.0.0.1", Port: closedPort(t), Probe: target},
+		{Name: "maintenance", Protocol: "socks", Host: "127.0.0.1", Port: 1080, Maintenance: true},
+	}
+	var updates int
+	ranker := NewRanker(WithWorkers(2), WithSamples(2), WithProbeTimeout(time.Second), WithUpdate(func(Ranking) {
+		updates++
+	}))
+	ranking := ranker.Refresh(context.Background(), proxies)
+	if len(ranking.Nodes) != 3 || ranking.UpdatedAt.IsZero() || updates != 1 {
+		t.Fatalf("unexpected ranking %+v", ranking)
+	}
+	kinds := map[string]string{}
+	for _, q := range ranking.Nodes {
+		if q.Loss != 100 || q.Error == "" {
+			t.Errorf("%s: expected failure, got %+v", q.Name, q)
+		}
+		kinds[q.Name] = q.ErrorKind
+	}
+	if kinds["unsupported"] != ErrorKindUnsupported || kinds["refused"] != ErrorKindRefused || kinds["maintenance"] != ErrorKindMaintenance {
+		t.Errorf("unexpected error kinds %v", kinds)
+	}
+	if cached := ranker.Cached(); !cached.UpdatedAt.Equal(ranking.UpdatedAt) {
+		t.Errorf("cache not updated")
+	}
+	if got := ranker.Get(context.Background(), time.Hour); !got.UpdatedAt.Equal(ranking.UpdatedAt) {
+		t.Errorf("fresh cache was refreshed")
+	}
+	if got := ranker.Latest(context.Background(), time.Hour); !got.UpdatedAt.Equal(ranking.UpdatedAt) || updates != 1 {
+		t.Errorf("fresh cache was refreshed")
+	}
+}
+
+type networkKey struct{}
+
+func TestRankerNetwork(t *testing.T) {
+	// 加速中每轮探测都要带上加速器的网络，创建出站时才会绕过 tun
+	target := &probe.Config{Type: probe.TypeTCP, Target: "127.0.0.1:9"}
+	var seen []any
+	ranker := NewRanker(WithSamples(1), WithNetwork(func(ctx context.Context) context.Context {
+		seen = append(seen, ctx.Value(networkKey{}))
+		return context.WithValue(ctx, networkKey{}, "box")
+	}))
+	// 创建出站时收到的必须是带有加速器网络的 ctx
+	var mu sync.Mutex
+	var created []any
+	newOutbound = func(ctx context.Context, out option.Outbound) (adapter.Outbound, error) {
+		mu.Lock()
+		created = append(created, ctx.Value(networkKey{}))
+		mu.Unlock()
+		return createOutbound(ctx, out)
+	}
+	t.Cleanup(func() { newOutbound = createOutbound })
+	ctx := context.WithValue(context.Background(), networkKey{}, "app")
+	proxies := []Proxy{{Name: "refused", Protocol: "socks", Host: "127.0.0.1", Port: closedPort(t), Probe: target}}
+	ranker.Refresh(ctx, proxies)
+	ranker.Refresh(ctx, proxies)
+	if len(seen) != 2 || seen[0] != "app" || seen[1] != "app" {
+		t.Errorf("unexpected network calls %v", seen)
+	}
+	if len(created) != 2 || created[0] != "box" || created[1] != "box" {
+		t.Errorf("outbounds created without the box network: %v", created)
+	}
+}
@@ -0,0 +1,235 @@
+package node
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseLink 解析 ss/vless/trojan/vmess/hysteria2/tuic 分享链接
+func ParseLink(link string) (Proxy, error) {
+	link = strings.TrimSpace(link)
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok {
+		return Proxy{}, fmt.Errorf("无效的分享链接: %s", link)
+	}
+	var p Proxy
+	var err error
+	switch strings.ToLower(scheme) {
+	case "ss":
+		p, err = parseShadowsocks(link)
+	case "vmess":
+		p, err = parseVMess(link)
+	case "vless", "trojan", "hysteria2", "hy2", "tuic":
+		p, err = parseURL(link)
+	default:
+		return Proxy{}, errUnsupported(scheme)
+	}
+	if err != nil {
+		return Proxy{}, fmt.Errorf("解析 %s 链接失败: %w", scheme, err)
+	}
+	if p.Name == "" {
+		p.Name = net.JoinHostPort(p.Host, strconv.Itoa(int(p.Port)))
+	}
+	return p, p.Validate()
+}
+
+// decodeBase64 兼容标准和 URL 编码，有无填充均可
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func splitHostPort(hostport string) (string, uint16, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("无效的端口 %s", port)
+	}
+	return host, uint16(n), nil
+}
+
+// parseShadowsocks 支持 SIP002 和旧版整体 base64 两种格式
+func parseShadowsocks(link string) (Proxy, error) {
+	u, err := url.Parse(link)
+	if err != nil || u.Host == "" || u.Port() == "" {
+		// 旧格式 ss://base64(method:password@host:port)#name
+		body, fragment, _ := strings.Cut(strings.TrimPrefix(link, "ss://"), "#")
+		decoded, err := decodeBase64(body)
+		if err != nil {
+			return Proxy{}, err
+		}
+		name, _ := url.PathUnescape(fragment)
+		u, err = url.Parse("ss://" + string(decoded) + "#" + url.PathEscape(name))
+		if err != nil {
+			return Proxy{}, err
+		}
+	}
+	p := Proxy{Protocol: ProtocolShadowsocks, Name: u.Fragment}
+	p.Host, p.Port, err = splitHostPort(u.Host)
+	if err != nil {
+		return Proxy{}, err
+	}
+	if password, ok := u.User.Password(); ok {
+		p.Method, p.Password = u.User.Username(), password
+	} else {
+		// SIP002 中 userinfo 为 base64(method:password)
+		decoded, err := decodeBase64(u.User.Username())
+		if err != nil {
+			return Proxy{}, err
+		}
+		p.Method, p.Password, _ = strings.Cut(string(decoded), ":")
+	}
+	if plugin := u.Query().Get("plugin"); plugin != "" {
+		p.Plugin, p.PluginOpts = splitPlugin(plugin)
+	}
+	return p, nil
+}
+
+// parseURL 解析 vless/trojan/hysteria2/tuic 这类标准 URL 格式的链接
+func parseURL(link string) (Proxy, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return Proxy{}, err
+	}
+	q := u.Query()
+	p := Proxy{Name: u.Fragment}
+	p.Host, p.Port, err = splitHostPort(u.Host)
+	if err != nil {
+		return Proxy{}, err
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "vless":
+		p.Protocol, p.UUID, p.Flow = ProtocolVLESS, u.User.Username(), q.Get("flow")
+	case "trojan":
+		p.Protocol, p.Password = ProtocolTrojan, u.User.Username()
+	case "hysteria2", "hy2":
+		p.Protocol, p.Password = ProtocolHysteria2, u.User.String()
+		if q.Get("obfs") == "salamander" {
+			p.Obfs = q.Get("obfs-password")
+		}
+		p.UpMbps, _ = strconv.Atoi(q.Get("upmbps"))
+		p.DownMbps, _ = strconv.Atoi(q.Get("downmbps"))
+		if p.Password, err = url.PathUnescape(p.Password); err != nil {
+			return Proxy{}, err
+		}
+	case "tuic":
+		p.Protocol, p.UUID = ProtocolTUIC, u.User.Username()
+		p.Password, _ = u.User.Password()
+		p.CongestionControl = q.Get("congestion_control")
+	}
+	p.TLS = linkTLS(q, p.Protocol)
+	p.Transport = linkTransport(q.Get("type"), q.Get("path"), q.Get("host"), q.Get("serviceName"))
+	return p, nil
+}
+
+// linkTLS 读取链接中的 TLS 参数，vless 只有 security 为 tls/reality 时启用
+func linkTLS(q url.Values, protocol string) *TLS {
+	security := q.Get("security")
+	if protocol == ProtocolVLESS && security != "tls" && security != "reality" {
+		return nil
+	}
+	if security == "none" {
+		return nil
+	}
+	t := &TLS{
+		ServerName:  firstNonEmpty(q.Get("sni"), q.Get("peer")),
+		Insecure:    isTrue(q.Get("allowInsecure")) || isTrue(q.Get("insecure")) || isTrue(q.Get("allow_insecure")),
+		Fingerprint: q.Get("fp"),
+	}
+	if alpn := q.Get("alpn"); alpn != "" {
+		t.ALPN = strings.Split(alpn, ",")
+	}
+	if security == "reality" {
+		t.Reality = &Reality{PublicKey: q.Get("pbk"), ShortID: q.Get("sid")}
+	}
+	return t
+}
+
+// linkTransport 转换链接中的传输层参数，tcp 和空值表示不使用传输层
+func linkTransport(network, path, host, serviceName string) *Transport {
+	switch network {
+	case "ws", "httpupgrade":
+		return &Transport{Type: network, Path: path, Host: host}
+	case "grpc":
+		return &Transport{Type: network, ServiceName: serviceName}
+	}
+	return nil
+}
+
+// vmessLink v2rayN 格式 vmess 链接中的 JSON
+type vmessLink struct {
+	Name        string          `json:"ps"`
+	Address     string          `json:"add"`
+	Port        json.RawMessage `json:"port"`
+	ID          string          `json:"id"`
+	AlterID     json.RawMessage `json:"aid"`
+	Security    string          `json:"scy"`
+	Network     string          `json:"net"`
+	Host        string          `json:"host"`
+	Path        string          `json:"path"`
+	TLS         string          `json:"tls"`
+	SNI         string          `json:"sni"`
+	ALPN        string          `json:"alpn"`
+	Fingerprint string          `json:"fp"`
+}
+
+func parseVMess(link string) (Proxy, error) {
+	decoded, err := decodeBase64(strings.TrimPrefix(link, "vmess://"))
+	if err != nil {
+		return Proxy{}, err
+	}
+	var v vmessLink
+	if err = json.Unmarshal(decoded, &v); err != nil {
+		return Proxy{}, err
+	}
+	// port 和 aid 可能是数字也可能是字符串
+	port, err := strconv.ParseUint(strings.Trim(string(v.Port), `"`), 10, 16)
+	if err != nil {
+		return Proxy{}, errors.New("无效的端口")
+	}
+	aid, _ := strconv.Atoi(strings.Trim(string(v.AlterID), `"`))
+	p := Proxy{
+		Name:     v.Name,
+		Protocol: ProtocolVMess,
+		Host:     v.Address,
+		Port:     uint16(port),
+		UUID:     v.ID,
+		AlterID:  aid,
+		Security: v.Security,
+	}
+	if v.TLS == "tls" {
+		p.TLS = &TLS{ServerName: v.SNI, Fingerprint: v.Fingerprint}
+		if v.ALPN != "" {
+			p.TLS.ALPN = strings.Split(v.ALPN, ",")
+		}
+	}
+	// grpc 的服务名放在 path 中
+	p.Transport = linkTransport(v.Network, v.Path, v.Host, v.Path)
+	return p, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func isTrue(s string) bool {
+	return s == "1" || strings.EqualFold(s, "true")
+}
@@ -0,0 +1,225 @@
+package node
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseLink(t *testing.T) {
+	vmess := base64.StdEncoding.EncodeToString([]byte(`{"v":"2","ps":"vm","add":"1.2.3.4","port":"443","id":"` + testUUID + `","aid":0,"net":"ws","path":"/ws","host":"cdn.example.com","tls":"tls","sni":"example.com"}`))
+	userinfo := base64.RawURLEncoding.EncodeToString([]byte("aes-128-gcm:secret"))
+	tests := []struct {
+		link  string
+		check func(Proxy) bool
+	}{
+		{"ss://" + userinfo + "@1.2.3.4:8388#%E9%A6%99%E6%B8%AF", func(p Proxy) bool {
+			return p.Protocol == ProtocolShadowsocks && p.Method == "aes-128-gcm" && p.Password == "secret" && p.Name == "香港" && p.Port == 8388
+		}},
+		{"ss://" + base64.StdEncoding.EncodeToString([]byte("aes-128-gcm:secret@1.2.3.4:8388")) + "#old", func(p Proxy) bool {
+			return p.Method == "aes-128-gcm" && p.Password == "secret" && p.Host == "1.2.3.4" && p.Name == "old"
+		}},
+		{"vless://" + testUUID + "@1.2.3.4:443?security=reality&sni=www.example.com&pbk=" + testPublicKey + "&sid=ab&flow=xtls-rprx-vision&fp=chrome#r", func(p Proxy) bool {
+			return p.Protocol == ProtocolVLESS && p.UUID == testUUID && p.Flow == FlowVision && p.TLS != nil &&
+				p.TLS.Reality != nil && p.TLS.Reality.PublicKey == testPublicKey && p.TLS.ServerName == "www.example.com"
+		}},
+		{"vless://" + testUUID + "@1.2.3.4:80?type=grpc&serviceName=svc", func(p Proxy) bool {
+			return p.TLS == nil && p.Transport != nil && p.Transport.Type == "grpc" && p.Transport.ServiceName == "svc" && p.Name == "1.2.3.4:80"
+		}},
+		{"trojan://pass@1.2.3.4:443?sni=example.com&allowInsecure=1#t", func(p Proxy) bool {
+			return p.Protocol == ProtocolTrojan && p.Password == "pass" && p.TLS != nil && p.TLS.Insecure && p.TLS.ServerName == "example.com"
+		}},
+		{"vmess://" + vmess, func(p Proxy) bool {
+			return p.Protocol == ProtocolVMess && p.Port == 443 && p.TLS != nil && p.Transport != nil && p.Transport.Path == "/ws" && p.Transport.Host == "cdn.example.com"
+		}},
+		{"hy2://pa%40ss@1.2.3.4:443?obfs=salamander&obfs-password=o&sni=example.com#h", func(p Proxy) bool {
+			return p.Protocol == ProtocolHysteria2 && p.Password == "pa@ss" && p.Obfs == "o" && p.TLS != nil
+		}},
+		{"tuic://" + testUUID + ":pass@1.2.3.4:443?congestion_control=cubic&alpn=h3#u", func(p Proxy) bool {
+			return p.Protocol == ProtocolTUIC && p.UUID == testUUID && p.Password == "pass" && p.CongestionControl == "cubic" && len(p.TLS.ALPN) == 1
+		}},
+	}
+	for _, test := range tests {
+		p, err := ParseLink(test.link)
+		if err != nil {
+			t.Errorf("%s: %v", test.link, err)
+			continue
+		}
+		if !test.check(p) {
+			t.Errorf("%s: unexpected %+v", test.link, p)
+		}
+	}
+	for _, link := range []string{"http://1.2.3.4", "vless://not-a-uuid@1.2.3.4:443", "trojan://pass@1.2.3.4"} {
+		if _, err := ParseLink(link); err == nil {
+			t.Errorf("%s: expected error", link)
+		}
+	}
+}
+
+func TestParseSubscription(t *testing.T) {
+	links := "trojan://pass@1.2.3.4:443#a\nss://" + base64.RawURLEncoding.EncodeToString([]byte("aes-128-gcm:secret")) + "@1.2.3.4:8388#b\nbogus://x\n"
+	clash := `
+proxies:
+  - name: hk
+    type: vless
+    server: 1.2.3.4
+    port: 443
+    uuid: ` + testUUID + `
+    tls: true
+    servername: www.example.com
+    client-fingerprint: chrome
+    reality-opts:
+      public-key: ` + testPublicKey + `
+      short-id: ab
+  - name: jp
+    type: hysteria2
+    server: 1.2.3.5
+    port: 443
+    password: pass
+    up: "50 Mbps"
+  - name: us
+    type: vmess
+    server: 1.2.3.7
+    port: 443
+    uuid: ` + testUUID + `
+    alterId: 0
+    cipher: auto
+  - name: sg
+    type: ss
+    server: 1.2.3.8
+    port: 8388
+    cipher: aes-128-gcm
+    password: secret
+  - name: wg
+    type: wireguard
+    server: 1.2.3.6
+    port: 51820
+`
+	singBox := `{"outbounds":[
+  {"type":"direct","tag":"direct"},
+  {"type":"vmess","tag":"us","server":"1.2.3.4","server_port":443,"uuid":"` + testUUID + `",
+   "tls":{"enabled":true,"server_name":"example.com"},"transport":{"type":"ws","path":"/ws"}}
+]}`
+	tests := []struct {
+		name  string
+		data  string
+		names []string
+		err   bool
+	}{
+		{"plain", links, []string{"a", "b"}, true},
+		{"base64", base64.StdEncoding.EncodeToString([]byte(links)), []string{"a", "b"}, true},
+		{"clash", clash, []string{"hk", "jp", "us", "sg"}, true},
+		{"sing-box", singBox, []string{"us"}, false},
+	}
+	for _, test := range tests {
+		proxies, err := ParseSubscription([]byte(test.data))
+		if (err != nil) != test.err {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		var names []string
+		for _, p := range proxies {
+			names = append(names, p.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(test.names, ",") {
+			t.Errorf("%s: got %v, want %v", test.name, names, test.names)
+		}
+	}
+	proxies, _ := ParseSubscription([]byte(clash))
+	if hk := proxies[0]; hk.TLS == nil || hk.TLS.Reality == nil || hk.TLS.ServerName != "www.example.com" {
+		t.Errorf("unexpected clash reality node %+v", hk)
+	}
+	if jp := proxies[1]; jp.UpMbps != 50 || jp.TLS == nil {
+		t.Errorf("unexpected clash hysteria2 node %+v", jp)
+	}
+	// cipher 对 vmess 是 security，对 ss 是加密方法
+	if us := proxies[2]; us.Security != "auto" || us.Method != "" {
+		t.Errorf("unexpected clash vmess node %+v", us)
+	}
+	if sg := proxies[3]; sg.Method != "aes-128-gcm" || sg.Security != "" {
+		t.Errorf("unexpected clash shadowsocks node %+v", sg)
+	}
+}
+
+func TestSubscription(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	body := "trojan://pass@1.2.3.4:443#a"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(body))))
+	}))
+	defer server.Close()
+
+	if _, err := ImportLinks("trojan://pass@1.2.3.5:443#link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddSubscription(context.Background(), server.URL, "test", 0); err != nil {
+		t.Fatal(err)
+	}
+	nodes := LoadImported().nodes()
+	if len(nodes) != 2 || nodes[0].Source != SourceLink || nodes[1].Source != server.URL || nodes[1].Name != "a" {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+
+	// 刷新失败时保留上次的节点
+	body = ""
+	if err := RefreshSubscription(context.Background(), server.URL); err == nil {
+		t.Error("expected refresh error")
+	}
+	s := LoadImported().Subscriptions[0]
+	if s.Error == "" || len(s.Nodes) != 1 || s.Interval != DefaultSubscriptionInterval {
+		t.Errorf("unexpected subscription %+v", s)
+	}
+
+	body = "trojan://pass@1.2.3.4:443#a\ntrojan://pass@1.2.3.4:444#b"
+	if err := RefreshSubscription(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if s = LoadImported().Subscriptions[0]; s.Error != "" || len(s.Nodes) != 2 {
+		t.Errorf("unexpected subscription %+v", s)
+	}
+	if err := RemoveSubscription(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if nodes = LoadImported().nodes(); len(nodes) != 1 {
+		t.Errorf("subscription nodes not removed: %+v", nodes)
+	}
+}
+
+func TestImportLinksDeduplicate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	if n, err := ImportLinks("trojan://pass@1.2.3.5:443#a\ntrojan://pass@1.2.3.5:443#a2"); err != nil || n != 1 {
+		t.Fatalf("import: %d %v", n, err)
+	}
+	// 改名不影响 ID，连接参数相同的节点只保留一个
+	if n, err := ImportLinks("trojan://pass@1.2.3.5:443#renamed"); err == nil || n != 0 {
+		t.Errorf("duplicate import: %d %v", n, err)
+	}
+	if n, err := ImportLinks("trojan://pass@1.2.3.5:443#a\ntrojan://pass@1.2.3.6:443#b"); err != nil || n != 1 {
+		t.Errorf("partial import: %d %v", n, err)
+	}
+	links := LoadImported().Links
+	if len(links) != 2 || links[0].Name != "a" || links[1].Name != "b" {
+		t.Fatalf("unexpected links %+v", links)
+	}
+	// 保存的 ID 与合并节点时补全的 ID 一致
+	merged := merge(nil, nil, LoadImported().nodes())
+	if merged[0].ID != links[0].ID || merged[1].ID != links[1].ID {
+		t.Errorf("link ids %s %s differ from merged %s %s", links[0].ID, links[1].ID, merged[0].ID, merged[1].ID)
+	}
+	if listed := ImportedLinks(); len(listed) != 2 || listed[0].ID != links[0].ID || listed[0].Source != SourceLink {
+		t.Errorf("unexpected listed links %+v", listed)
+	}
+	if err := RemoveLink(links[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveLink(links[0].ID); err == nil {
+		t.Error("expected error removing missing link")
+	}
+	if links = LoadImported().Links; len(links) != 1 || links[0].Name != "b" {
+		t.Errorf("unexpected links after remove %+v", links)
+	}
+}
@@ -0,0 +1,534 @@
+package node
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"playfast/internal/http-client"
+	"playfast/internal/path"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 节点来源，订阅节点的来源为订阅地址
+const (
+	SourceRemote = "remote"
+	SourceLink   = "link"
+)
+
+// DefaultSubscriptionInterval 订阅默认的刷新间隔，单位分钟
+const DefaultSubscriptionInterval = 360
+
+// Subscription 订阅及其最近一次拉取到的节点
+type Subscription struct {
+	URL       string    `json:"url"`
+	Name      string    `json:"name"`
+	Interval  int       `json:"interval"` // 刷新间隔，单位分钟
+	UpdatedAt time.Time `json:"updatedAt"`
+	Error     string    `json:"error"` // 最近一次刷新的错误，成功时为空
+	Nodes     []Proxy   `json:"nodes"`
+}
+
+// Imported 本地保存的导入节点，保存在 imported.json
+type Imported struct {
+	Links         []Proxy        `json:"links"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+var importedLock sync.Mutex
+
+func importedFile() string {
+	return filepath.Join(path.Path(), "imported.json")
+}
+
+// LoadImported 读取本地保存的导入节点
+func LoadImported() Imported {
+	importedLock.Lock()
+	defer importedLock.Unlock()
+	return loadImported()
+}
+
+func loadImported() Imported {
+	imported := Imported{}
+	data, err := os.ReadFile(importedFile())
+	if err != nil {
+		return imported
+	}
+	_ = json.Unmarshal(data, &imported)
+	return imported
+}
+
+func saveImported(imported Imported) error {
+	data, err := json.MarshalIndent(imported, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(importedFile(), data, 0644)
+}
+
+// updateImported 在锁内读取、修改并保存导入节点
+func updateImported(update func(*Imported) error) error {
+	importedLock.Lock()
+	defer importedLock.Unlock()
+	imported := loadImported()
+	if err := update(&imported); err != nil {
+		return err
+	}
+	return saveImported(imported)
+}
+
+// nodes 返回所有导入的节点，并标记来源
+func (i Imported) nodes() []Proxy {
+	nodes := make([]Proxy, 0, len(i.Links))
+	for _, p := range i.Links {
+		p.Source = SourceLink
+		nodes = append(nodes, p)
+	}
+	for _, s := range i.Subscriptions {
+		for _, p := range s.Nodes {
+			p.Source = s.URL
+			nodes = append(nodes, p)
+		}
+	}
+	return nodes
+}
+
+// ImportLinks 导入一行一个的分享链接，返回新导入的节点数
+// 连接参数与已导入的链接相同的节点会被跳过，部分链接无法解析时仍保存其余节点，并返回合并后的错误
+func ImportLinks(text string) (int, error) {
+	proxies, err := ParseSubscription([]byte(text))
+	if len(proxies) == 0 {
+		if err == nil {
+			err = errors.New("没有找到可导入的节点")
+		}
+		return 0, err
+	}
+	added := 0
+	saveErr := updateImported(func(imported *Imported) error {
+		seen := make(map[string]struct{}, len(imported.Links)+len(proxies))
+		for _, p := range imported.Links {
+			seen[linkID(p)] = struct{}{}
+		}
+		for _, p := range proxies {
+			p.ID = linkID(p)
+			if _, ok := seen[p.ID]; ok {
+				continue
+			}
+			seen[p.ID] = struct{}{}
+			imported.Links = append(imported.Links, p)
+			added++
+		}
+		return nil
+	})
+	if added == 0 && err == nil && saveErr == nil {
+		err = errors.New("节点已导入")
+	}
+	return added, errors.Join(err, saveErr)
+}
+
+// ImportedLinks 返回通过分享链接导入的节点，以前导入的节点没有保存 ID 时补全
+func ImportedLinks() []Proxy {
+	links := LoadImported().Links
+	for i := range links {
+		links[i].ID = linkID(links[i])
+		links[i].Source = SourceLink
+	}
+	return links
+}
+
+// RemoveLink 按 ID 删除导入的链接节点
+func RemoveLink(id string) error {
+	return updateImported(func(imported *Imported) error {
+		links := imported.Links[:0]
+		for _, p := range imported.Links {
+			if linkID(p) != id {
+				links = append(links, p)
+			}
+		}
+		if len(links) == len(imported.Links) {
+			return fmt.Errorf("导入的节点 %s 不存在", id)
+		}
+		imported.Links = links
+		return nil
+	})
+}
+
+// linkID 导入链接的 ID，与 merge 为没有 ID 的链接补全的 ID 相同
+func linkID(p Proxy) string {
+	if p.ID != "" {
+		return p.ID
+	}
+	p.Source = SourceLink
+	return stableID(p)
+}
+
+// AddSubscription 添加订阅并立即拉取一次
+func AddSubscription(ctx context.Context, url, name string, interval int) error {
+	if interval <= 0 {
+		interval = DefaultSubscriptionInterval
+	}
+	err := updateImported(func(imported *Imported) error {
+		for _, s := range imported.Subscriptions {
+			if s.URL == url {
+				return errors.New("订阅已存在")
+			}
+		}
+		imported.Subscriptions = append(imported.Subscriptions, Subscription{URL: url, Name: name, Interval: interval})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return RefreshSubscription(ctx, url)
+}
+
+// RemoveSubscription 删除订阅及其节点
+func RemoveSubscription(url string) error {
+	return updateImported(func(imported *Imported) error {
+		for i, s := range imported.Subscriptions {
+			if s.URL == url {
+				imported.Subscriptions = append(imported.Subscriptions[:i], imported.Subscriptions[i+1:]...)
+				return nil
+			}
+		}
+		return errors.New("订阅不存在")
+	})
+}
+
+// RefreshSubscription 拉取订阅，失败时保留上次的节点并记录错误
+func RefreshSubscription(ctx context.Context, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var proxies []Proxy
+	data, err := http_client.Get(ctx, url)
+	if err == nil {
+		proxies, err = ParseSubscription(data)
+		if len(proxies) > 0 {
+			// 部分节点无法解析不影响整个订阅
+			if err != nil {
+				log.Println("订阅", url, "部分节点解析失败:", err)
+			}
+			err = nil
+		} else if err == nil {
+			err = errors.New("订阅中没有节点")
+		}
+	}
+	saveErr := updateImported(func(imported *Imported) error {
+		for i := range imported.Subscriptions {
+			s := &imported.Subscriptions[i]
+			if s.URL != url {
+				continue
+			}
+			s.UpdatedAt = time.Now()
+			if err != nil {
+				s.Error = err.Error()
+			} else {
+				s.Error = ""
+				s.Nodes = proxies
+			}
+			return nil
+		}
+		return errors.New("订阅不存在")
+	})
+	return errors.Join(err, saveErr)
+}
+
+// RunSubscriptions 每分钟检查一次，刷新到期的订阅，直到 ctx 结束
+func RunSubscriptions(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		for _, s := range LoadImported().Subscriptions {
+			if time.Since(s.UpdatedAt) >= time.Duration(s.Interval)*time.Minute {
+				if err := RefreshSubscription(ctx, s.URL); err != nil {
+					log.Println("刷新订阅", s.URL, "失败:", err)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ParseSubscription 识别并解析订阅内容，支持 sing-box 配置或出站列表、Clash 配置、
+// base64 编码或明文的分享链接列表。返回成功解析的节点和无法解析的条目的错误
+func ParseSubscription(data []byte) ([]Proxy, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, errors.New("订阅内容为空")
+	}
+	switch data[0] {
+	case '{', '[':
+		return parseSingBox(data)
+	}
+	if bytes.Contains(data, []byte("proxies:")) {
+		return parseClash(data)
+	}
+	if !bytes.Contains(data, []byte("://")) {
+		decoded, err := decodeBase64(string(bytes.Join(bytes.Fields(data), nil)))
+		if err != nil {
+			return nil, fmt.Errorf("无法识别的订阅格式: %w", err)
+		}
+		data = decoded
+	}
+	var proxies []Proxy
+	var errs []error
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		p, err := ParseLink(line)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, errors.Join(errs...)
+}
+
+// singBoxOutbound sing-box 出站中与节点相关的字段
+type singBoxOutbound struct {
+	Type              string `json:"type"`
+	Tag               string `json:"tag"`
+	Server            string `json:"server"`
+	ServerPort        uint16 `json:"server_port"`
+	Method            string `json:"method"`
+	Password          string `json:"password"`
+	UUID              string `json:"uuid"`
+	Flow              string `json:"flow"`
+	Security          string `json:"security"`
+	AlterID           int    `json:"alter_id"`
+	UpMbps            int    `json:"up_mbps"`
+	DownMbps          int    `json:"down_mbps"`
+	CongestionControl string `json:"congestion_control"`
+	Plugin            string `json:"plugin"`
+	PluginOpts        string `json:"plugin_opts"`
+	Obfs              *struct {
+		Type     string `json:"type"`
+		Password string `json:"password"`
+	} `json:"obfs"`
+	TLS *struct {
+		Enabled    bool     `json:"enabled"`
+		ServerName string   `json:"server_name"`
+		Insecure   bool     `json:"insecure"`
+		ALPN       []string `json:"alpn"`
+		UTLS       *struct {
+			Fingerprint string `json:"fingerprint"`
+		} `json:"utls"`
+		Reality *struct {
+			Enabled   bool   `json:"enabled"`
+			PublicKey string `json:"public_key"`
+			ShortID   string `json:"short_id"`
+		} `json:"reality"`
+	} `json:"tls"`
+	Transport *struct {
+		Type        string            `json:"type"`
+		Path        string            `json:"path"`
+		Host        string            `json:"host"`
+		Headers     map[string]string `json:"headers"`
+		ServiceName string            `json:"service_name"`
+	} `json:"transport"`
+}
+
+// parseSingBox 解析完整的 sing-box 配置或出站数组，跳过非节点出站
+func parseSingBox(data []byte) ([]Proxy, error) {
+	var outbounds []singBoxOutbound
+	if data[0] == '{' {
+		var config struct {
+			Outbounds []singBoxOutbound `json:"outbounds"`
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, err
+		}
+		outbounds = config.Outbounds
+	} else if err := json.Unmarshal(data, &outbounds); err != nil {
+		return nil, err
+	}
+	var proxies []Proxy
+	var errs []error
+	for _, o := range outbounds {
+		if o.Server == "" {
+			// direct/block/selector 等
+			continue
+		}
+		p := Proxy{
+			Name:              o.Tag,
+			Protocol:          o.Type,
+			Host:              o.Server,
+			Port:              o.ServerPort,
+			Method:            o.Method,
+			Password:          o.Password,
+			UUID:              o.UUID,
+			Flow:              o.Flow,
+			Security:          o.Security,
+			AlterID:           o.AlterID,
+			UpMbps:            o.UpMbps,
+			DownMbps:          o.DownMbps,
+			CongestionControl: o.CongestionControl,
+			Plugin:            o.Plugin,
+			PluginOpts:        o.PluginOpts,
+		}
+		if o.Obfs != nil {
+			p.Obfs = o.Obfs.Password
+		}
+		if o.TLS != nil && o.TLS.Enabled {
+			p.TLS = &TLS{ServerName: o.TLS.ServerName, Insecure: o.TLS.Insecure, ALPN: o.TLS.ALPN}
+			if o.TLS.UTLS != nil {
+				p.TLS.Fingerprint = o.TLS.UTLS.Fingerprint
+			}
+			if o.TLS.Reality != nil && o.TLS.Reality.Enabled {
+				p.TLS.Reality = &Reality{PublicKey: o.TLS.Reality.PublicKey, ShortID: o.TLS.Reality.ShortID}
+			}
+		}
+		if t := o.Transport; t != nil {
+			p.Transport = &Transport{Type: t.Type, Path: t.Path, Host: t.Host, Headers: t.Headers, ServiceName: t.ServiceName}
+		}
+		if err := p.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, errors.Join(errs...)
+}
+
+// clashProxy Clash 配置中与节点相关的字段
+type clashProxy struct {
+	Name              string         `yaml:"name"`
+	Type              string         `yaml:"type"`
+	Server            string         `yaml:"server"`
+	Port              uint16         `yaml:"port"`
+	Cipher            string         `yaml:"cipher"`
+	Password          string         `yaml:"password"`
+	UUID              string         `yaml:"uuid"`
+	AlterID           int            `yaml:"alterId"`
+	Flow              string         `yaml:"flow"`
+	TLS               bool           `yaml:"tls"`
+	SNI               string         `yaml:"sni"`
+	ServerName        string         `yaml:"servername"`
+	SkipCertVerify    bool           `yaml:"skip-cert-verify"`
+	ALPN              []string       `yaml:"alpn"`
+	Fingerprint       string         `yaml:"client-fingerprint"`
+	Network           string         `yaml:"network"`
+	Up                string         `yaml:"up"`
+	Down              string         `yaml:"down"`
+	ObfsPassword      string         `yaml:"obfs-password"`
+	CongestionControl string         `yaml:"congestion-controller"`
+	Plugin            string         `yaml:"plugin"`
+	PluginOpts        map[string]any `yaml:"plugin-opts"`
+	RealityOpts       *struct {
+		PublicKey string `yaml:"public-key"`
+		ShortID   string `yaml:"short-id"`
+	} `yaml:"reality-opts"`
+	WSOpts *struct {
+		Path    string            `yaml:"path"`
+		Headers map[string]string `yaml:"headers"`
+	} `yaml:"ws-opts"`
+	GRPCOpts *struct {
+		ServiceName string `yaml:"grpc-service-name"`
+	} `yaml:"grpc-opts"`
+}
+
+// clashTypes Clash 节点类型与协议的对应关系
+var clashTypes = map[string]string{
+	"ss":        ProtocolShadowsocks,
+	"vless":     ProtocolVLESS,
+	"trojan":    ProtocolTrojan,
+	"vmess":     ProtocolVMess,
+	"hysteria2": ProtocolHysteria2,
+	"tuic":      ProtocolTUIC,
+	"anytls":    ProtocolAnyTLS,
+	"socks5":    ProtocolSOCKS,
+}
+
+func parseClash(data []byte) ([]Proxy, error) {
+	var config struct {
+		Proxies []clashProxy `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	var proxies []Proxy
+	var errs []error
+	for _, c := range config.Proxies {
+		protocol, ok := clashTypes[c.Type]
+		if !ok {
+			errs = append(errs, errUnsupported(c.Type))
+			continue
+		}
+		p := Proxy{
+			Name:              c.Name,
+			Protocol:          protocol,
+			Host:              c.Server,
+			Port:              c.Port,
+			Password:          c.Password,
+			UUID:              c.UUID,
+			AlterID:           c.AlterID,
+			Flow:              c.Flow,
+			Obfs:              c.ObfsPassword,
+			CongestionControl: c.CongestionControl,
+			UpMbps:            mbps(c.Up),
+			DownMbps:          mbps(c.Down),
+		}
+		// cipher 对 ss 是加密方法，对 vmess 是 security
+		switch protocol {
+		case ProtocolShadowsocks:
+			p.Method = c.Cipher
+		case ProtocolVMess:
+			p.Security = c.Cipher
+		}
+		p.Plugin, p.PluginOpts = clashPlugin(c.Plugin, c.PluginOpts)
+		// trojan/hysteria2/tuic/anytls 始终使用 TLS
+		if c.TLS || c.RealityOpts != nil || protocol == ProtocolTrojan || protocol == ProtocolHysteria2 || protocol == ProtocolTUIC || protocol == ProtocolAnyTLS {
+			p.TLS = &TLS{
+				ServerName:  firstNonEmpty(c.SNI, c.ServerName),
+				Insecure:    c.SkipCertVerify,
+				ALPN:        c.ALPN,
+				Fingerprint: c.Fingerprint,
+			}
+			if c.RealityOpts != nil {
+				p.TLS.Reality = &Reality{PublicKey: c.RealityOpts.PublicKey, ShortID: c.RealityOpts.ShortID}
+			}
+		}
+		switch c.Network {
+		case "ws":
+			p.Transport = &Transport{Type: "ws"}
+			if c.WSOpts != nil {
+				p.Transport.Path = c.WSOpts.Path
+				p.Transport.Headers = c.WSOpts.Headers
+			}
+		case "grpc":
+			p.Transport = &Transport{Type: "grpc"}
+			if c.GRPCOpts != nil {
+				p.Transport.ServiceName = c.GRPCOpts.ServiceName
+			}
+		}
+		if err := p.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		proxies = append(proxies, p)
+	}
+	return proxies, errors.Join(errs...)
+}
+
+// mbps 解析 Clash 中 "100 Mbps" 或 "100" 形式的带宽
+func mbps(s string) int {
+	var n int
+	_, _ = fmt.Sscanf(strings.TrimSpace(s), "%d", &n)
+	return n
+}
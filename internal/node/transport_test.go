@@ -0,0 +1,128 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+// 32 字节的 Reality 公钥
+const testPublicKey = "jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0"
+
+func TestVLESSReality(t *testing.T) {
+	p := Proxy{
+		Protocol: ProtocolVLESS, Host: "1.2.3.4", Port: 443, UUID: testUUID, Flow: FlowVision,
+		TLS: &TLS{ServerName: "www.example.com", Reality: &Reality{PublicKey: testPublicKey, ShortID: "0123abcd"}},
+	}
+	out, err := outboundOptions(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.VLESSOutboundOptions)
+	if options.Flow != FlowVision || options.Multiplex != nil || options.PacketEncoding == nil || *options.PacketEncoding != "xudp" {
+		t.Errorf("unexpected vless options %+v", options)
+	}
+	tls := options.TLS
+	if tls == nil || !tls.Enabled || tls.ServerName != "www.example.com" {
+		t.Fatalf("unexpected tls %+v", tls)
+	}
+	if tls.Reality == nil || !tls.Reality.Enabled || tls.Reality.PublicKey != testPublicKey || tls.Reality.ShortID != "0123abcd" {
+		t.Errorf("unexpected reality %+v", tls.Reality)
+	}
+	if tls.UTLS == nil || !tls.UTLS.Enabled || tls.UTLS.Fingerprint != "chrome" {
+		t.Errorf("reality should default to chrome fingerprint, got %+v", tls.UTLS)
+	}
+}
+
+func TestVLESSWebsocket(t *testing.T) {
+	p := Proxy{
+		Protocol: ProtocolVLESS, Host: "cdn.example.com", Port: 443, UUID: testUUID,
+		TLS:       &TLS{Fingerprint: "firefox", ALPN: []string{"http/1.1"}},
+		Transport: &Transport{Type: "ws", Path: "/ws", Host: "edge.example.com", Headers: map[string]string{"User-Agent": "test"}},
+	}
+	out, err := outboundOptions(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.VLESSOutboundOptions)
+	if options.Multiplex == nil || !options.Multiplex.Enabled {
+		t.Error("multiplex should stay enabled without flow")
+	}
+	if tls := options.TLS; tls.ServerName != "cdn.example.com" || tls.UTLS.Fingerprint != "firefox" || tls.Reality != nil || tls.ALPN[0] != "http/1.1" {
+		t.Errorf("unexpected tls %+v", tls)
+	}
+	transport := options.Transport
+	if transport == nil || transport.Type != constant.V2RayTransportTypeWebsocket || transport.WebsocketOptions.Path != "/ws" {
+		t.Fatalf("unexpected transport %+v", transport)
+	}
+	headers := transport.WebsocketOptions.Headers
+	if headers["Host"][0] != "edge.example.com" || headers["User-Agent"][0] != "test" {
+		t.Errorf("unexpected headers %v", headers)
+	}
+}
+
+func TestVMessGRPC(t *testing.T) {
+	p := Proxy{
+		Protocol: ProtocolVMess, Host: "1.2.3.4", Port: 443, UUID: testUUID, Security: "aes-128-gcm",
+		TLS:       &TLS{ServerName: "grpc.example.com"},
+		Transport: &Transport{Type: "grpc", ServiceName: "tunnel"},
+	}
+	out, err := outboundOptions(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.VMessOutboundOptions)
+	if options.Security != "aes-128-gcm" || options.TLS == nil || options.TLS.ServerName != "grpc.example.com" {
+		t.Errorf("unexpected vmess options %+v", options)
+	}
+	if options.Transport == nil || options.Transport.Type != constant.V2RayTransportTypeGRPC || options.Transport.GRPCOptions.ServiceName != "tunnel" {
+		t.Errorf("unexpected transport %+v", options.Transport)
+	}
+	// 未配置 TLS 的 vmess 不启用 TLS
+	out, _ = outboundOptions(Proxy{Protocol: ProtocolVMess, Host: "1.2.3.4", Port: 80, UUID: testUUID})
+	if out.Options.(*option.VMessOutboundOptions).TLS != nil {
+		t.Error("vmess without tls config should not enable tls")
+	}
+}
+
+func TestTrojanHTTPUpgrade(t *testing.T) {
+	p := Proxy{
+		Protocol: ProtocolTrojan, Host: "trojan.example.com", Port: 443, Password: "secret",
+		Transport: &Transport{Type: "httpupgrade", Path: "/up", Host: "up.example.com"},
+	}
+	out, err := outboundOptions(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.TrojanOutboundOptions)
+	if options.TLS == nil || !options.TLS.Enabled || options.TLS.ServerName != "trojan.example.com" {
+		t.Errorf("trojan should always enable tls, got %+v", options.TLS)
+	}
+	upgrade := options.Transport.HTTPUpgradeOptions
+	if options.Transport.Type != constant.V2RayTransportTypeHTTPUpgrade || upgrade.Host != "up.example.com" || upgrade.Path != "/up" {
+		t.Errorf("unexpected transport %+v", options.Transport)
+	}
+}
+
+func TestTransportValidation(t *testing.T) {
+	base := Proxy{Name: "test", Protocol: ProtocolVLESS, Host: "1.2.3.4", Port: 443, UUID: testUUID}
+	cases := map[string]func(p *Proxy){
+		"flow without tls":    func(p *Proxy) { p.Flow = FlowVision },
+		"unknown flow":        func(p *Proxy) { p.Flow = "xtls-rprx-direct"; p.TLS = &TLS{} },
+		"flow with transport": func(p *Proxy) { p.Flow = FlowVision; p.TLS = &TLS{}; p.Transport = &Transport{Type: "ws"} },
+		"flow on trojan":      func(p *Proxy) { p.Protocol = ProtocolTrojan; p.Password = "x"; p.Flow = FlowVision; p.TLS = &TLS{} },
+		"unknown transport":   func(p *Proxy) { p.Transport = &Transport{Type: "quic"} },
+		"transport on tuic":   func(p *Proxy) { p.Protocol = ProtocolTUIC; p.Password = "x"; p.Transport = &Transport{Type: "ws"} },
+		"bad fingerprint":     func(p *Proxy) { p.TLS = &TLS{Fingerprint: "netscape"} },
+		"bad public key":      func(p *Proxy) { p.TLS = &TLS{Reality: &Reality{PublicKey: "short"}} },
+		"bad short id":        func(p *Proxy) { p.TLS = &TLS{Reality: &Reality{PublicKey: testPublicKey, ShortID: "xyz"}} },
+	}
+	for name, modify := range cases {
+		p := base
+		modify(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+	"slices"
+	"sync"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+// Tuning 节点的连接调优，可由 proxy.json 下发并在本地按节点覆盖，未设置的字段使用默认值
+type Tuning struct {
+	Multiplex *Multiplex `json:"multiplex,omitempty"` // vless 未使用流控时默认 h2mux
+	TFO       *bool      `json:"tfo,omitempty"`       // TCP Fast Open
+	MPTCP     *bool      `json:"mptcp,omitempty"`     // Multipath TCP
+	UoT       *bool      `json:"uot,omitempty"`       // shadowsocks/socks 的 UDP over TCP，默认开启
+}
+
+// Multiplex 多路复用设置
+type Multiplex struct {
+	Enabled        bool    `json:"enabled"`
+	Protocol       string  `json:"protocol,omitempty"` // h2mux、smux 或 yamux，默认 h2mux
+	MaxConnections int     `json:"max_connections,omitempty"`
+	MinStreams     int     `json:"min_streams,omitempty"`
+	MaxStreams     int     `json:"max_streams,omitempty"` // 不能与 max_connections、min_streams 同时设置
+	Padding        bool    `json:"padding,omitempty"`
+	Brutal         *Brutal `json:"brutal,omitempty"` // TCP Brutal 拥塞控制，需要服务端支持
+}
+
+// Brutal TCP Brutal 的上下行带宽，单位 Mbps
+type Brutal struct {
+	UpMbps   int `json:"up_mbps"`
+	DownMbps int `json:"down_mbps"`
+}
+
+// defaultMultiplex vless 未使用流控时默认的多路复用
+var defaultMultiplex = Multiplex{Enabled: true, Protocol: "h2mux", MaxConnections: 8, MinStreams: 16}
+
+// multiplexProtocols 支持多路复用的协议
+var multiplexProtocols = []string{ProtocolShadowsocks, ProtocolVLESS, ProtocolTrojan, ProtocolVMess}
+
+// merge 用 override 中已设置的字段覆盖 t
+func (t *Tuning) merge(override Tuning) *Tuning {
+	merged := Tuning{}
+	if t != nil {
+		merged = *t
+	}
+	if override.Multiplex != nil {
+		merged.Multiplex = override.Multiplex
+	}
+	if override.TFO != nil {
+		merged.TFO = override.TFO
+	}
+	if override.MPTCP != nil {
+		merged.MPTCP = override.MPTCP
+	}
+	if override.UoT != nil {
+		merged.UoT = override.UoT
+	}
+	return &merged
+}
+
+// validate 检查调优设置是否适用于节点协议
+func (t *Tuning) validate(p Proxy, invalid func(format string, args ...any) error) error {
+	if t == nil {
+		return nil
+	}
+	quic := p.Protocol == ProtocolHysteria2 || p.Protocol == ProtocolTUIC
+	if quic && (isSet(t.TFO) || isSet(t.MPTCP)) {
+		return invalid("基于 QUIC 的协议不支持 TFO 和 MPTCP")
+	}
+	if t.UoT != nil && p.Protocol != ProtocolShadowsocks && p.Protocol != ProtocolSOCKS {
+		return invalid("只有 shadowsocks 和 socks 支持设置 UDP over TCP")
+	}
+	m := t.Multiplex
+	if m == nil || !m.Enabled {
+		return nil
+	}
+	if !slices.Contains(multiplexProtocols, p.Protocol) {
+		return invalid("不支持多路复用")
+	}
+	if p.Flow != "" {
+		return invalid("流控 %s 不能与多路复用同时使用", p.Flow)
+	}
+	switch m.Protocol {
+	case "", "h2mux", "smux", "yamux":
+	default:
+		return invalid("不支持的多路复用协议 %q", m.Protocol)
+	}
+	if m.MaxStreams > 0 && (m.MaxConnections > 0 || m.MinStreams > 0) {
+		return invalid("max_streams 不能与 max_connections、min_streams 同时设置")
+	}
+	if m.Brutal != nil && (m.Brutal.UpMbps <= 0 || m.Brutal.DownMbps <= 0) {
+		return invalid("brutal 需要设置上下行带宽")
+	}
+	return nil
+}
+
+func isSet(b *bool) bool {
+	return b != nil && *b
+}
+
+// dialerOptions 生成 TFO 和 MPTCP 的拨号设置
+func (t *Tuning) dialerOptions() option.DialerOptions {
+	if t == nil {
+		return option.DialerOptions{}
+	}
+	return option.DialerOptions{
+		TCPFastOpen:  isSet(t.TFO),
+		TCPMultiPath: isSet(t.MPTCP),
+	}
+}
+
+// uot 生成 UDP over TCP 设置，未设置时开启
+func (t *Tuning) uot() *option.UDPOverTCPOptions {
+	if t != nil && t.UoT != nil && !*t.UoT {
+		return nil
+	}
+	return &option.UDPOverTCPOptions{Enabled: true, Version: 2}
+}
+
+// multiplex 生成多路复用设置，未设置时使用 fallback，fallback 为 nil 表示不使用
+func (t *Tuning) multiplex(fallback *Multiplex) *option.OutboundMultiplexOptions {
+	m := fallback
+	if t != nil && t.Multiplex != nil {
+		m = t.Multiplex
+	}
+	if m == nil || !m.Enabled {
+		return nil
+	}
+	options := &option.OutboundMultiplexOptions{
+		Enabled:        true,
+		Protocol:       m.Protocol,
+		MaxConnections: m.MaxConnections,
+		MinStreams:     m.MinStreams,
+		MaxStreams:     m.MaxStreams,
+		Padding:        m.Padding,
+	}
+	if options.Protocol == "" {
+		options.Protocol = "h2mux"
+	}
+	if m.Brutal != nil {
+		options.Brutal = &option.BrutalOptions{Enabled: true, UpMbps: m.Brutal.UpMbps, DownMbps: m.Brutal.DownMbps}
+	}
+	return options
+}
+
+var tuningLock sync.Mutex
+
+func tuningFile() string {
+	return filepath.Join(path.Path(), "tuning.json")
+}
+
+// LoadTuning 读取本地按节点 ID 保存的调优覆盖
+func LoadTuning() map[string]Tuning {
+	tuningLock.Lock()
+	defer tuningLock.Unlock()
+	return loadTuning()
+}
+
+func loadTuning() map[string]Tuning {
+	tuning := make(map[string]Tuning)
+	data, err := os.ReadFile(tuningFile())
+	if err != nil {
+		return tuning
+	}
+	_ = json.Unmarshal(data, &tuning)
+	return tuning
+}
+
+// SetTuning 保存节点的本地调优覆盖，tuning 为 nil 时删除覆盖
+func SetTuning(ctx context.Context, id string, tuning *Tuning) error {
+	if tuning != nil {
+		p, err := Find(Get(ctx), id)
+		if err != nil {
+			return err
+		}
+		p.Tuning = p.Tuning.merge(*tuning)
+		if err = p.Validate(); err != nil {
+			return err
+		}
+	}
+	tuningLock.Lock()
+	defer tuningLock.Unlock()
+	overrides := loadTuning()
+	if tuning == nil {
+		delete(overrides, id)
+	} else {
+		overrides[id] = *tuning
+	}
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tuningFile(), data, 0644)
+}
+
+// applyTuning 将本地调优覆盖合并到节点，合并后配置无效时保留服务端的配置
+func applyTuning(proxies []Proxy, overrides map[string]Tuning) []Proxy {
+	for i, p := range proxies {
+		override, ok := overrides[p.ID]
+		if !ok {
+			continue
+		}
+		p.Tuning = p.Tuning.merge(override)
+		if err := p.Validate(); err != nil {
+			log.Println("忽略节点", p.ID, "的本地调优:", err)
+			continue
+		}
+		proxies[i] = p
+	}
+	return proxies
+}
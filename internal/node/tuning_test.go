@@ -0,0 +1,100 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+)
+
+func TestTuningDefaults(t *testing.T) {
+	out, err := outboundOptions(Proxy{Protocol: ProtocolVLESS, Host: "127.0.0.1", Port: 443, UUID: testUUID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := out.Options.(*option.VLESSOutboundOptions).Multiplex
+	if m == nil || m.Protocol != "h2mux" || m.MaxConnections != 8 || m.MinStreams != 16 {
+		t.Errorf("unexpected default vless multiplex %+v", m)
+	}
+	out, err = outboundOptions(Proxy{Protocol: ProtocolShadowsocks, Host: "127.0.0.1", Port: 8388, Method: "aes-128-gcm", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.ShadowsocksOutboundOptions)
+	if options.UDPOverTCP == nil || !options.UDPOverTCP.Enabled || options.Multiplex != nil || options.TCPFastOpen {
+		t.Errorf("unexpected default shadowsocks options %+v", options)
+	}
+}
+
+func TestTuning(t *testing.T) {
+	off, on := false, true
+	p := Proxy{
+		Protocol: ProtocolShadowsocks, Host: "127.0.0.1", Port: 8388, Method: "aes-128-gcm", Password: "secret",
+		Tuning: &Tuning{
+			UoT: &off, TFO: &on, MPTCP: &on,
+			Multiplex: &Multiplex{Enabled: true, Protocol: "smux", MaxStreams: 4, Padding: true, Brutal: &Brutal{UpMbps: 50, DownMbps: 100}},
+		},
+	}
+	out, err := outboundOptions(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := out.Options.(*option.ShadowsocksOutboundOptions)
+	if options.UDPOverTCP != nil || !options.TCPFastOpen || !options.TCPMultiPath {
+		t.Errorf("unexpected shadowsocks options %+v", options)
+	}
+	m := options.Multiplex
+	if m == nil || m.Protocol != "smux" || m.MaxStreams != 4 || !m.Padding || m.Brutal == nil || m.Brutal.DownMbps != 100 {
+		t.Errorf("unexpected multiplex %+v", m)
+	}
+	if _, err = newOutbound(context.Background(), out); err != nil {
+		t.Errorf("create outbound: %v", err)
+	}
+
+	// 关闭 vless 默认的多路复用
+	out, err = outboundOptions(Proxy{Protocol: ProtocolVLESS, Host: "127.0.0.1", Port: 443, UUID: testUUID, Tuning: &Tuning{Multiplex: &Multiplex{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := out.Options.(*option.VLESSOutboundOptions).Multiplex; m != nil {
+		t.Errorf("multiplex should be disabled, got %+v", m)
+	}
+}
+
+func TestTuningValidation(t *testing.T) {
+	on := true
+	tests := map[string]Proxy{
+		"multiplex on socks": {Protocol: ProtocolSOCKS, Tuning: &Tuning{Multiplex: &Multiplex{Enabled: true}}},
+		"multiplex with flow": {Protocol: ProtocolVLESS, UUID: testUUID, Flow: FlowVision, TLS: &TLS{},
+			Tuning: &Tuning{Multiplex: &Multiplex{Enabled: true}}},
+		"unknown protocol": {Protocol: ProtocolTrojan, Password: "a", Tuning: &Tuning{Multiplex: &Multiplex{Enabled: true, Protocol: "mux"}}},
+		"streams conflict": {Protocol: ProtocolTrojan, Password: "a", Tuning: &Tuning{Multiplex: &Multiplex{Enabled: true, MaxStreams: 4, MinStreams: 2}}},
+		"brutal bandwidth": {Protocol: ProtocolTrojan, Password: "a", Tuning: &Tuning{Multiplex: &Multiplex{Enabled: true, Brutal: &Brutal{}}}},
+		"tfo on quic":      {Protocol: ProtocolHysteria2, Password: "a", Tuning: &Tuning{TFO: &on}},
+		"uot on trojan":    {Protocol: ProtocolTrojan, Password: "a", Tuning: &Tuning{UoT: &on}},
+	}
+	for name, p := range tests {
+		p.Host, p.Port = "127.0.0.1", 443
+		if err := p.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyTuning(t *testing.T) {
+	off, on := false, true
+	proxies := []Proxy{
+		{ID: "a", Protocol: ProtocolSOCKS, Host: "127.0.0.1", Port: 1080, Tuning: &Tuning{TFO: &on}},
+		{ID: "b", Protocol: ProtocolTrojan, Host: "127.0.0.1", Port: 443, Password: "a"},
+	}
+	proxies = applyTuning(proxies, map[string]Tuning{
+		"a": {UoT: &off},
+		"b": {UoT: &off}, // trojan 不支持，忽略
+	})
+	if a := proxies[0].Tuning; a == nil || !isSet(a.TFO) || a.UoT == nil || *a.UoT {
+		t.Errorf("override should merge with server tuning, got %+v", a)
+	}
+	if proxies[1].Tuning != nil {
+		t.Errorf("invalid override should be ignored, got %+v", proxies[1].Tuning)
+	}
+}
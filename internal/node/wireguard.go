@@ -0,0 +1,107 @@
+package node
+
+import (
+	"context"
+	"encoding/base64"
+	"net/netip"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/include"
+	slog "github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// defaultAllowedIPs wireguard 未设置 allowed_ips 时转发所有流量
+var defaultAllowedIPs = []string{"0.0.0.0/0", "::/0"}
+
+// validateWireGuard 检查 wireguard 的密钥、地址和 reserved
+func (p Proxy) validateWireGuard(invalid func(format string, args ...any) error) error {
+	keys := map[string]string{"private_key": p.PrivateKey, "public_key": p.PublicKey}
+	if p.PreSharedKey != "" {
+		keys["pre_shared_key"] = p.PreSharedKey
+	}
+	for name, key := range keys {
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 32 {
+			return invalid("%s 需要 32 字节的 base64 密钥", name)
+		}
+	}
+	if len(p.Address) == 0 {
+		return invalid("缺少本地地址")
+	}
+	if _, err := parsePrefixes(p.Address); err != nil {
+		return invalid("无效的本地地址: %v", err)
+	}
+	if _, err := parsePrefixes(p.AllowedIPs); err != nil {
+		return invalid("无效的 allowed_ips: %v", err)
+	}
+	if len(p.Reserved) != 0 && len(p.Reserved) != 3 {
+		return invalid("reserved 需要 3 个字节")
+	}
+	for _, b := range p.Reserved {
+		if b < 0 || b > 255 {
+			return invalid("reserved 需要 3 个字节")
+		}
+	}
+	return nil
+}
+
+// parsePrefixes 解析 CIDR 列表，不带前缀长度的地址视为单个地址
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, err
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// wireGuardOptions 生成 wireguard 端点配置，节点已经过校验
+func (p Proxy) wireGuardOptions(dialer option.DialerOptions) *option.WireGuardEndpointOptions {
+	address, _ := parsePrefixes(p.Address)
+	allowed := p.AllowedIPs
+	if len(allowed) == 0 {
+		allowed = defaultAllowedIPs
+	}
+	allowedIPs, _ := parsePrefixes(allowed)
+	var reserved []uint8
+	for _, b := range p.Reserved {
+		reserved = append(reserved, uint8(b))
+	}
+	return &option.WireGuardEndpointOptions{
+		MTU:        p.MTU,
+		Address:    address,
+		PrivateKey: p.PrivateKey,
+		Peers: []option.WireGuardPeer{{
+			Address:      p.Host,
+			Port:         p.Port,
+			PublicKey:    p.PublicKey,
+			PreSharedKey: p.PreSharedKey,
+			AllowedIPs:   allowedIPs,
+			Reserved:     reserved,
+		}},
+		DialerOptions: dialer,
+	}
+}
+
+// newEndpoint 创建并启动可直接拨号的 wireguard 端点，用于探测
+func newEndpoint(ctx context.Context, out option.Outbound) (adapter.Outbound, error) {
+	endpoint, err := include.EndpointRegistry().Create(ctx, nil, slog.StdLogger(), out.Tag, out.Type, out.Options)
+	if err != nil {
+		return nil, err
+	}
+	for _, stage := range adapter.ListStartStages {
+		if err = endpoint.Start(stage); err != nil {
+			_ = endpoint.Close()
+			return nil, E.Cause(err, "start wireguard")
+		}
+	}
+	return endpoint, nil
+}
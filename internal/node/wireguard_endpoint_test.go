@@ -0,0 +1,23 @@
+//go:build with_wireguard && with_gvisor
+
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing/common"
+)
+
+// 默认构建不包含 wireguard，只在带上 with_wireguard 和 with_gvisor 编译标签时测试创建端点
+func TestWireGuardEndpoint(t *testing.T) {
+	out, err := outboundOptions(testWireGuard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbound, err := newOutbound(context.Background(), out)
+	if err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+	_ = common.Close(outbound)
+}
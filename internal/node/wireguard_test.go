@@ -0,0 +1,79 @@
+package node
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+const testWireGuardKey = "YFkPtUmjTyjIlKjQJbp/GxB8mTDM3VDq3aJ2WezRpXY="
+
+func testWireGuard() Proxy {
+	return Proxy{
+		ID:         "wg",
+		Name:       "wg",
+		Protocol:   ProtocolWireGuard,
+		Host:       "127.0.0.1",
+		Port:       51820,
+		PrivateKey: testWireGuardKey,
+		PublicKey:  testWireGuardKey,
+		Address:    []string{"10.0.0.2/32", "fd00::2"},
+		Reserved:   []int{1, 2, 3},
+		MTU:        1280,
+	}
+}
+
+func TestWireGuard(t *testing.T) {
+	out, err := outboundOptions(testWireGuard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := out.Options.(*option.WireGuardEndpointOptions)
+	if out.Type != constant.TypeWireGuard || wg.MTU != 1280 || len(wg.Address) != 2 || wg.Address[1].String() != "fd00::2/128" {
+		t.Fatalf("unexpected endpoint %+v", wg)
+	}
+	peer := wg.Peers[0]
+	if peer.Address != "127.0.0.1" || peer.Port != 51820 || len(peer.AllowedIPs) != 2 || len(peer.Reserved) != 3 || peer.Reserved[2] != 3 {
+		t.Errorf("unexpected peer %+v", peer)
+	}
+
+	// wireguard 作为端点加入链路，前置节点仍是出站
+	p := testWireGuard()
+	p.Detour = DetourUpstream
+	hops, err := chainOf(p, "proxy", nil, Upstream{Type: ProtocolSOCKS, Host: "127.0.0.1", Port: 1080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain, err := options(hops, "proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain.Endpoints) != 1 || chain.Endpoints[0].Tag != "proxy" || len(chain.Outbounds) != 1 || chain.Outbounds[0].Tag != "proxy-detour-1" {
+		t.Fatalf("unexpected chain %+v", chain)
+	}
+	if detour := chain.Endpoints[0].Options.(*option.WireGuardEndpointOptions).Detour; detour != "proxy-detour-1" {
+		t.Errorf("wireguard detour %q, want proxy-detour-1", detour)
+	}
+}
+
+func TestWireGuardValidation(t *testing.T) {
+	invalid := []func(*Proxy){
+		func(p *Proxy) { p.PrivateKey = "" },
+		func(p *Proxy) { p.PublicKey = "short" },
+		func(p *Proxy) { p.PreSharedKey = "AAAA" },
+		func(p *Proxy) { p.Address = nil },
+		func(p *Proxy) { p.Address = []string{"10.0.0.300/32"} },
+		func(p *Proxy) { p.AllowedIPs = []string{"any"} },
+		func(p *Proxy) { p.Reserved = []int{1, 2} },
+		func(p *Proxy) { p.Reserved = []int{1, 2, 256} },
+	}
+	for i, modify := range invalid {
+		p := testWireGuard()
+		modify(&p)
+		if err := p.Validate(); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%d: expected ErrInvalid, got %v", i, err)
+		}
+	}
+}
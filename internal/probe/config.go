@@ -0,0 +1,85 @@
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"playfast/internal/path"
+)
+
+// 探测类型
+const (
+	TypeTCP  = "tcp"
+	TypeTLS  = "tls"
+	TypeHTTP = "http"
+	TypeUDP  = "udp"
+)
+
+// Default 未配置探测目标时使用的 204 检测地址
+var Default = Config{Type: TypeHTTP, Target: "http://cp.cloudflare.com/generate_204"}
+
+// Config 探测目标配置，可以写在 proxy.json 的节点上，也可以按游戏保存在本地
+type Config struct {
+	Type       string `json:"type"`
+	Target     string `json:"target"`                // tcp/tls/udp 为 host:port，http 为 URL
+	ServerName string `json:"server_name,omitempty"` // 仅 tls
+	Insecure   bool   `json:"insecure,omitempty"`    // 仅 tls
+	Method     string `json:"method,omitempty"`      // 仅 http
+}
+
+// New 根据配置创建探测
+func (c Config) New() (Probe, error) {
+	switch c.Type {
+	case TypeTCP:
+		return TCP{Address: c.Target}, nil
+	case TypeTLS:
+		return TLS{Address: c.Target, ServerName: c.ServerName, Insecure: c.Insecure}, nil
+	case TypeHTTP:
+		return HTTP{URL: c.Target, Method: c.Method}, nil
+	case TypeUDP:
+		return UDP{Address: c.Target}, nil
+	}
+	return nil, fmt.Errorf("不支持的探测类型: %s", c.Type)
+}
+
+// Targets 本地保存的按游戏划分的探测目标，Game 为当前选择的游戏
+type Targets struct {
+	Game  string            `json:"game"`
+	Games map[string]Config `json:"games"`
+}
+
+func targetsFile() string {
+	return filepath.Join(path.Path(), "probe.json")
+}
+
+// LoadTargets 读取本地保存的探测目标
+func LoadTargets() Targets {
+	targets := Targets{Games: make(map[string]Config)}
+	data, err := os.ReadFile(targetsFile())
+	if err != nil {
+		return targets
+	}
+	_ = json.Unmarshal(data, &targets)
+	return targets
+}
+
+// SaveTargets 保存探测目标
+func SaveTargets(targets Targets) error {
+	data, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(targetsFile(), data, 0644)
+}
+
+// Resolve 选择实际使用的探测目标：当前游戏优先，其次是节点自带的配置，最后是默认值
+func (t Targets) Resolve(node *Config) Config {
+	if config, ok := t.Games[t.Game]; ok && t.Game != "" {
+		return config
+	}
+	if node != nil && node.Type != "" {
+		return *node
+	}
+	return Default
+}
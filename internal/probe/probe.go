@@ -0,0 +1,147 @@
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"playfast/internal/echo"
+	"time"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+// Dialer 与 sing-box 出站的 DialContext 签名一致，为 nil 时直接连接
+type Dialer func(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error)
+
+// Probe 测量一次经过拨号器到目标的延迟
+type Probe interface {
+	Probe(ctx context.Context, dial Dialer) (time.Duration, error)
+}
+
+func (d Dialer) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	if d == nil {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+	return d(ctx, network, M.ParseSocksaddr(address))
+}
+
+// TCP 测量 TCP 连接建立耗时
+// 经过代理出站时，部分协议在首次写入前不会真正连接，测到的是到节点的耗时
+type TCP struct {
+	Address string
+}
+
+func (p TCP) Probe(ctx context.Context, dial Dialer) (time.Duration, error) {
+	start := time.Now()
+	conn, err := dial.dial(ctx, "tcp", p.Address)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	_ = conn.Close()
+	return elapsed, nil
+}
+
+// TLS 测量 TLS 握手耗时，不包含 TCP 连接时间
+type TLS struct {
+	Address    string
+	ServerName string // 为空时使用地址中的主机名
+	Insecure   bool
+}
+
+func (p TLS) Probe(ctx context.Context, dial Dialer) (time.Duration, error) {
+	conn, err := dial.dial(ctx, "tcp", p.Address)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = conn.Close() }()
+	serverName := p.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(p.Address)
+	}
+	client := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: p.Insecure})
+	start := time.Now()
+	if err = client.HandshakeContext(ctx); err != nil {
+		return 0, err
+	}
+	return time.Since(start), nil
+}
+
+// HTTP 发送 HEAD 或 GET 请求，测量收到响应头的耗时，2xx 和 3xx 视为成功
+// 常用目标为返回 204 的连通性检测地址
+type HTTP struct {
+	URL    string
+	Method string // 默认 HEAD
+}
+
+func (p HTTP) Probe(ctx context.Context, dial Dialer) (time.Duration, error) {
+	method := p.Method
+	if method == "" {
+		method = http.MethodHead
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	transport := &http.Transport{
+		DialContext:       dial.dial,
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(start)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return 0, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return elapsed, nil
+}
+
+// UDP 向 UDP Echo 服务发送一组测试包，返回往返延迟的平均值
+type UDP struct {
+	Address  string
+	Count    int           // 默认 5
+	Interval time.Duration // 默认 20ms
+}
+
+func (p UDP) Probe(ctx context.Context, dial Dialer) (time.Duration, error) {
+	count, interval := p.Count, p.Interval
+	if count <= 0 {
+		count = 5
+	}
+	if interval <= 0 {
+		interval = 20 * time.Millisecond
+	}
+	options := []echo.ClientOption{echo.WithNetwork("udp"), echo.WithTimeout(time.Second)}
+	if dial != nil {
+		options = append(options, echo.WithDialer(dial))
+	}
+	client := echo.NewClient(p.Address, options...)
+	if err := client.Connect(ctx); err != nil {
+		return 0, err
+	}
+	defer func() { _ = client.Close() }()
+	stats, err := client.Stream(ctx, make([]byte, 32), count, interval)
+	if err != nil {
+		return 0, err
+	}
+	if stats.Received == 0 {
+		return 0, errors.New("全部丢包")
+	}
+	s := client.GetStats()
+	return s.Sum / time.Duration(s.Count), nil
+}
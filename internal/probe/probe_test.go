@@ -0,0 +1,104 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"playfast/internal/echo"
+	"testing"
+	"time"
+
+	M "github.com/sagernet/sing/common/metadata"
+)
+
+func TestProbes(t *testing.T) {
+	httpServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer httpServer.Close()
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	echoServer := echo.NewServer()
+	defer func() { _ = echoServer.Close() }()
+	udpAddr, err := echoServer.ListenUDP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probes := map[string]Probe{
+		"tcp":  TCP{Address: httpServer.Listener.Addr().String()},
+		"tls":  TLS{Address: tlsServer.Listener.Addr().String(), Insecure: true},
+		"http": HTTP{URL: httpServer.URL},
+		"udp":  UDP{Address: udpAddr.String(), Interval: time.Millisecond},
+	}
+	var dialed int
+	dialer := func(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+		dialed++
+		var d net.Dialer
+		return d.DialContext(ctx, network, destination.String())
+	}
+	for name, p := range probes {
+		latency, err := p.Probe(context.Background(), dialer)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if latency <= 0 {
+			t.Errorf("%s: unexpected latency %s", name, latency)
+		}
+	}
+	if dialed != len(probes) {
+		t.Errorf("dialer used %d times, want %d", dialed, len(probes))
+	}
+}
+
+func TestHTTPStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := (HTTP{URL: server.URL}).Probe(context.Background(), nil); err == nil {
+		t.Error("expected error for 404")
+	}
+}
+
+func TestProbeCancel(t *testing.T) {
+	// 拨号器一直阻塞到 ctx 结束
+	blocking := func(ctx context.Context, _ string, _ M.Socksaddr) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for _, p := range []Probe{
+		TCP{Address: "192.0.2.1:80"},
+		TLS{Address: "192.0.2.1:443"},
+		HTTP{URL: "http://192.0.2.1/generate_204"},
+		UDP{Address: "192.0.2.1:7"},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err := p.Probe(ctx, blocking)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%T: expected deadline exceeded, got %v", p, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	node := &Config{Type: TypeTCP, Target: "1.1.1.1:443"}
+	game := Config{Type: TypeUDP, Target: "game.example:7"}
+	targets := Targets{Games: map[string]Config{"demo": game}}
+	if got := targets.Resolve(nil); got != Default {
+		t.Errorf("got %+v, want default", got)
+	}
+	if got := targets.Resolve(node); got != *node {
+		t.Errorf("got %+v, want node target", got)
+	}
+	targets.Game = "demo"
+	if got := targets.Resolve(node); got != game {
+		t.Errorf("got %+v, want game target", got)
+	}
+	if _, err := (Config{Type: "icmp"}).New(); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
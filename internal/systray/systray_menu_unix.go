@@ -4,8 +4,8 @@
 package systray
 
 import (
-	"PlayFast/internal/systray/internal/generated/menu"
 	"log"
+	"playfast/internal/systray/internal/generated/menu"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/godbus/dbus/v5/prop"
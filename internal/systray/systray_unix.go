@@ -8,14 +8,14 @@
 package systray
 
 import (
-	"PlayFast/internal/systray/internal/generated/menu"
-	"PlayFast/internal/systray/internal/generated/notifier"
 	"bytes"
 	"fmt"
 	"image"
 	_ "image/png" // used only here
 	"log"
 	"os"
+	"playfast/internal/systray/internal/generated/menu"
+	"playfast/internal/systray/internal/generated/notifier"
 	"sync"
 	"time"
 
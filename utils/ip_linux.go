@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// routeEntry 对应 /proc/net/route 中的一行
+type routeEntry struct {
+	iface   string
+	dest    uint32
+	gateway uint32
+	mask    uint32
+	metric  int
+}
+
+func readRouteTable() ([]routeEntry, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	entries := make([]routeEntry, 0)
+	scanner := bufio.NewScanner(file)
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		dest, err1 := strconv.ParseUint(fields[1], 16, 32)
+		gateway, err2 := strconv.ParseUint(fields[2], 16, 32)
+		metric, err3 := strconv.Atoi(fields[6])
+		mask, err4 := strconv.ParseUint(fields[7], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			continue
+		}
+		entries = append(entries, routeEntry{
+			iface:   fields[0],
+			dest:    uint32(dest),
+			gateway: uint32(gateway),
+			mask:    uint32(mask),
+			metric:  metric,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// hexIP 将 /proc/net/route 中的小端序地址转换为 IP
+func hexIP(v uint32) net.IP {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return net.IPv4(b[0], b[1], b[2], b[3])
+}
+
+func GetDefaultInterface() (*net.Interface, error) {
+	table, err := readRouteTable()
+	if err != nil {
+		return nil, err
+	}
+	name := ""
+	minM := 0
+	for _, row := range table {
+		if row.dest == 0 && row.mask == 0 {
+			if row.metric < minM || name == "" {
+				minM = row.metric
+				name = row.iface
+			}
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no default interface found")
+	}
+	return net.InterfaceByName(name)
+}
+
+func getDefaultGateway(ifIndex int) (string, int, error) {
+	iface, err := net.InterfaceByIndex(ifIndex)
+	if err != nil {
+		return "", 0, err
+	}
+	table, err := readRouteTable()
+	if err != nil {
+		return "", 0, err
+	}
+	for _, row := range table {
+		if row.iface == iface.Name && row.dest == 0 && row.mask == 0 {
+			return hexIP(row.gateway).String(), row.metric, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no default gateway found")
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/r10v/gowindows"
+)
+
+func GetDefaultInterface() (*net.Interface, error) {
+	table, err := gowindows.GetIpForwardTable()
+	if err != nil {
+		return nil, err
+	}
+	minM := 0
+	index := 0
+	for _, row := range table {
+		if row.ForwardDest[0] == 0 && row.ForwardDest[1] == 0 && row.ForwardDest[2] == 0 && row.ForwardDest[3] == 0 && row.ForwardMask[0] == 0 && row.ForwardMask[1] == 0 && row.ForwardMask[2] == 0 && row.ForwardMask[3] == 0 {
+			if int(row.ForwardMetric1) < minM || minM == 0 {
+				minM = int(row.ForwardMetric1)
+				index = int(row.ForwardIfIndex)
+			}
+		}
+	}
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range interfaces {
+		if iface.Index == index {
+			return &iface, nil
+		}
+	}
+	return nil, fmt.Errorf("no default interface found")
+}
+
+func getDefaultGateway(ifIndex int) (string, int, error) {
+	table, err := gowindows.GetIpForwardTable()
+	if err != nil {
+		return "", 0, err
+	}
+	for _, row := range table {
+		if row.ForwardIfIndex == gowindows.DWord(ifIndex) && row.ForwardDest[0] == 0 && row.ForwardDest[1] == 0 && row.ForwardDest[2] == 0 && row.ForwardDest[3] == 0 && row.ForwardMask[0] == 0 && row.ForwardMask[1] == 0 && row.ForwardMask[2] == 0 && row.ForwardMask[3] == 0 {
+			return net.IP(row.ForwardNextHop[:]).String(), int(row.ForwardMetric1), nil
+		}
+	}
+	return "", 0, fmt.Errorf("no default gateway found")
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+)
+
+// Neighbor 表示 ARP/邻居表中的一条记录
+type Neighbor struct {
+	IP  string
+	MAC string
+}
+
+// parseARP 解析 /proc/net/arp 格式的邻居表，跳过未完成解析的记录
+func parseARP(r io.Reader) ([]Neighbor, error) {
+	neighbors := make([]Neighbor, 0)
+	scanner := bufio.NewScanner(r)
+	// 跳过表头
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// 0x0 表示未完成解析
+		if len(fields) < 4 || fields[2] == "0x0" {
+			continue
+		}
+		mac, err := net.ParseMAC(fields[3])
+		if err != nil || mac.String() == "00:00:00:00:00:00" {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{IP: fields[0], MAC: mac.String()})
+	}
+	return neighbors, scanner.Err()
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"os"
+)
+
+// GetNeighbors 读取 /proc/net/arp 中已解析的邻居
+func GetNeighbors() ([]Neighbor, error) {
+	file, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	return parseARP(file)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseARP(t *testing.T) {
+	const header = "IP address       HW type     Flags       HW address            Mask     Device\n"
+	cases := []struct {
+		name  string
+		table string
+		want  []Neighbor
+	}{
+		{"empty", header, []Neighbor{}},
+		{"resolved", header + "192.168.1.20     0x1         0x2         00:D9:D1:12:34:56     *        eth0\n",
+			[]Neighbor{{IP: "192.168.1.20", MAC: "00:d9:d1:12:34:56"}}},
+		{"incomplete", header + "192.168.1.21     0x1         0x0         00:00:00:00:00:00     *        eth0\n", []Neighbor{}},
+		{"zero mac", header + "192.168.1.22     0x1         0x2         00:00:00:00:00:00     *        eth0\n", []Neighbor{}},
+		{"bad mac", header + "192.168.1.23     0x1         0x2         not-a-mac             *        eth0\n", []Neighbor{}},
+		{"short line", header + "192.168.1.24     0x1\n", []Neighbor{}},
+		{"mixed", header +
+			"192.168.1.20     0x1         0x2         00:d9:d1:12:34:56     *        eth0\n" +
+			"192.168.1.21     0x1         0x0         00:00:00:00:00:00     *        eth0\n" +
+			"192.168.1.30     0x1         0x6         7c:ed:8d:00:00:01     *        eth0\n",
+			[]Neighbor{{IP: "192.168.1.20", MAC: "00:d9:d1:12:34:56"}, {IP: "192.168.1.30", MAC: "7c:ed:8d:00:00:01"}}},
+	}
+	for _, c := range cases {
+		got, err := parseARP(strings.NewReader(c.table))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// GetNeighbors 读取系统 ARP 表
+func GetNeighbors() ([]Neighbor, error) {
+	cmd := exec.Command("arp", "-a")
+	// 隐藏窗口（仅适用于 Windows）
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true, // 关键设置，阻止窗口闪现
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to GetNeighbors: %v, output: %s", err, string(output))
+	}
+	neighbors := make([]Neighbor, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		mac, err := net.ParseMAC(fields[1])
+		if err != nil || isMulticastMAC(mac) {
+			continue
+		}
+		neighbors = append(neighbors, Neighbor{IP: fields[0], MAC: mac.String()})
+	}
+	return neighbors, nil
+}
+
+func isMulticastMAC(mac net.HardwareAddr) bool {
+	return len(mac) > 0 && mac[0]&0x01 == 0x01
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// SetIPForwarding 开关指定网卡的 IPv4 转发
+// Linux 下转发由入口网卡的 forwarding 开关决定，无需修改全局 ip_forward
+func SetIPForwarding(index int, enable bool) error {
+	iface, err := net.InterfaceByIndex(index)
+	if err != nil {
+		return fmt.Errorf("failed to set IP forwarding: %v", err)
+	}
+	state := "0"
+	if enable {
+		state = "1"
+	}
+	file := filepath.Join("/proc/sys/net/ipv4/conf", iface.Name, "forwarding")
+	log.Println("echo", state, ">", file)
+	err = os.WriteFile(file, []byte(state), 0644)
+	if err != nil {
+		return fmt.Errorf("failed to set IP forwarding: %v", err)
+	}
+	return nil
+}